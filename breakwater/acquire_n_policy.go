@@ -0,0 +1,24 @@
+package breakwater
+
+// AcquireNPolicy controls what AcquireN does when fewer than n credits
+// are available; see WithAcquireNPolicy.
+type AcquireNPolicy int
+
+const (
+	// AllOrNothing is the default: AcquireN either debits the full n
+	// credits atomically or debits nothing and fails, so a fan-out is
+	// admitted or rejected as a unit instead of partially starting.
+	AllOrNothing AcquireNPolicy = iota
+	// BestEffort: AcquireN debits as many credits as are currently
+	// available, up to n, and only fails if none are available at all.
+	// Callers must check the granted count AcquireN returns rather than
+	// assuming it always equals n.
+	BestEffort
+)
+
+func (p AcquireNPolicy) String() string {
+	if p == BestEffort {
+		return "best_effort"
+	}
+	return "all_or_nothing"
+}
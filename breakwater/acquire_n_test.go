@@ -0,0 +1,128 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAcquireNAllOrNothingGrantsTheFullAmount(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 10)
+
+	release, granted, err := bw.AcquireN(context.Background(), "/svc/Method", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if granted != 4 {
+		t.Fatalf("expected 4 credits granted, got %d", granted)
+	}
+	if got := bw.PeekCredit(""); got != 6 {
+		t.Fatalf("expected the balance to be debited by 4, got %d", got)
+	}
+
+	release()
+	if got := bw.PeekCredit(""); got != 10 {
+		t.Fatalf("expected release() to give all 4 back, got %d", got)
+	}
+}
+
+func TestAcquireNAllOrNothingFailsClosedWhenShort(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 3)
+
+	release, granted, err := bw.AcquireN(context.Background(), "/svc/Method", 4)
+	if err == nil {
+		t.Fatal("expected AllOrNothing to fail when short by even one credit")
+	}
+	if release != nil {
+		t.Fatal("expected a nil release func on failure")
+	}
+	if granted != 0 {
+		t.Fatalf("expected 0 granted on failure, got %d", granted)
+	}
+	de, ok := err.(*DropError)
+	if !ok || de.Reason != InsufficientCredit {
+		t.Fatalf("expected an InsufficientCredit DropError, got %v", err)
+	}
+	// Nothing should have been debited from a failed all-or-nothing attempt.
+	if got := bw.PeekCredit(""); got != 3 {
+		t.Fatalf("expected the balance to stay at 3, got %d", got)
+	}
+}
+
+func TestAcquireNBestEffortGrantsWhatIsAvailable(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithAcquireNPolicy(BestEffort))
+	bw.SetCredit("", 3)
+
+	release, granted, err := bw.AcquireN(context.Background(), "/svc/Method", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if granted != 3 {
+		t.Fatalf("expected 3 credits granted (all that was available), got %d", granted)
+	}
+	if got := bw.PeekCredit(""); got != 0 {
+		t.Fatalf("expected the balance to be fully debited, got %d", got)
+	}
+
+	release()
+	if got := bw.PeekCredit(""); got != 3 {
+		t.Fatalf("expected release() to give the granted 3 back, got %d", got)
+	}
+}
+
+func TestAcquireNBestEffortFailsOnlyWhenNothingIsAvailable(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithAcquireNPolicy(BestEffort))
+	bw.SetCredit("", 0)
+
+	release, granted, err := bw.AcquireN(context.Background(), "/svc/Method", 10)
+	if err == nil {
+		t.Fatal("expected BestEffort to fail when nothing at all is available")
+	}
+	if release != nil {
+		t.Fatal("expected a nil release func on failure")
+	}
+	if granted != 0 {
+		t.Fatalf("expected 0 granted, got %d", granted)
+	}
+}
+
+func TestAcquireNReleaseWithNewCreditAdoptsItOutright(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 10)
+
+	release, _, err := bw.AcquireN(context.Background(), "/svc/Method", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release(50)
+	if got := bw.PeekCredit(""); got != 50 {
+		t.Fatalf("expected release(50) to set the balance to 50, got %d", got)
+	}
+}
+
+func TestAcquireNReleaseIsIdempotent(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 10)
+
+	release, _, err := bw.AcquireN(context.Background(), "/svc/Method", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release()
+	release()
+	if got := bw.PeekCredit(""); got != 10 {
+		t.Fatalf("expected a second release() call to be a no-op, got %d", got)
+	}
+}
+
+func TestAcquireNPolicyStringer(t *testing.T) {
+	if got := AllOrNothing.String(); got != "all_or_nothing" {
+		t.Fatalf("got %q", got)
+	}
+	if got := BestEffort.String(); got != "best_effort" {
+		t.Fatalf("got %q", got)
+	}
+}
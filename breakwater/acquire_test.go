@@ -0,0 +1,89 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAcquireGrantsAndReleaseGivesTheCostBack(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 5)
+
+	ctx, release, err := bw.Acquire(context.Background(), "/svc/Method")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if release == nil {
+		t.Fatal("expected a non-nil release func on success")
+	}
+	if _, ok := AdmitInfoFromContext(ctx); !ok {
+		t.Fatal("expected the returned context to carry AdmitInfo")
+	}
+	if got := bw.PeekCredit(""); got != 4 {
+		t.Fatalf("expected the balance to be debited by 1, got %d", got)
+	}
+
+	release()
+	if got := bw.PeekCredit(""); got != 5 {
+		t.Fatalf("expected release() to give the cost back, got %d", got)
+	}
+}
+
+func TestAcquireReleaseWithNewCreditAdoptsItOutright(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 5)
+
+	_, release, err := bw.Acquire(context.Background(), "/svc/Method")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release(42)
+	if got := bw.PeekCredit(""); got != 42 {
+		t.Fatalf("expected release(42) to set the balance to 42, got %d", got)
+	}
+}
+
+func TestAcquireReleaseIsIdempotent(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 5)
+
+	_, release, err := bw.Acquire(context.Background(), "/svc/Method")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release()
+	release()
+	if got := bw.PeekCredit(""); got != 5 {
+		t.Fatalf("expected a second release() call to be a no-op, got %d", got)
+	}
+}
+
+func TestAcquireFailsClosedWhenInFlightCapIsReached(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithMaxInFlight(1))
+	bw.SetCredit("", 5)
+
+	_, release, err := bw.Acquire(context.Background(), "/svc/Method")
+	if err != nil {
+		t.Fatalf("unexpected error acquiring the only slot: %v", err)
+	}
+	defer release()
+
+	_, secondRelease, err := bw.Acquire(context.Background(), "/svc/Method")
+	if err == nil {
+		t.Fatal("expected the second Acquire to be rejected by the in-flight cap")
+	}
+	if secondRelease != nil {
+		t.Fatal("expected a nil release func on failure")
+	}
+	de, ok := err.(*DropError)
+	if !ok || de.Reason != InFlightLimit {
+		t.Fatalf("expected an InFlightLimit DropError, got %v", err)
+	}
+	// The credit spent attempting the second Acquire must have been
+	// handed back automatically, not left stranded.
+	if got := bw.PeekCredit(""); got != 4 {
+		t.Fatalf("expected the failed Acquire's credit to be returned, got %d", got)
+	}
+}
@@ -0,0 +1,37 @@
+package breakwater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEffectiveExpirationUsesStaticBudgetWithoutSamples(t *testing.T) {
+	bw := New(BWParametersDefault, WithAdaptiveExpiration(2))
+	if got := bw.effectiveExpiration(); got != bw.clientExpiration.Load() {
+		t.Fatalf("expected clientExpiration %d before any RTT sample, got %d", bw.clientExpiration.Load(), got)
+	}
+}
+
+func TestEffectiveExpirationScalesWithMeasuredRTT(t *testing.T) {
+	bw := New(BWParametersDefault, WithAdaptiveExpiration(2))
+	bw.clientExpiration.Store(1_000_000) // generous upper bound so the adaptive value isn't clamped
+
+	bw.recordRTT(10 * time.Microsecond)
+
+	got := bw.effectiveExpiration()
+	want := int64(20) // 2 * 10us
+	if got != want {
+		t.Fatalf("expected effective expiration %d, got %d", want, got)
+	}
+}
+
+func TestEffectiveExpirationClampsToStaticUpperBound(t *testing.T) {
+	bw := New(BWParametersDefault, WithAdaptiveExpiration(100))
+	bw.clientExpiration.Store(500)
+
+	bw.recordRTT(1000 * time.Microsecond) // adaptive value would be 100_000
+
+	if got := bw.effectiveExpiration(); got != bw.clientExpiration.Load() {
+		t.Fatalf("expected clamp to clientExpiration %d, got %d", bw.clientExpiration.Load(), got)
+	}
+}
@@ -0,0 +1,38 @@
+package breakwater
+
+import "context"
+
+/*
+AdmissionPolicy decides whether a request that has already cleared
+queueing, expiration, and credit acquisition should actually be let
+through. acquireCreditLoop consults it once a credit has been taken
+off method's balance, immediately before handing the request back to
+the interceptor: demand is this instance's current reported demand for
+method, creditBalance is the balance left after the credit was taken,
+and waitedUs is how long the request has been queued or parked so far.
+
+Returning false vetoes the request: the credit just taken is returned
+to the balance, and the request is dropped with reason the same way a
+queue-full or expired request is, via DropError. This is the extension
+point for algorithms that want to look past the raw credit count -- eg
+a CoDel-style policy that drops once queuing delay crosses a target, or
+a PID controller tuning admission against an external signal -- without
+reimplementing the queueing, expiration, and credit bookkeeping
+acquireCreditLoop already does.
+*/
+type AdmissionPolicy interface {
+	Admit(ctx context.Context, demand int64, creditBalance int64, waitedUs int64) (admit bool, reason DropReason)
+}
+
+/*
+CreditPolicy is the default AdmissionPolicy: it always admits, since the
+credit acquisition acquireCreditLoop already performed is itself the
+admission decision. Setting WithAdmissionPolicy is the only way to
+change this; an instance that never calls it behaves exactly as before
+AdmissionPolicy existed.
+*/
+type CreditPolicy struct{}
+
+func (CreditPolicy) Admit(ctx context.Context, demand int64, creditBalance int64, waitedUs int64) (bool, DropReason) {
+	return true, QueueFull
+}
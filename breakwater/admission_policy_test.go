@@ -0,0 +1,43 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type rejectAllPolicy struct{}
+
+func (rejectAllPolicy) Admit(ctx context.Context, demand int64, creditBalance int64, waitedUs int64) (bool, DropReason) {
+	return false, InFlightLimit
+}
+
+func TestAdmissionPolicyCanVetoAnAlreadyCreditedRequest(t *testing.T) {
+	bw := New(BWParametersDefault, WithAdmissionPolicy(rejectAllPolicy{}))
+	bw.SetCredit("", 1)
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError, got %v", err)
+	}
+	if de.Reason != InFlightLimit {
+		t.Fatalf("expected the policy's reason InFlightLimit, got %v", de.Reason)
+	}
+	if got := bw.PeekCredit(""); got != 1 {
+		t.Fatalf("expected the vetoed request's credit to be returned, got %d", got)
+	}
+}
+
+func TestWithoutAdmissionPolicyDefaultsToCreditPolicyAndAlwaysAdmits(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 1)
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := bw.PeekCredit(""); got != 0 {
+		t.Fatalf("expected the credit to be spent, got %d", got)
+	}
+}
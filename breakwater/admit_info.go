@@ -0,0 +1,33 @@
+package breakwater
+
+import "context"
+
+// AdmitInfo describes the state a request was admitted under: how long
+// it waited for a credit and what the balance/demand looked like right
+// after. It's cheap to build from values the interceptors already have
+// on hand, so it's stashed on every admitted request rather than left to
+// be threaded through manually by callers that want it.
+type AdmitInfo struct {
+	WaitedUs      int64 // time spent between entry and admission, including any time parked on a credit
+	CreditBalance int64 // outgoing credit balance immediately after this request's credit was debited
+	Demand        int   // queue length reported alongside this request, see Breakwater.Demand
+}
+
+type admitInfoKey struct{}
+
+// withAdmitInfo attaches info to ctx for AdmitInfoFromContext to read
+// back downstream. Only called from the client interceptors' admit path,
+// never on a drop: a request that never reaches invoker/streamer was
+// never admitted and has nothing to report.
+func withAdmitInfo(ctx context.Context, info AdmitInfo) context.Context {
+	return context.WithValue(ctx, admitInfoKey{}, info)
+}
+
+// AdmitInfoFromContext returns the AdmitInfo stashed by the client
+// interceptors for an admitted request, and false if ctx carries none --
+// eg because the request was bypassed via WithPriority/WithDisabled, or
+// because this ctx was never passed through a breakwater interceptor.
+func AdmitInfoFromContext(ctx context.Context) (AdmitInfo, bool) {
+	info, ok := ctx.Value(admitInfoKey{}).(AdmitInfo)
+	return info, ok
+}
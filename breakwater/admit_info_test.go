@@ -0,0 +1,56 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestAdmitInfoFromContextSetOnAdmittedRequest(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 5)
+
+	var gotInfo AdmitInfo
+	var gotOk bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		gotInfo, gotOk = AdmitInfoFromContext(ctx)
+		return nil
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !gotOk {
+		t.Fatal("expected AdmitInfoFromContext to find a value on an admitted request")
+	}
+	if gotInfo.CreditBalance != 4 {
+		t.Fatalf("expected CreditBalance 4 after debiting 1 from 5, got %d", gotInfo.CreditBalance)
+	}
+}
+
+func TestAdmitInfoFromContextAbsentOnBypass(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	var gotOk bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		_, gotOk = AdmitInfoFromContext(ctx)
+		return nil
+	}
+
+	ctx := WithPriority(context.Background(), High)
+	if err := bw.UnaryInterceptorClient(ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotOk {
+		t.Fatal("expected a bypassed request to carry no AdmitInfo")
+	}
+}
+
+func TestAdmitInfoFromContextAbsentWithoutBreakwater(t *testing.T) {
+	if _, ok := AdmitInfoFromContext(context.Background()); ok {
+		t.Fatal("expected a plain context to carry no AdmitInfo")
+	}
+}
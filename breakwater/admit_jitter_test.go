@@ -0,0 +1,36 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestAdmitJitterDelaysInvoker(t *testing.T) {
+	bw := New(BWParametersDefault, WithAdmitJitter(20*time.Millisecond))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		bw.SetCredit("", 1) // refill so every iteration actually admits
+	}
+	if elapsed := time.Since(start); elapsed == 0 {
+		t.Fatal("expected jitter to add some delay across repeated calls")
+	}
+}
+
+func TestNoAdmitJitterByDefault(t *testing.T) {
+	bw := New(BWParametersDefault)
+	if bw.admitJitter != 0 {
+		t.Fatalf("expected admitJitter 0 by default, got %v", bw.admitJitter)
+	}
+	bw.applyAdmitJitter() // must be a no-op, not panic or sleep
+}
@@ -0,0 +1,23 @@
+package breakwater
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// atomicFloat64 is a float64 counterpart to atomic.Int64/atomic.Bool:
+// sync/atomic has no native float64 type, so this stores the IEEE 754
+// bit pattern in an atomic.Uint64 instead. Used for the handful of
+// float64 fields (thresholdDelay, aqmDelay) that ApplyConfig needs to be
+// able to update while the interceptor is concurrently reading them.
+type atomicFloat64 struct {
+	bits atomic.Uint64
+}
+
+func (a *atomicFloat64) Load() float64 {
+	return math.Float64frombits(a.bits.Load())
+}
+
+func (a *atomicFloat64) Store(v float64) {
+	a.bits.Store(math.Float64bits(v))
+}
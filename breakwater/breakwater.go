@@ -1,11 +1,20 @@
 package breakwater
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
 	"runtime/metrics"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
 )
 
 // make RTT configurable from input
@@ -32,33 +41,616 @@ type Connection struct {
 	issuedWriteLock chan int64
 	demand          int64 // number of requests pending
 	demandWriteLock chan int64
-	id              uuid.UUID
+	id              string         // opaque client id read off the "id" metadata key, see WithID
 	lastUpdated     chan time.Time // last time new credits were issued
 }
 
 type Breakwater struct {
 	clientMap sync.Map // Map of client connections
 	// requestMap      sync.Map  // Map of requests for time tracking
-	lastUpdateTime    time.Time // last time since an RTT update
-	numClients        chan int64
-	rttLock           chan int64 // Lock for cTotal, cIssued, lastUpdateTime update
-	cTotal            int64      // global pool of credits
-	cIssued           chan int64 // total credits currently issued
-	aFactor           float64    // aggressive factor for increasing credits
-	bFactor           float64    // multiplicative factor for decreasing credits
-	SLO               int64      // SLA in microseconds
-	thresholdDelay    float64    // threshold delay (for server-side token reduction) in microseconds
-	aqmDelay          float64    // aqm threshold (for server-side AQM) in microseconds
-	clientExpiration  int64      // client expiration time in microseconds
-	prevHist          *metrics.Float64Histogram
-	currHist          *metrics.Float64Histogram
-	id                uuid.UUID
-	pendingOutgoing   chan int64 // pending outgoing requests
-	noCreditBlocker   chan int64 // block requests when no credits
-	outgoingCredits   chan int64 // outgoing credits
+	lastUpdateTime time.Time // last time since an RTT update
+	numClients     chan int64
+	rttLock        chan int64 // Lock for cTotal, cIssued, lastUpdateTime update
+	cTotal         int64      // global pool of credits
+	cIssued        chan int64 // total credits currently issued
+	aFactor        float64    // aggressive factor for increasing credits
+	bFactor        float64    // multiplicative factor for decreasing credits
+	SLO            int64      // SLA in microseconds
+	// thresholdDelay and aqmDelay are read from the interceptor hot path
+	// and, via ApplyConfig, can be updated by a concurrent config reload,
+	// hence atomicFloat64 rather than a plain float64.
+	thresholdDelay atomicFloat64 // threshold delay (for server-side token reduction) in microseconds
+	aqmDelay       atomicFloat64 // aqm threshold (for server-side AQM) in microseconds
+	// clientExpiration is likewise atomic so ApplyConfig can change it
+	// while acquireCreditLoop is concurrently reading it.
+	clientExpiration atomic.Int64 // client expiration time in microseconds
+	prevHist         *metrics.Float64Histogram
+	currHist         *metrics.Float64Histogram
+	// measuredDelayEWMABits holds getDelay's preferred signal: an
+	// EWMA of real per-request handler latency, as math.Float64bits, in
+	// microseconds. UnaryInterceptor can't observe true queueing time --
+	// it never parks or blocks a request before calling handler, admission
+	// bookkeeping runs synchronously -- so per the documented fallback on
+	// WithQueueDelayEWMAAlpha, it times the handler call itself instead and
+	// feeds that into this EWMA. getDelay only falls back to the
+	// /sched/latencies histogram (prevHist/currHist above) until the first
+	// request has seeded this.
+	measuredDelayEWMABits   atomic.Int64
+	measuredDelayEWMASeeded atomic.Bool
+	// queueDelayEWMAAlpha weights each raw handler-latency sample against
+	// the running average getDelay reports to the AQM controller; see
+	// WithQueueDelayEWMAAlpha. Defaults to defaultQueueDelayEWMAAlpha.
+	queueDelayEWMAAlpha float64
+	id                  uuid.UUID
+	pendingOutgoing     chan int64 // pending outgoing requests
+	noCreditBlocker     chan int64 // parks a waiter when outgoingCredits is at or below 0
+	// outgoingCredits is the client's outgoing credit balance. It used to be
+	// a buffered chan int64 acting as a binary semaphore; it is now an
+	// atomic counter, mutated through TryAcquireCredit/ReleaseCredit/
+	// SetCredit so every call site gets the same wake-a-waiter semantics.
+	// noCreditBlocker still does the actual parking when it hits 0.
+	outgoingCredits   atomic.Int64
 	queueingDelayChan chan DelayOperation
+	// queueCapacity is read whenever a new per-method pool is lazily
+	// created (see poolFor), which can race with an ApplyConfig reload,
+	// hence atomic.Int64. It does not resize pendingOutgoing/
+	// noCreditBlocker, the instance-wide channels sized from it at
+	// construction -- see ApplyConfig's doc comment.
+	queueCapacity atomic.Int64
+
+	// Per-instance policy toggles. These mirror the deprecated package-level
+	// globals of the same name and let separate Breakwater instances run with
+	// different policies in the same process. Set via New's Option functions.
+	useClientQueueLength    bool
+	useClientTimeExpiration bool
+	creditsOnFail           bool
+	streamCreditInterval    int64 // debit an extra credit every N messages sent on a stream; 0 disables
+
+	// Counters backing Stats(). Incremented with sync/atomic from the
+	// interceptor hot path, read with sync/atomic from Stats().
+	droppedCount  uint64 // requests dropped locally: queue-too-long, shutting down, or the WithMaxInFlight cap
+	expiredCount  uint64 // requests dropped for expiration (fixed budget or context deadline)
+	bypassedCount uint64 // requests that skipped admission control entirely, via WithPriority(ctx, High) or while disabled (see enabled)
+	backoffMicros uint64 // total time acquireCreditLoop spent sleeping between zero-credit retries, see zeroCreditBackoff
+
+	// creditsOnFailNoop counts returnCreditOnFail calls that had nothing
+	// to give back (cost<=0), so that path's outcome stays observable
+	// instead of silently doing nothing.
+	creditsOnFailNoop uint64
+
+	// creditsGranted and creditsConsumed back Stats()'s utilization
+	// ratio: creditsGranted is every credit a server response has ever
+	// added to the outgoing balance (via mergeCredit/mergeCreditDelta, on
+	// the instance-wide pool only, not a per-method one), and
+	// creditsConsumed is every credit TryAcquireCreditN has successfully
+	// debited from it. A low consumed/granted ratio means the server is
+	// over-granting relative to what this client actually uses.
+	creditsGranted  uint64
+	creditsConsumed uint64
+
+	// enabled gates whether UnaryInterceptorClient/StreamInterceptorClient
+	// enforce admission control at all. true by default; WithDisabled
+	// starts an instance disabled, and Enable/Disable flip it at runtime,
+	// letting a canary rollout wire breakwater into the interceptor chain
+	// and turn it on later without a redeploy. Disabled calls take the
+	// same passthrough path as a WithPriority(ctx, High) bypass.
+	enabled atomic.Bool
+
+	metrics MetricsRecorder // optional external metrics sink, nil by default
+
+	slogger *slog.Logger // structured logger; defaults to a no-op logger
+
+	clientTTL time.Duration // server-side: evict clients unseen for longer than this; 0 disables eviction
+
+	// delayTrackingRunning records whether manageQueueingDelay's goroutine
+	// was actually started for this instance (param.ServerSide && loadShedding
+	// at construction time). queueingDelayChan is unbuffered, so anything
+	// that sends a DelayOperation -- like ServerStats' read -- must check
+	// this first or it blocks forever against a reader that doesn't exist.
+	delayTrackingRunning bool
+
+	// creditFloor is the minimum outgoing credit balance updateCreditsFromTrailer
+	// will settle on, in place of the previously hardcoded 1. A floor of 0
+	// lets an overloaded server fully pause a client; see WithCreditFloor.
+	// atomic.Int64 so ApplyConfig can change it while the interceptor is
+	// concurrently reading it.
+	creditFloor atomic.Int64
+
+	// maxDemand caps the demand value UnaryInterceptor accepts from
+	// the "demand" metadata entry, clamping anything above it rather than
+	// feeding an unbounded or adversarial value into updateCreditsToIssue.
+	// See WithMaxDemand and defaultMaxDemand.
+	maxDemand atomic.Int64
+
+	// priorityQueue backs the instance-wide pool's admission order among
+	// requests parked in acquireCreditLoop; a MethodConfig override gets
+	// its own in methodPool.priorityQueue instead. See priority_queue.go
+	// and WithPriorityAging.
+	priorityQueue *priorityWaitQueue
+	// priorityAgingPerSecond is shared by every priorityWaitQueue this
+	// instance creates (the instance-wide one above, and every
+	// methodPool's). See WithPriorityAging.
+	priorityAgingPerSecond float64
+
+	// admitRateLimiter paces the admit path independent of credits, for
+	// WithMaxAdmitRate; nil (the default) disables it. See
+	// awaitAdmitRate.
+	admitRateLimiter *tokenBucket
+
+	// clientKeyFunc overrides how the server derives the per-client key
+	// it registers/looks up Connections under, for operators who want a
+	// trust model other than defaultClientKey. nil (the default) leaves
+	// that to defaultClientKey. See WithClientKeyFunc and client_key.go.
+	clientKeyFunc func(ctx context.Context) string
+	// peerValidatedID, when set, makes defaultClientKey fold the
+	// transport peer's address into the key even when the client did
+	// supply an id, so the same claimed id from two different
+	// connections can't share or collide on one Connection's accounting.
+	// See WithPeerValidatedID.
+	peerValidatedID bool
+
+	// cheapHintThreshold is how fast a WithCheapHint request's response
+	// has to come back for UnaryInterceptorClient to proactively release
+	// its credit. See defaultCheapHintThreshold and WithCheapHintThreshold.
+	cheapHintThreshold time.Duration
+	// cheapHintReleases counts how many responses actually triggered the
+	// proactive release above, surfaced via Stats().CheapHintReleases.
+	cheapHintReleases uint64
+
+	// queueFullPolicy and queueFullBlockTimeout back WithQueueFullPolicy;
+	// Reject (the zero value) preserves the original always-drop
+	// behavior for anyone who never sets this. See QueueFullPolicy.
+	queueFullPolicy       QueueFullPolicy
+	queueFullBlockTimeout time.Duration
+	// dropOldestQueue is the instance-wide pool's FIFO eviction tracker
+	// for QueueFullPolicy's DropOldest; a MethodConfig override gets its
+	// own in methodPool.dropOldestQueue instead. See queue_full_policy.go.
+	dropOldestQueue *dropOldestQueue
+
+	// creditStore, when set via WithCreditStore, persists the
+	// instance-wide outgoing balance so a restarted process can resume
+	// near where it left off instead of cold-starting. nil is the
+	// default, meaning no persistence. See credit_store.go.
+	creditStore CreditStore
+
+	// circuitBreaker, when set via WithCircuitBreaker, fails requests fast
+	// with CircuitOpen once the drop rate stays too high for too long,
+	// instead of letting them queue and then drop one at a time. nil is
+	// the default, meaning the breaker never intervenes. See
+	// circuit_breaker.go.
+	circuitBreaker *circuitBreaker
+
+	// zeroCreditSince and zeroCreditWarnAfter back the safety timeout that
+	// warns when a client has sat at creditFloor (with creditFloor <= 0)
+	// for too long, in case the server never sends a positive credit again.
+	// zeroCreditSince holds UnixNano, 0 meaning "not currently stalled".
+	zeroCreditSince     atomic.Int64
+	zeroCreditWarnAfter time.Duration
+
+	// demandIdleSince backs WithCreditDecay's "give back" of overcommitted
+	// capacity: when the instance-wide demand (see Demand) drops to zero,
+	// demandIdleSince records when, so watchCreditDecay can shrink
+	// outgoingCredits toward creditFloor once it's stayed there past the
+	// configured idle window. Holds UnixNano, 0 meaning "demand is
+	// currently nonzero".
+	demandIdleSince atomic.Int64
+
+	// fifo gates strict ordering of queued requests; see WithFIFO.
+	fifo          bool
+	ticketCounter atomic.Int64 // next ticket handed out when fifo is enabled
+	servingTicket atomic.Int64 // ticket currently permitted to attempt acquireCredit
+	ticketWaiters sync.Map     // ticket (int64) -> chan struct{}, closed when it becomes that ticket's turn
+
+	// methodConfigs holds the per-method overrides set via WithMethodConfig,
+	// written only during construction before any request can run, so the
+	// lock-free reads in poolFor are safe without a mutex. methodPools is
+	// the lazily-populated method string -> *methodPool cache backing them.
+	methodConfigs map[string]MethodConfig
+	methodPools   sync.Map
+
+	// partitionKeyFunc, set via WithCreditPartition, derives an extra key
+	// from the outgoing context -- eg the backend address a load
+	// balancer's picker chose for this call -- that gets folded into the
+	// method string used to look up a credit pool. This reuses poolFor's
+	// per-method-pool machinery unchanged: a partitioned call simply asks
+	// for a pool keyed by "method\x1fpartition" instead of "method", and
+	// poolFor creates one the first time that composite key is seen, even
+	// without an explicit WithMethodConfig entry for it. nil disables
+	// partitioning, the default.
+	partitionKeyFunc func(ctx context.Context) string
+
+	// poolScope, set via WithPoolScope, narrows or widens poolKey's notion
+	// of "method" before partitionKeyFunc is applied on top of it. See
+	// PoolScope.
+	poolScope PoolScope
+
+	// creditClassesEnabled is set by WithCreditClasses: while true,
+	// poolKey routes every call by its WithClass tag (or defaultClassName
+	// for untagged calls) instead of by method/partition, reusing the same
+	// methodConfigs/methodPools machinery under class-namespaced keys; see
+	// classPoolKey.
+	creditClassesEnabled bool
+
+	// adaptiveExpiration and adaptiveMultiplier back WithAdaptiveExpiration:
+	// when enabled, the client-side expiration check in acquireCredit uses
+	// adaptiveMultiplier * ewmaRTTMicros instead of the static
+	// clientExpiration, clamped to clientExpiration as an upper bound.
+	adaptiveExpiration bool
+	adaptiveMultiplier float64
+	// ewmaRTTMicros is an exponentially weighted moving average of the
+	// invoker round-trip time, in microseconds, updated by recordRTT after
+	// every unary call. 0 means no sample has been recorded yet.
+	ewmaRTTMicros atomic.Int64
+
+	// tracerProvider backs WithTracerProvider; nil means tracer() falls
+	// back to the global provider (otel.GetTracerProvider) at call time,
+	// so a provider installed after New still takes effect.
+	tracerProvider trace.TracerProvider
+
+	// demandCount is the exact count of requests currently queued in
+	// pendingOutgoing, maintained alongside it by queueRequest/
+	// dequeueRequest rather than read via len(pendingOutgoing), which
+	// races against concurrent queue/dequeue. Backs the exported Demand.
+	demandCount atomic.Int64
+
+	// onDrop, set via WithOnDrop, is called synchronously from the hot
+	// path at every drop point (queue-full, expiration, cancellation).
+	// nil means no callback is registered.
+	onDrop func(reason DropReason, id string, waitedUs int64)
+
+	// stallTimeout, stallFailsRequest, and onStall back WithStallTimeout:
+	// a waiter parked on noCreditBlocker longer than stallTimeout fires
+	// onStall (if set) and increments stallCount, and -- only if
+	// stallFailsRequest is true -- is failed outright with a Stalled
+	// DropError instead of continuing to wait. 0 (the default) disables
+	// the watchdog entirely.
+	stallTimeout      time.Duration
+	stallFailsRequest bool
+	onStall           func(id string, method string, waitedUs int64)
+	stallCount        uint64
+
+	// creditWaitTimeout backs WithCreditWaitTimeout: a separate, normally
+	// shorter ceiling than clientExpiration/useClientTimeExpiration, on how
+	// long a request may sit parked on noCreditBlocker specifically. Unlike
+	// WithStallTimeout it always drops the request once exceeded, with a
+	// distinct CreditTimeout reason, rather than offering a report-only
+	// mode. 0 (the default) disables it.
+	creditWaitTimeout time.Duration
+
+	// demandReportInterval backs WithDemandReportInterval: when nonzero,
+	// UnaryInterceptorClient/StreamInterceptorClient only attach the
+	// "demand" header once this much time has passed since
+	// lastDemandReportAt, trading some server-side staleness (it falls
+	// back to lastKnownDemand between reports) for fewer metadata bytes
+	// on the wire. 0 (the default) reports on every call.
+	demandReportInterval time.Duration
+	lastDemandReportAt   atomic.Int64
+
+	// creditAvailabilityCh backs CreditAvailability: noteCreditTransition
+	// sends onto it, non-blocking and coalescing, whenever the
+	// instance-wide balance crosses between <=0 and >0, so a consumer can
+	// react to admission state changing without polling PeekCredit. Sized
+	// 1 since only the most recent transition matters to a reader that
+	// hasn't caught up yet.
+	creditAvailabilityCh chan bool
+
+	// creditAvailableMu/creditAvailableGen back creditAvailableSignal: an
+	// internal-only broadcast, woken whenever the instance-wide balance
+	// crosses from <=0 to >0, that acquireCreditLoop's zero-credit retry
+	// selects on so it re-checks TryAcquireCreditN as soon as a real
+	// credit shows up instead of only after its backoff timer elapses.
+	// Unlike creditAvailabilityCh (sized 1, meant for a single external
+	// CreditAvailability reader to drain), every waiter parked on
+	// creditAvailableGen at once observes the same close, via the usual
+	// close-and-replace generation-channel pattern, so using it
+	// internally can never steal a signal a CreditAvailability caller was
+	// waiting on.
+	creditAvailableMu  sync.Mutex
+	creditAvailableGen chan struct{}
+
+	// closed, shutdownCh, and inFlight back Close. closed is checked by
+	// queueRequest so no new request is admitted once shutdown has
+	// started; shutdownCh is closed exactly once, alongside ctx.Done(),
+	// to wake every waiter parked in acquireCreditLoop; inFlight counts
+	// requests that have been handed to invoker/streamer and not yet
+	// returned, so Close can wait for them to drain.
+	closed     atomic.Bool
+	shutdownCh chan struct{}
+	inFlight   sync.WaitGroup
+
+	// maxInFlightSem, set via WithMaxInFlight, bounds how many invoker or
+	// streamer calls this instance lets run at once, independent of the
+	// credit balance: a buffered channel used as a non-blocking
+	// semaphore, nil (no cap) by default. Checked after a credit has
+	// already been acquired, so acquireInFlightSlot's failure path must
+	// always give that credit back.
+	maxInFlightSem chan struct{}
+
+	// lastServerLoad holds the most recently observed "load" header value
+	// (0-100, or -1 if none has been seen yet), a read-only signal the
+	// server attaches independent of credit issuance. See noteServerLoad
+	// and Stats().ServerLoad.
+	lastServerLoad atomic.Int64
+
+	// clock backs every time.Now()/time.Since() read on the client-side
+	// hot path (queue-wait timing, expiration, RTT), so tests can supply
+	// a fake one via WithClock instead of sleeping in real time. Defaults
+	// to realClock.
+	clock Clock
+
+	// admitJitter, set via WithAdmitJitter, is the upper bound on a random
+	// delay applied after a waiter acquires a credit but before the
+	// invoker/streamer call, to spread out the stampede that follows a
+	// batch of credits landing on a set of waiters parked on the same
+	// noCreditBlocker. 0 disables it, the default.
+	admitJitter time.Duration
+
+	// idStr caches id.String() once at construction. Every admitted
+	// request reads it (metadata, metrics, drop messages), and
+	// uuid.UUID.String() reformats the 16 raw bytes into hex every call,
+	// so caching it turns a per-request allocation into a one-time one.
+	idStr string
+
+	// demandStrings precomputes strconv.Itoa(0..len(demandStrings)-1) once
+	// at construction, so demandString can avoid strconv.Itoa's allocation
+	// for the common case of a small, bounded demand. Demand values at or
+	// beyond this range fall back to strconv.Itoa.
+	demandStrings [64]string
+
+	// demandEWMAAlpha weights each raw Demand() sample against the
+	// running average reported on the wire; see WithDemandEWMAAlpha.
+	// Defaults to defaultDemandEWMAAlpha.
+	demandEWMAAlpha float64
+	// demandEWMABits holds the current EWMA-smoothed demand as
+	// math.Float64bits, updated by smoothedDemand right before every
+	// "demand" header is sent. Only meaningful once demandEWMASeeded is
+	// true; unlike RTT, a raw demand of exactly 0 is common, so bits==0
+	// can't double as "unseeded" the way ewmaRTTMicros does.
+	demandEWMABits   atomic.Int64
+	demandEWMASeeded atomic.Bool
+
+	// overloadWindow and overloadSheddingThreshold back OverloadLevel;
+	// see WithOverloadWindow/WithOverloadSheddingThreshold. windowStart
+	// holds UnixNano of when windowRequests/windowDrops were last reset,
+	// 0 meaning "not yet started". Both counters are incremented from
+	// the client-side hot path: windowRequests in acquireCreditLoop,
+	// windowDrops in fireOnDrop.
+	overloadWindow            time.Duration
+	overloadSheddingThreshold float64
+	overloadWindowStart       atomic.Int64
+	windowRequests            atomic.Int64
+	windowDrops               atomic.Int64
+
+	// dropCodes holds the per-DropReason gRPC code overrides set via
+	// WithDropCodes. nil (the default) means every drop site keeps using
+	// the code it always has; see dropCode.
+	dropCodes map[DropReason]codes.Code
+
+	// acquireNPolicy controls what AcquireN does when fewer than the
+	// requested n credits are available; see WithAcquireNPolicy.
+	// AllOrNothing (the zero value) is the default.
+	acquireNPolicy AcquireNPolicy
+
+	// resetOnErrorCodes holds the set of gRPC codes set via
+	// WithResetOnErrorCodes for which a failed invoker call resets the
+	// credit balance back to its starting value instead of running
+	// returnCreditOnFail's add-one logic; nil (the default) means no
+	// error code triggers a reset. See resetCreditOnError.
+	resetOnErrorCodes map[codes.Code]bool
+
+	// slowStartEnabled and slowStartDone back WithInitialCredits' ramp:
+	// while enabled and not yet done, updateCreditsFromTrailer doubles
+	// the outgoing balance on every response that carries no real
+	// "credits" value, instead of leaving it unchanged, so a
+	// deliberately low starting balance climbs on its own before the
+	// first real AQM feedback exists to react to it. The first response
+	// that does carry a "credits" value sets slowStartDone and hands
+	// control to the server's own value for good.
+	slowStartEnabled bool
+	slowStartDone    atomic.Bool
+
+	// waitLatency is a log2-bucketed histogram of queue wait times for
+	// admitted requests, recorded in acquireCredit; see WaitLatency.
+	waitLatency waitLatencyHistogram
+
+	// shadowMode backs WithShadowMode: when true, acquireCreditLoop still
+	// runs every queue/expiration/credit decision and counts what it
+	// would have dropped in shadowDropCounts instead of droppedCount/
+	// expiredCount, but always admits the request. WithMaxInFlight's cap
+	// is a hard local resource ceiling rather than a policy under
+	// evaluation, so it stays enforced even in shadow mode.
+	shadowMode       bool
+	shadowDropCounts [int(CircuitOpen) + 1]atomic.Uint64
+
+	// initialCredits is the instance-wide pool's starting balance, used
+	// by Reset to restore outgoingCredits after a reconnect instead of
+	// hardcoding 1. InitBreakwater sets it to match outgoingCredits'
+	// initial value; New re-captures it after opts run, so WithInitialCredits
+	// (and anything else that adjusts the starting balance) is reflected.
+	initialCredits int64
+
+	// chainedCreditFunc backs WithChainedCredit: when set, UnaryInterceptor
+	// runs it over the credits it was about to issue to the upstream
+	// caller, letting a server that is also a client to a downstream hop
+	// forward whichever constraint is tighter. See ChainDownstream for the
+	// common case.
+	chainedCreditFunc ChainCreditFunc
+
+	// creditUpdateMode backs WithCreditUpdateMode; see CreditUpdateMode.
+	// Zero value is Replace, the pre-existing wire behavior.
+	creditUpdateMode CreditUpdateMode
+
+	// broker backs WithBroker: when set, acquireCreditLoop asks it to move
+	// an idle credit over from a registered peer before backing off on a
+	// TryAcquireCreditN miss. nil (the default) means this instance
+	// behaves exactly as it did before CreditBroker existed.
+	broker *CreditBroker
+
+	// admissionPolicy backs WithAdmissionPolicy; see AdmissionPolicy. Never
+	// nil: InitBreakwater defaults it to CreditPolicy{}, which always
+	// admits, so an instance that never calls WithAdmissionPolicy behaves
+	// exactly as before AdmissionPolicy existed.
+	admissionPolicy AdmissionPolicy
+
+	// maxCreditsPerClient backs WithMaxCreditsPerClient: updateCreditsToIssue
+	// clamps a single client's issued credits to this, regardless of its
+	// reported demand, so one client can't capture the whole overcommitted
+	// pool. 0 (the default) means no per-client cap, the pre-existing
+	// behavior.
+	maxCreditsPerClient int64
+}
+
+// demandString returns the decimal string for demand, reusing the
+// precomputed demandStrings table when demand is small enough to be in
+// it, to avoid a strconv.Itoa allocation on the hot path.
+func (b *Breakwater) demandString(demand int) string {
+	if demand >= 0 && demand < len(b.demandStrings) {
+		return b.demandStrings[demand]
+	}
+	return strconv.Itoa(demand)
+}
+
+// poolKey returns the string the interceptor should pass to
+// acquireCredit/TryAcquireCredit/etc for this call. When WithCreditClasses
+// is configured, this is the WithClass-tagged class's pool (or the
+// default class's, for an untagged or unconfigured tag), taking priority
+// over per-method pooling entirely. Otherwise it's method narrowed or
+// widened by poolScope (see PoolScope), combined with
+// partitionKeyFunc(ctx) when WithCreditPartition is set. The separator
+// is a control character that can't appear in a gRPC method path, so it
+// can't collide with an unpartitioned key.
+func (b *Breakwater) poolKey(ctx context.Context, method string) string {
+	if b.creditClassesEnabled {
+		name := defaultClassName
+		if tagged, ok := classFromContext(ctx); ok {
+			name = tagged
+		}
+		return classPoolKey(b.resolveClass(name))
+	}
+	base := method
+	switch b.poolScope {
+	case PoolScopeService:
+		base = serviceFromMethod(method)
+	case PoolScopeConnection:
+		base = ""
+	}
+	if b.partitionKeyFunc == nil {
+		return base
+	}
+	if base == "" {
+		return b.partitionKeyFunc(ctx)
+	}
+	return base + "\x1f" + b.partitionKeyFunc(ctx)
+}
+
+// Enable turns admission control back on, making
+// UnaryInterceptorClient/StreamInterceptorClient resume queueing and
+// credit enforcement on the next call. Safe to call concurrently with
+// in-flight requests.
+func (b *Breakwater) Enable() {
+	b.enabled.Store(true)
 }
 
+// Disable puts this instance into passthrough mode: every subsequent
+// UnaryInterceptorClient/StreamInterceptorClient call skips queueing and
+// credit logic entirely and goes straight to invoker/streamer, the same
+// path WithPriority(ctx, High) takes. Requests already queued or
+// in-flight when Disable is called are unaffected; they run out under
+// whatever policy was in effect when they started. Safe to call
+// concurrently with in-flight requests.
+func (b *Breakwater) Disable() {
+	b.enabled.Store(false)
+}
+
+// fireOnDrop invokes b.onDrop if one is registered; a no-op otherwise.
+// Called synchronously -- see WithOnDrop for the constraint this places
+// on the callback.
+func (b *Breakwater) fireOnDrop(reason DropReason, waitedUs int64) {
+	b.windowDrops.Add(1)
+	if b.onDrop != nil {
+		b.onDrop(reason, b.idStr, waitedUs)
+	}
+}
+
+/*
+noteDrop records that acquireCreditLoop decided to drop a request for
+reason, then fires metrics/OnDrop exactly as a real drop always has.
+Outside shadow mode it bumps real (the Stats() counter this reason has
+always counted against, droppedCount or expiredCount; nil for reasons
+that never counted against one), and reports true so the caller drops
+the request as usual. In shadow mode (see WithShadowMode) it bumps
+shadowDropCounts[reason] instead, leaving real untouched, and reports
+false so the caller admits the request anyway.
+*/
+func (b *Breakwater) noteDrop(reason DropReason, waitedUs int64, real *uint64) bool {
+	if b.shadowMode {
+		b.shadowDropCounts[reason].Add(1)
+	} else if real != nil {
+		atomic.AddUint64(real, 1)
+	}
+	if b.metrics != nil {
+		b.metrics.OnDrop(b.idStr, reason.String())
+	}
+	b.fireOnDrop(reason, waitedUs)
+	return !b.shadowMode
+}
+
+// rttEWMAAlpha weights each new RTT sample against the running average;
+// higher values track recent latency more closely at the cost of more
+// noise.
+const rttEWMAAlpha = 0.2
+
+// defaultDemandEWMAAlpha weights each raw Demand() sample against the
+// running average reported in the "demand" header; see
+// WithDemandEWMAAlpha.
+const defaultDemandEWMAAlpha = 0.2
+
+// defaultQueueDelayEWMAAlpha weights each raw handler-latency sample
+// against the running average getDelay feeds the AQM controller; see
+// WithQueueDelayEWMAAlpha.
+const defaultQueueDelayEWMAAlpha = 0.2
+
+// zeroCreditBackoffBase is the sleep duration after the first consecutive
+// TryAcquireCredit miss in acquireCreditLoop; later misses double it (up
+// to zeroCreditBackoffMaxShift times), jittered and capped by the
+// request's remaining clientExpiration budget.
+const zeroCreditBackoffBase = 500 * time.Microsecond
+
+// zeroCreditBackoffMaxShift caps how many times zeroCreditBackoffBase is
+// doubled, so a long streak of misses doesn't grow the backoff without
+// bound.
+const zeroCreditBackoffMaxShift = 6
+
+// waitForReadyQueuePollInterval is how often waitToQueue retries
+// queueRequest for a wait-for-ready call that found the queue full.
+// There is no blocking variant of queueRequest's channel send to wait
+// on directly, so this polls instead, the same tradeoff
+// zeroCreditBackoffBase makes for credit misses.
+const waitForReadyQueuePollInterval = 500 * time.Microsecond
+
+// defaultMaxDemand bounds the demand value UnaryInterceptor will
+// accept from a client's "demand" metadata absent WithMaxDemand. It is
+// generous relative to any real queue depth (MAX_Q_LENGTH is 50) while
+// still rejecting the kind of huge value a buggy or adversarial client
+// might send, rather than letting it flow unbounded into
+// updateCreditsToIssue.
+const defaultMaxDemand int64 = 1_000_000
+
+// defaultPriorityAgingPerSecond is how many Priority levels a waiter's
+// effective priority rises per second parked in acquireCreditLoop,
+// absent WithPriorityAging. A Low (-1) waiter ties a freshly-arrived
+// Normal (0) one after about a second of waiting, bounding how long
+// background work can be starved by a steady stream of Normal traffic
+// during partial overload.
+const defaultPriorityAgingPerSecond = 1.0
+
+// defaultCheapHintThreshold is how fast a WithCheapHint request's
+// response has to come back for UnaryInterceptorClient to proactively
+// release its credit, absent WithCheapHintThreshold. Generous enough
+// that a genuinely cheap request clears it comfortably, while a request
+// mistakenly marked cheap that actually queued or ran long does not.
+const defaultCheapHintThreshold = 5 * time.Millisecond
+
 // // TODO: Add fields for gRPC contexts
 // type request struct {
 // 	reqID                  uuid.UUID
@@ -69,27 +661,58 @@ func InitBreakwater(param BWParameters) (bw *Breakwater) {
 	bFactor, aFactor, SLO, InitialCredits := param.BFactor, param.AFactor, param.SLO, param.InitialCredits
 	thresholdDelay := float64(SLO) * DELAY_THRESHOLD_PERCENT
 	aqmDelay := thresholdDelay * 2.0
+
+	// A capacity of 0 would make pendingOutgoing an unbuffered channel,
+	// which deadlocks queueRequest/dequeueRequest instead of dropping
+	// requests. Fall back to the default queue length in that case.
+	queueCapacity := param.QueueCapacity
+	if queueCapacity <= 0 {
+		slog.Warn("QueueCapacity must be > 0, falling back to default", slog.Int("default", MAX_Q_LENGTH))
+		queueCapacity = MAX_Q_LENGTH
+	}
+
+	id := uuid.New()
 	bw = &Breakwater{
-		clientMap:        sync.Map{},
-		lastUpdateTime:   time.Now().Add(-1 * time.Second),
-		numClients:       make(chan int64, 1),
-		rttLock:          make(chan int64, 1),
-		cTotal:           InitialCredits,
-		cIssued:          make(chan int64, 1),
-		bFactor:          bFactor,
-		aFactor:          aFactor,
-		SLO:              SLO,
-		thresholdDelay:   thresholdDelay,
-		aqmDelay:         aqmDelay,
-		clientExpiration: param.ClientExpiration,
-		prevHist:         nil,
-		currHist:         nil,
-		id:               uuid.New(),
-		// Outgoing buffer drops requests if > 50 requests in queue
-		pendingOutgoing:   make(chan int64, MAX_Q_LENGTH),
-		noCreditBlocker:   make(chan int64, 1),
-		outgoingCredits:   make(chan int64, 1),
-		queueingDelayChan: make(chan DelayOperation),
+		clientMap:      sync.Map{},
+		lastUpdateTime: time.Now().Add(-1 * time.Second),
+		numClients:     make(chan int64, 1),
+		rttLock:        make(chan int64, 1),
+		cTotal:         InitialCredits,
+		cIssued:        make(chan int64, 1),
+		bFactor:        bFactor,
+		aFactor:        aFactor,
+		SLO:            SLO,
+		prevHist:       nil,
+		currHist:       nil,
+		id:             id,
+		idStr:          id.String(),
+		// Outgoing buffer drops requests once queueCapacity requests are queued
+		pendingOutgoing: make(chan int64, queueCapacity),
+		// noCreditBlocker is buffered to queueCapacity, not 1: a waiter
+		// only ever parks here after queueRequest succeeded, so that's
+		// also the most tokens unblockNoCreditBlock could ever usefully
+		// hand out in one burst (eg a server-reported credit jump that
+		// covers many waiters at once) without any of them going to
+		// waste against a full buffer.
+		noCreditBlocker:           make(chan int64, queueCapacity),
+		queueingDelayChan:         make(chan DelayOperation),
+		slogger:                   noopLogger,
+		shutdownCh:                make(chan struct{}),
+		creditAvailabilityCh:      make(chan bool, 1),
+		creditAvailableGen:        make(chan struct{}),
+		clock:                     realClock{},
+		demandEWMAAlpha:           defaultDemandEWMAAlpha,
+		queueDelayEWMAAlpha:       defaultQueueDelayEWMAAlpha,
+		priorityAgingPerSecond:    defaultPriorityAgingPerSecond,
+		priorityQueue:             newPriorityWaitQueue(defaultPriorityAgingPerSecond),
+		cheapHintThreshold:        defaultCheapHintThreshold,
+		dropOldestQueue:           newDropOldestQueue(),
+		overloadWindow:            defaultOverloadWindow,
+		overloadSheddingThreshold: defaultOverloadSheddingThreshold,
+		admissionPolicy:           CreditPolicy{},
+	}
+	for i := range bw.demandStrings {
+		bw.demandStrings[i] = strconv.Itoa(i)
 	}
 	RTT_MICROSECOND = param.RTT_MICROSECOND
 	debug = param.Verbose
@@ -97,10 +720,40 @@ func InitBreakwater(param BWParameters) (bw *Breakwater) {
 	loadShedding = param.LoadShedding
 	useClientQueueLength = param.UseClientQueueLength
 	trackCredits = param.TrackCredits
+
+	// Seed the instance fields from the same parameters so that instances
+	// created via InitBreakwater behave identically to before. New callers
+	// should prefer New, which lets these be overridden per-instance via
+	// Option functions instead of through the deprecated globals above.
+	bw.useClientTimeExpiration = param.UseClientTimeExpiration
+	bw.useClientQueueLength = param.UseClientQueueLength
+	bw.creditsOnFail = creditsOnFail
+	if param.Verbose {
+		// Preserve the old Verbose behavior for InitBreakwater callers who
+		// haven't migrated to New+WithLogger: a debug-level logger to stderr.
+		bw.slogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	// -1 means "no load signal observed yet", distinct from a real 0
+	// (server measured itself as idle).
+	bw.lastServerLoad.Store(-1)
+	bw.thresholdDelay.Store(thresholdDelay)
+	bw.aqmDelay.Store(aqmDelay)
+	bw.clientExpiration.Store(param.ClientExpiration)
+	bw.queueCapacity.Store(queueCapacity)
+	bw.creditFloor.Store(1)
+	bw.maxDemand.Store(defaultMaxDemand)
+	bw.enabled.Store(true)
+	bw.overloadWindowStart.Store(time.Now().UnixNano())
+	// math.MinInt64, not 0, so the first call always reports its demand
+	// even against a fake clock seeded at the Unix epoch (time.Unix(0,0)),
+	// which would otherwise look like "just reported".
+	bw.lastDemandReportAt.Store(math.MinInt64)
+
 	// unblock blocker
 	bw.noCreditBlocker <- 1
 	// give 1 credit to start
-	bw.outgoingCredits <- 1
+	bw.outgoingCredits.Store(1)
+	bw.initialCredits = 1
 	// unblock rttLock
 	bw.rttLock <- 1
 	// zero credits and delay
@@ -108,8 +761,9 @@ func InitBreakwater(param BWParameters) (bw *Breakwater) {
 	bw.cIssued <- 0
 
 	if param.ServerSide {
-		// log
-		logger("[Server Init]:	Initialized server with params: bFactor: %f, aFactor: %f, SLO: %d, InitialCredits: %d\n", bFactor, aFactor, SLO, InitialCredits)
+		bw.logDebug("server initialized",
+			slog.Float64("bFactor", bFactor), slog.Float64("aFactor", aFactor),
+			slog.Int64("SLO", SLO), slog.Int64("initialCredits", InitialCredits))
 		// Start the goroutine that updates credits periodically
 		// Does update once every rtt in separate goroutine
 		go bw.rttUpdate()
@@ -118,6 +772,7 @@ func InitBreakwater(param BWParameters) (bw *Breakwater) {
 		if loadShedding {
 			// Start the goroutine that manages queueingDelay
 			go bw.manageQueueingDelay()
+			bw.delayTrackingRunning = true
 		}
 	}
 
@@ -151,10 +806,311 @@ func (b *Breakwater) startTimeoutRoutine(duration time.Duration) {
 	// Start a separate Goroutine to unblock requests after the timer expires
 	go func() {
 		<-timer.C
-		logger("[Timeout]:	Unblocking all requests. Updated spend credits to %d\n", 99999999)
-		// Update credits and unblock other requests
-		<-b.outgoingCredits
-		b.outgoingCredits <- 99999999
-		b.unblockNoCreditBlock()
+		b.logDebug("timeout reached, unblocking all requests", slog.Int64("creditBalance", 99999999))
+		b.SetCredit("", 99999999)
+	}()
+}
+
+// TryAcquireCredit attempts to take one credit from method's balance
+// without blocking (method's own pool if WithMethodConfig gave it one,
+// otherwise the instance-wide balance shared by every method). It
+// returns false if the balance is already at or below 0. On success, if
+// the balance is still positive afterward it passes the wakeup along via
+// unblockNoCreditBlock, mirroring the old channel semaphore's behavior of
+// re-signaling noCreditBlocker whenever credits remain for the next
+// waiter.
+func (b *Breakwater) TryAcquireCredit(method string) bool {
+	return b.TryAcquireCreditN(method, 1)
+}
+
+// TryAcquireCreditN is TryAcquireCredit's cost-aware counterpart: it
+// takes n credits from method's balance in one CAS instead of always
+// taking 1, for use with WithCost. n <= 0 is treated as 1. It fails the
+// same way TryAcquireCredit does if the balance can't cover the full
+// cost -- there is no partial debit.
+func (b *Breakwater) TryAcquireCreditN(method string, n int64) bool {
+	if n <= 0 {
+		n = 1
+	}
+	if p := b.poolFor(method); p != nil {
+		return p.tryAcquireN(n)
+	}
+	for {
+		cur := b.outgoingCredits.Load()
+		if cur < n {
+			return false
+		}
+		if b.outgoingCredits.CompareAndSwap(cur, cur-n) {
+			atomic.AddUint64(&b.creditsConsumed, uint64(n))
+			remaining := cur - n
+			if remaining > 0 {
+				// remaining credits are all still up for grabs, so wake
+				// that many waiters in one go instead of one at a time.
+				b.unblockNoCreditBlockN(method, remaining)
+			}
+			b.noteCreditTransition(cur, remaining)
+			return true
+		}
+	}
+}
+
+// tryAcquireUpToN debits min(cur, n) credits from method's balance
+// atomically and returns how many it actually took, for AcquireN's
+// BestEffort policy. It returns 0 without touching the balance if the
+// current balance is already at or below 0.
+func (b *Breakwater) tryAcquireUpToN(method string, n int64) int64 {
+	if n <= 0 {
+		n = 1
+	}
+	if p := b.poolFor(method); p != nil {
+		return p.tryAcquireUpToN(n)
+	}
+	for {
+		cur := b.outgoingCredits.Load()
+		if cur <= 0 {
+			return 0
+		}
+		want := min(cur, n)
+		if b.outgoingCredits.CompareAndSwap(cur, cur-want) {
+			atomic.AddUint64(&b.creditsConsumed, uint64(want))
+			remaining := cur - want
+			if remaining > 0 {
+				b.unblockNoCreditBlockN(method, remaining)
+			}
+			b.noteCreditTransition(cur, remaining)
+			return want
+		}
+	}
+}
+
+// ReleaseCredit adds n credits back to method's balance, waking a
+// parked waiter if the balance was at or below 0 beforehand.
+func (b *Breakwater) ReleaseCredit(method string, n int64) {
+	if p := b.poolFor(method); p != nil {
+		p.release(n)
+		return
+	}
+	after := b.outgoingCredits.Add(n)
+	before := after - n
+	if before <= 0 {
+		b.unblockNoCreditBlockN(method, after)
+	}
+	b.noteCreditTransition(before, after)
+}
+
+// acquireInFlightSlot takes one slot from maxInFlightSem without
+// blocking, returning false immediately if WithMaxInFlight's cap is
+// already reached. Returns true unconditionally when no cap is
+// configured.
+func (b *Breakwater) acquireInFlightSlot() bool {
+	if b.maxInFlightSem == nil {
+		return true
+	}
+	select {
+	case b.maxInFlightSem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseInFlightSlot gives back a slot taken by acquireInFlightSlot; a
+// no-op when no cap is configured.
+func (b *Breakwater) releaseInFlightSlot() {
+	if b.maxInFlightSem == nil {
+		return
+	}
+	<-b.maxInFlightSem
+}
+
+// SetCredit replaces method's balance outright, for the call sites (a
+// trailer-reported balance, the 5-minute failsafe above) that set an
+// absolute value rather than a delta. If the new balance is positive, it
+// wakes as many parked waiters as credits that just became newly
+// available, not just one.
+func (b *Breakwater) SetCredit(method string, v int64) {
+	if p := b.poolFor(method); p != nil {
+		p.set(v)
+		return
+	}
+	prev := b.outgoingCredits.Swap(v)
+	if v > 0 {
+		b.unblockNoCreditBlockN(method, max(v-max(prev, 0), 1))
+	}
+	b.noteCreditTransition(prev, v)
+}
+
+/*
+Close begins a graceful shutdown of this Breakwater instance:
+queueRequest immediately starts refusing new requests (acquireCredit
+returns a *DropError with Reason ShuttingDown for them), every waiter
+currently parked in acquireCreditLoop is woken with the same error, and
+Close then waits up to timeout for outstanding invoker/streamer calls
+already admitted to finish. It returns nil once the drain completes, or
+an error if timeout elapses first, so callers (tests especially) can
+tell a clean shutdown from one that had to give up with requests still
+in flight. Either way, if WithCreditStore is configured, Close persists
+the final balance before returning. Close is safe to call more than
+once; only the first call has any effect.
+*/
+func (b *Breakwater) Close(timeout time.Duration) error {
+	if !b.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	close(b.shutdownCh)
+
+	done := make(chan struct{})
+	go func() {
+		b.inFlight.Wait()
+		close(done)
 	}()
+
+	select {
+	case <-done:
+		b.saveCreditStore()
+		return nil
+	case <-time.After(timeout):
+		b.saveCreditStore()
+		return fmt.Errorf("breakwater: drain timed out after %s with requests still in flight", timeout)
+	}
+}
+
+/*
+CreditAvailability returns a read-only channel that receives true when
+the instance-wide balance (the same pool PeekCredit("") and Stats()
+report) goes from <=0 to positive, and false on the reverse transition,
+letting an external scheduler react to admission state changing instead
+of polling PeekCredit. Like Stats(), it only reflects the instance-wide
+pool, not a per-method pool from WithMethodConfig.
+
+The channel is buffered and every send is non-blocking: a slow or
+absent consumer can never stall the credit hot path, at the cost of
+only the most recent transition being guaranteed visible if several
+happen faster than the consumer drains them.
+*/
+func (b *Breakwater) CreditAvailability() <-chan bool {
+	return b.creditAvailabilityCh
+}
+
+// noteCreditTransition compares before and after's sign and, if the
+// instance-wide balance just crossed between <=0 and >0, reports the
+// new state on creditAvailabilityCh. Coalesces under backpressure by
+// dropping a stale pending value rather than blocking the caller.
+func (b *Breakwater) noteCreditTransition(before, after int64) {
+	if (before > 0) == (after > 0) {
+		return
+	}
+	avail := after > 0
+	if avail {
+		b.broadcastCreditAvailable()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case b.creditAvailabilityCh <- avail:
+			return
+		default:
+		}
+		select {
+		case <-b.creditAvailabilityCh:
+		default:
+		}
+	}
+}
+
+// creditAvailableSignal returns the channel acquireCreditLoop's
+// zero-credit retry should wait on: it's closed exactly once the next
+// time the instance-wide balance crosses from <=0 to >0, then replaced,
+// so a waiter that grabs this before re-checking its balance is
+// guaranteed to see the very next genuine increase, not just whichever
+// generic noCreditBlocker token happened to wake it.
+func (b *Breakwater) creditAvailableSignal() <-chan struct{} {
+	b.creditAvailableMu.Lock()
+	defer b.creditAvailableMu.Unlock()
+	return b.creditAvailableGen
+}
+
+// broadcastCreditAvailable wakes every waiter currently parked on
+// creditAvailableSignal's channel by closing it, then installs a fresh
+// channel for the next generation of waiters.
+func (b *Breakwater) broadcastCreditAvailable() {
+	b.creditAvailableMu.Lock()
+	defer b.creditAvailableMu.Unlock()
+	close(b.creditAvailableGen)
+	b.creditAvailableGen = make(chan struct{})
+}
+
+// PeekCredit reads method's current balance without mutating it.
+func (b *Breakwater) PeekCredit(method string) int64 {
+	if p := b.poolFor(method); p != nil {
+		return p.peek()
+	}
+	return b.outgoingCredits.Load()
+}
+
+/*
+mergeCredit folds a server-reported credit balance into method's
+balance as a single CAS-loop critical section. It replaces a prior
+PeekCredit-then-SetCredit sequence in updateCreditsFromTrailer that read
+the balance and wrote it back in two separate steps: a concurrent
+response settling at the same time could land its write in between,
+and then get silently overwritten once this call's SetCredit finally
+ran, losing an update. hasReported distinguishes a response that
+attached a "credits" trailer (reported is authoritative, floored) from
+one that didn't (the existing balance is floored in place, read fresh
+on every loop iteration instead of a stale snapshot).
+*/
+func (b *Breakwater) mergeCredit(method string, reported int64, hasReported bool, floor int64) int64 {
+	if p := b.poolFor(method); p != nil {
+		return p.merge(reported, hasReported, floor)
+	}
+	for {
+		cur := b.outgoingCredits.Load()
+		next := cur
+		if hasReported {
+			next = reported
+		}
+		if next < floor {
+			next = floor
+		}
+		if b.outgoingCredits.CompareAndSwap(cur, next) {
+			if next > cur {
+				atomic.AddUint64(&b.creditsGranted, uint64(next-cur))
+			}
+			if next > 0 {
+				// Wake as many waiters as credits that just became newly
+				// available, not just one, so a burst grant doesn't
+				// cascade through the queue one at a time.
+				b.unblockNoCreditBlockN(method, max(next-max(cur, 0), 1))
+			}
+			b.noteCreditTransition(cur, next)
+			return next
+		}
+	}
+}
+
+// mergeCreditDelta is mergeCredit's Additive-mode counterpart (see
+// CreditUpdateMode): instead of replacing method's balance with
+// reported outright, it adds delta to whatever the balance currently
+// is, in the same single CAS-loop critical section mergeCredit uses.
+func (b *Breakwater) mergeCreditDelta(method string, delta int64, floor int64) int64 {
+	if p := b.poolFor(method); p != nil {
+		return p.mergeDelta(delta, floor)
+	}
+	for {
+		cur := b.outgoingCredits.Load()
+		next := cur + delta
+		if next < floor {
+			next = floor
+		}
+		if b.outgoingCredits.CompareAndSwap(cur, next) {
+			if next > cur {
+				atomic.AddUint64(&b.creditsGranted, uint64(next-cur))
+			}
+			if next > 0 {
+				b.unblockNoCreditBlockN(method, max(next-max(cur, 0), 1))
+			}
+			b.noteCreditTransition(cur, next)
+			return next
+		}
+	}
 }
@@ -0,0 +1,68 @@
+package breakwater
+
+import "sync"
+
+/*
+CreditBroker pools idle credits across several Breakwater instances in
+the same process that all front the same backend, each via its own
+WithBroker(broker). A client split across many Breakwater instances (eg
+one per upstream caller, or one per downstream shard) otherwise has no
+way for an idle instance's spare credits to help a starved one, even
+though admitting the starved request wouldn't actually cost the shared
+backend anything extra -- the idle instance just isn't using its share.
+
+Lending only ever moves credits an instance holds beyond its own
+entitlement (initialCredits, the same baseline Reset restores), so a
+lender can never be driven below where it started; NewCreditBroker's
+zero value is ready to use, and instances without a broker at all
+behave exactly as before CreditBroker existed.
+*/
+type CreditBroker struct {
+	mu      sync.Mutex
+	members []*Breakwater
+}
+
+// NewCreditBroker returns an empty broker. Instances register with it
+// by being constructed with WithBroker(broker).
+func NewCreditBroker() *CreditBroker {
+	return &CreditBroker{}
+}
+
+func (cb *CreditBroker) register(bw *Breakwater) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.members = append(cb.members, bw)
+}
+
+/*
+Lend looks for a peer registered with cb that is sitting on credits
+beyond its own entitlement, takes one, and hands it to borrower. It
+returns true if a credit was actually moved. method selects borrower's
+pool the same way acquireCreditLoop's caller already resolved it; the
+peer's balance is read and debited from its own instance-wide pool,
+since a lent credit crossing instances has no natural per-method home
+on the lending side.
+
+Members are visited in registration order and the first one with
+spare capacity wins, so under sustained pressure lending isn't
+perfectly fair across peers -- acceptable for a work-conserving pool
+where the point is simply not leaving idle credits idle, not load
+balancing between peers.
+*/
+func (cb *CreditBroker) Lend(borrower *Breakwater, method string) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	for _, peer := range cb.members {
+		if peer == borrower {
+			continue
+		}
+		if peer.PeekCredit("") <= peer.initialCredits {
+			continue
+		}
+		if peer.TryAcquireCredit("") {
+			borrower.ReleaseCredit(method, 1)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBrokerLendsAnIdlePeersSpareCredit(t *testing.T) {
+	broker := NewCreditBroker()
+	idle := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, InitialCredits: 1}, WithBroker(broker))
+	starved := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, InitialCredits: 1}, WithBroker(broker))
+
+	// Give idle a credit beyond its entitlement, and drain starved's own
+	// balance to 0, so starved has nothing of its own to spend.
+	idle.ReleaseCredit("", 1)
+	if !starved.TryAcquireCredit("") {
+		t.Fatal("expected starved's starting credit to be available")
+	}
+
+	if !broker.Lend(starved, "") {
+		t.Fatal("expected the broker to find idle's spare credit")
+	}
+	if got := starved.PeekCredit(""); got != 1 {
+		t.Fatalf("expected starved's balance to be 1 after lending, got %d", got)
+	}
+	if got := idle.PeekCredit(""); got != 1 {
+		t.Fatalf("expected idle to be back at its entitlement, got %d", got)
+	}
+}
+
+func TestBrokerNeverLendsBelowAPeersEntitlement(t *testing.T) {
+	broker := NewCreditBroker()
+	peer := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, InitialCredits: 1}, WithBroker(broker))
+	starved := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, InitialCredits: 1}, WithBroker(broker))
+
+	if broker.Lend(starved, "") {
+		t.Fatal("expected no lend: peer is at its entitlement, not above it")
+	}
+	if got := peer.PeekCredit(""); got != 1 {
+		t.Fatalf("expected peer's balance to stay at its entitlement, got %d", got)
+	}
+}
+
+func TestWithoutBrokerBehavesExactlyAsBefore(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 0)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		result <- err
+	}()
+
+	bw.SetCredit("", 1)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiter to be admitted once credit arrived")
+	}
+}
+
+func TestAcquireCreditLoopBorrowsFromBrokerInsteadOfBackingOff(t *testing.T) {
+	broker := NewCreditBroker()
+	idle := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, InitialCredits: 1}, WithBroker(broker))
+	starved := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, InitialCredits: 1}, WithBroker(broker))
+
+	idle.ReleaseCredit("", 1)
+	if !starved.TryAcquireCredit("") {
+		t.Fatal("expected starved's starting credit to be available")
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := starved.acquireCreditLoop(context.Background(), starved.clock.Now(), "")
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected starved to borrow idle's spare credit, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected starved to be admitted via a borrowed credit")
+	}
+}
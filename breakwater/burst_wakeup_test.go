@@ -0,0 +1,68 @@
+package breakwater
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReleaseCreditWakesBurstConcurrentlyNotSerially parks waiterCount
+// goroutines on noCreditBlocker with no credit available, then releases
+// enough credit for all of them in a single call. If wakeups only ever
+// cascaded one at a time (the old cap-1 semaphore behavior), the total
+// time to drain every waiter would grow with waiterCount; waking them as
+// a burst keeps it roughly flat.
+func TestReleaseCreditWakesBurstConcurrentlyNotSerially(t *testing.T) {
+	const waiterCount = 50
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 0)
+
+	var wg sync.WaitGroup
+	started := make(chan struct{}, waiterCount)
+	done := make(chan time.Duration, waiterCount)
+
+	for i := 0; i < waiterCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started <- struct{}{}
+			start := time.Now()
+			for !bw.TryAcquireCreditN("", 1) {
+				select {
+				case <-bw.noCreditBlockerFor(""):
+				case <-time.After(time.Second):
+					t.Error("timed out waiting for a wakeup")
+					return
+				}
+			}
+			done <- time.Since(start)
+		}()
+	}
+	for i := 0; i < waiterCount; i++ {
+		<-started
+	}
+	// Give every goroutine a chance to park on noCreditBlocker before the
+	// burst release, so the release actually has waiterCount waiters to
+	// wake rather than racing ahead of some of them.
+	time.Sleep(20 * time.Millisecond)
+
+	releaseStart := time.Now()
+	bw.ReleaseCredit("", waiterCount)
+
+	var maxWake time.Duration
+	for i := 0; i < waiterCount; i++ {
+		d := <-done
+		if d > maxWake {
+			maxWake = d
+		}
+	}
+	totalDrain := time.Since(releaseStart)
+	wg.Wait()
+
+	// A one-at-a-time cascade would take on the order of waiterCount
+	// scheduler round trips; a burst wake should drain in a small,
+	// roughly constant number of them regardless of waiterCount.
+	if totalDrain > 200*time.Millisecond {
+		t.Fatalf("expected a burst release to drain %d waiters quickly, took %s", waiterCount, totalDrain)
+	}
+}
@@ -0,0 +1,39 @@
+package breakwater
+
+/*
+ChainCreditFunc lets a server factor an downstream constraint into the
+credits it is about to issue to its own caller, so pressure from a hop
+further down the mesh (A->B->C) flows back through B to A instead of
+each hop deciding purely on its own local state. clientID and demand are
+the same values UnaryInterceptor just read off the incoming request;
+localIssued is what updateCreditsToIssue computed before any chaining.
+The returned value replaces localIssued in the "credits" header sent
+back to the caller. Set via WithChainedCredit.
+*/
+type ChainCreditFunc func(clientID string, demand int64, localIssued int64) int64
+
+// WithChainedCredit installs f as this server's ChainCreditFunc; see
+// ChainCreditFunc for what it receives and how its return value is used.
+func WithChainedCredit(f ChainCreditFunc) Option {
+	return func(bw *Breakwater) {
+		bw.chainedCreditFunc = f
+	}
+}
+
+/*
+ChainDownstream builds a ChainCreditFunc that caps what this server
+issues upstream at whatever balance it's currently carrying as a client
+of downstream for method -- the credits a further-downstream hop most
+recently granted it. This is the common case: a server acting as a
+client to one tighter downstream dependency, composing the two tiers'
+credit pressure with a simple min rather than issuing upstream credits
+the server already knows it can't itself spend downstream.
+*/
+func ChainDownstream(downstream *Breakwater, method string) ChainCreditFunc {
+	return func(_ string, _ int64, localIssued int64) int64 {
+		if downstreamCredit := downstream.PeekCredit(method); downstreamCredit < localIssued {
+			return downstreamCredit
+		}
+		return localIssued
+	}
+}
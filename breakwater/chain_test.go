@@ -0,0 +1,40 @@
+package breakwater
+
+import "testing"
+
+func TestChainDownstreamCapsIssuedCreditsToTheTighterHop(t *testing.T) {
+	downstream := New(BWParametersDefault, WithInitialCredits(2))
+	chain := ChainDownstream(downstream, "")
+
+	if got := chain("client-a", 10, 50); got != 2 {
+		t.Fatalf("expected ChainDownstream to cap issued credits at downstream's balance of 2, got %d", got)
+	}
+}
+
+func TestChainDownstreamLeavesIssuedCreditsAloneWhenLocalIsTighter(t *testing.T) {
+	downstream := New(BWParametersDefault, WithInitialCredits(1000))
+	chain := ChainDownstream(downstream, "")
+
+	if got := chain("client-a", 10, 3); got != 3 {
+		t.Fatalf("expected local's tighter value of 3 to pass through unchanged, got %d", got)
+	}
+}
+
+func TestWithChainedCreditIsAppliedBeforeTheCreditsHeaderIsBuilt(t *testing.T) {
+	var sawLocal int64
+	bw := New(BWParametersDefault, WithChainedCredit(func(clientID string, demand int64, localIssued int64) int64 {
+		sawLocal = localIssued
+		return 1
+	}))
+	bw.RegisterClient("client-a", 10)
+
+	issued, _ := bw.updateCreditsToIssue("client-a", 10)
+	capped := bw.chainedCreditFunc("client-a", 10, issued)
+
+	if sawLocal != issued {
+		t.Fatalf("expected the chain func to observe the local decision (%d), got %d", issued, sawLocal)
+	}
+	if capped != 1 {
+		t.Fatalf("expected the chain func's override to win, got %d", capped)
+	}
+}
@@ -0,0 +1,31 @@
+package breakwater
+
+import "context"
+
+type cheapHintKey struct{}
+
+/*
+WithCheapHint marks ctx's request as one the caller expects to be
+trivially cheap for the server to handle, so UnaryInterceptorClient can
+release its acquired credit right after a successful response that
+comes back faster than WithCheapHintThreshold, instead of waiting on the
+next "credits" trailer (which may be several requests away under
+batched demand reporting) to replenish the balance.
+
+This is a heuristic, not a guarantee: it trades a small amount of
+over-sending for faster capacity turnover on mixed workloads where most
+requests are cheap but a few are not. A caller that marks an
+unexpectedly slow or expensive request this way gains nothing (the
+latency check still has to pass), but a caller that's simply wrong about
+which requests are cheap will cause this client to release credit for
+requests that weren't actually cheap for the server to serve -- use it
+only where the caller genuinely knows the request's cost ahead of time.
+*/
+func WithCheapHint(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cheapHintKey{}, true)
+}
+
+func cheapHintFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(cheapHintKey{}).(bool)
+	return v
+}
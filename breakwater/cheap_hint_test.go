@@ -0,0 +1,86 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestCheapHintFromContextDefaultsFalse(t *testing.T) {
+	if cheapHintFromContext(context.Background()) {
+		t.Fatal("expected a plain context to not carry a cheap hint")
+	}
+}
+
+func TestWithCheapHintMarksTheContext(t *testing.T) {
+	if !cheapHintFromContext(WithCheapHint(context.Background())) {
+		t.Fatal("expected WithCheapHint to mark the context")
+	}
+}
+
+func TestUnaryInterceptorClientReleasesCreditOnFastCheapHintResponse(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithCheapHintThreshold(time.Millisecond))
+	bw.SetCredit("", 5)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		clock.Advance(10 * time.Microsecond) // well under the 1ms threshold
+		return nil
+	}
+
+	ctx := WithCheapHint(context.Background())
+	if err := bw.UnaryInterceptorClient(ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := bw.PeekCredit(""); got != 5 {
+		t.Fatalf("expected the credit released back immediately, got balance %d", got)
+	}
+	if got := bw.Stats().CheapHintReleases; got != 1 {
+		t.Fatalf("expected CheapHintReleases to be 1, got %d", got)
+	}
+}
+
+func TestUnaryInterceptorClientSkipsReleaseWhenResponseIsSlow(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithCheapHintThreshold(time.Millisecond))
+	bw.SetCredit("", 5)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		clock.Advance(5 * time.Millisecond) // beyond the 1ms threshold
+		return nil
+	}
+
+	ctx := WithCheapHint(context.Background())
+	if err := bw.UnaryInterceptorClient(ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := bw.PeekCredit(""); got != 4 {
+		t.Fatalf("expected the credit to stay spent on a slow response, got balance %d", got)
+	}
+	if got := bw.Stats().CheapHintReleases; got != 0 {
+		t.Fatalf("expected CheapHintReleases to stay 0, got %d", got)
+	}
+}
+
+func TestUnaryInterceptorClientIgnoresFastResponseWithoutCheapHint(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithCheapHintThreshold(time.Millisecond))
+	bw.SetCredit("", 5)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		clock.Advance(10 * time.Microsecond)
+		return nil
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := bw.PeekCredit(""); got != 4 {
+		t.Fatalf("expected no proactive release without WithCheapHint, got balance %d", got)
+	}
+}
@@ -0,0 +1,155 @@
+package breakwater
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+/*
+CircuitState is WithCircuitBreaker's state machine position, exposed via
+Stats() so a dashboard can show when acquireCreditLoop escalated from
+shedding individual requests to failing every one of them fast.
+
+  - CircuitClosed: the breaker isn't intervening; requests flow through
+    the normal queue/credit admission path exactly as if it didn't exist.
+  - CircuitBreakerOpen: the drop rate stayed at or above threshold for
+    window, so every request is failed fast with the CircuitOpen
+    DropReason, without ever touching the queue, until cooldown elapses.
+  - CircuitHalfOpen: cooldown has elapsed; a single trickle request is
+    let through to probe whether the backend recovered, while every
+    other request arriving in the meantime keeps failing fast. The
+    probe's outcome decides whether the breaker closes again or reopens.
+*/
+type CircuitState int32
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitBreakerOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitBreakerOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	default:
+		return "Closed"
+	}
+}
+
+/*
+circuitBreaker backs WithCircuitBreaker; see CircuitState for the state
+machine it drives. requests/drops/windowStart track the rolling drop
+rate the same way Breakwater.windowRequests/windowDrops/overloadWindowStart
+do for OverloadLevel, just against their own threshold and window instead
+of sharing that one -- the two features escalate at different severities
+and shouldn't have to agree on a single window to do it.
+*/
+type circuitBreaker struct {
+	threshold float64
+	window    time.Duration
+	cooldown  time.Duration
+
+	state       atomic.Int32 // CircuitState
+	windowStart atomic.Int64 // UnixNano
+	// windowStartSet guards windowStart's first write: UnixNano() can
+	// legitimately be 0 (eg a test's manualClock starting at
+	// time.Unix(0, 0)), so windowStart==0 can't double as "uninitialized"
+	// the way a handful of other UnixNano fields in this package use it.
+	windowStartSet atomic.Bool
+	requests       atomic.Int64
+	drops          atomic.Int64
+	openedAt       atomic.Int64 // UnixNano, when the breaker most recently tripped open
+	// probing gates HalfOpen's single trickle request: CompareAndSwap(false,
+	// true) claims the probe slot, and recordResult releases it once that
+	// probe's outcome is known.
+	probing atomic.Bool
+}
+
+func newCircuitBreaker(threshold float64, window, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, window: window, cooldown: cooldown}
+}
+
+// rollWindow resets requests/drops once window has elapsed since they
+// were last reset, mirroring Breakwater.rollOverloadWindow.
+func (cb *circuitBreaker) rollWindow(nowNano int64) {
+	if cb.windowStartSet.CompareAndSwap(false, true) {
+		cb.windowStart.Store(nowNano)
+		return
+	}
+	start := cb.windowStart.Load()
+	if time.Duration(nowNano-start) < cb.window {
+		return
+	}
+	if cb.windowStart.CompareAndSwap(start, nowNano) {
+		cb.requests.Store(0)
+		cb.drops.Store(0)
+	}
+}
+
+// State reports the breaker's current position, for Stats().
+func (cb *circuitBreaker) State() CircuitState {
+	return CircuitState(cb.state.Load())
+}
+
+/*
+allow reports whether a request arriving right now should proceed to the
+normal queue/credit path at all. Open fails fast until cooldown elapses,
+at which point it flips itself to HalfOpen and re-evaluates as one; Closed
+and a just-claimed HalfOpen probe slot are the two "proceed" outcomes
+recordResult later expects a matching call for.
+*/
+func (cb *circuitBreaker) allow(now time.Time) bool {
+	switch cb.State() {
+	case CircuitBreakerOpen:
+		nowNano := now.UnixNano()
+		if time.Duration(nowNano-cb.openedAt.Load()) < cb.cooldown {
+			return false
+		}
+		cb.state.CompareAndSwap(int32(CircuitBreakerOpen), int32(CircuitHalfOpen))
+		return cb.allow(now)
+	case CircuitHalfOpen:
+		return cb.probing.CompareAndSwap(false, true)
+	default:
+		return true
+	}
+}
+
+/*
+recordResult folds one admission outcome into the breaker, for every
+call allow() let proceed (and only those -- a call allow() itself
+rejected must not call this). From HalfOpen it's always the one
+outstanding probe's result: a drop reopens the breaker (the backend is
+still unhealthy), a success closes it and resets the window. From Closed
+it's folded into the rolling window, tripping the breaker open the
+moment the drop rate reaches threshold.
+*/
+func (cb *circuitBreaker) recordResult(dropped bool, now time.Time) {
+	nowNano := now.UnixNano()
+
+	if cb.State() == CircuitHalfOpen {
+		cb.probing.Store(false)
+		if dropped {
+			cb.openedAt.Store(nowNano)
+			cb.state.Store(int32(CircuitBreakerOpen))
+		} else {
+			cb.requests.Store(0)
+			cb.drops.Store(0)
+			cb.state.Store(int32(CircuitClosed))
+		}
+		return
+	}
+
+	cb.rollWindow(nowNano)
+	requests := cb.requests.Add(1)
+	drops := cb.drops.Load()
+	if dropped {
+		drops = cb.drops.Add(1)
+	}
+	if requests > 0 && float64(drops)/float64(requests) >= cb.threshold {
+		cb.openedAt.Store(nowNano)
+		cb.state.Store(int32(CircuitBreakerOpen))
+	}
+}
@@ -0,0 +1,195 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStaysClosedBelowThreshold(t *testing.T) {
+	cb := newCircuitBreaker(0.5, time.Minute, time.Minute)
+	now := time.Unix(0, 0)
+
+	for i := 0; i < 10; i++ {
+		if !cb.allow(now) {
+			t.Fatalf("expected a closed breaker to allow request %d", i)
+		}
+		cb.recordResult(i == 9, now) // 1 drop out of 10, under the 50% threshold at every step
+	}
+	if got := cb.State(); got != CircuitClosed {
+		t.Fatalf("expected CircuitClosed, got %v", got)
+	}
+}
+
+func TestCircuitBreakerTripsOpenAtThreshold(t *testing.T) {
+	cb := newCircuitBreaker(0.5, time.Minute, time.Minute)
+	now := time.Unix(0, 0)
+
+	for i := 0; i < 4; i++ {
+		cb.allow(now)
+		cb.recordResult(true, now)
+	}
+	if got := cb.State(); got != CircuitBreakerOpen {
+		t.Fatalf("expected the breaker to trip open once the drop rate reaches threshold, got %v", got)
+	}
+}
+
+func TestCircuitBreakerFailsFastWithoutCoolingDown(t *testing.T) {
+	cb := newCircuitBreaker(0.5, time.Minute, time.Minute)
+	now := time.Unix(0, 0)
+	cb.allow(now)
+	cb.recordResult(true, now)
+
+	if cb.allow(now.Add(time.Second)) {
+		t.Fatal("expected the open breaker to keep rejecting before cooldown elapses")
+	}
+}
+
+func TestCircuitBreakerHalfOpensAfterCooldownAndAdmitsOneProbe(t *testing.T) {
+	cb := newCircuitBreaker(0.5, time.Minute, time.Minute)
+	now := time.Unix(0, 0)
+	cb.allow(now)
+	cb.recordResult(true, now)
+
+	probeTime := now.Add(2 * time.Minute)
+	if !cb.allow(probeTime) {
+		t.Fatal("expected the breaker to admit exactly one probe after cooldown")
+	}
+	if got := cb.State(); got != CircuitHalfOpen {
+		t.Fatalf("expected CircuitHalfOpen, got %v", got)
+	}
+	if cb.allow(probeTime) {
+		t.Fatal("expected a second concurrent request to keep failing fast while a probe is outstanding")
+	}
+}
+
+func TestCircuitBreakerClosesOnSuccessfulProbe(t *testing.T) {
+	cb := newCircuitBreaker(0.5, time.Minute, time.Minute)
+	now := time.Unix(0, 0)
+	cb.allow(now)
+	cb.recordResult(true, now)
+
+	probeTime := now.Add(2 * time.Minute)
+	cb.allow(probeTime)
+	cb.recordResult(false, probeTime)
+
+	if got := cb.State(); got != CircuitClosed {
+		t.Fatalf("expected a successful probe to close the breaker, got %v", got)
+	}
+	if !cb.allow(probeTime) {
+		t.Fatal("expected a closed breaker to resume admitting normally")
+	}
+}
+
+func TestCircuitBreakerReopensOnFailedProbe(t *testing.T) {
+	cb := newCircuitBreaker(0.5, time.Minute, time.Minute)
+	now := time.Unix(0, 0)
+	cb.allow(now)
+	cb.recordResult(true, now)
+
+	probeTime := now.Add(2 * time.Minute)
+	cb.allow(probeTime)
+	cb.recordResult(true, probeTime)
+
+	if got := cb.State(); got != CircuitBreakerOpen {
+		t.Fatalf("expected a failed probe to reopen the breaker, got %v", got)
+	}
+	if cb.allow(probeTime) {
+		t.Fatal("expected the reopened breaker to keep rejecting before its own cooldown elapses")
+	}
+}
+
+// acquireWithDeadline drops a zero-credit request quickly instead of
+// parking on noCreditBlocker forever, the same way
+// credit_available_signal_test.go's
+// TestAcquireCreditLoopDoesNotSpinUnderGenericReSignaling bounds its own
+// wait.
+func acquireWithDeadline(t *testing.T, bw *Breakwater, timeStart time.Time) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err := bw.acquireCreditLoop(ctx, timeStart, "")
+	return err
+}
+
+func TestWithCircuitBreakerFailsFastWithoutQueueing(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithCircuitBreaker(0.5, time.Minute, time.Minute))
+	bw.SetCredit("", 0)
+
+	for i := 0; i < 4; i++ {
+		if err := acquireWithDeadline(t, bw, clock.Now()); err == nil {
+			t.Fatalf("expected request %d to be dropped for lack of credit", i)
+		}
+	}
+
+	queueLenBefore := len(bw.pendingOutgoing)
+	err := acquireWithDeadline(t, bw, clock.Now())
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError once the breaker trips open, got %v", err)
+	}
+	if de.Reason != CircuitOpen {
+		t.Fatalf("expected reason CircuitOpen, got %v", de.Reason)
+	}
+	if got := len(bw.pendingOutgoing); got != queueLenBefore {
+		t.Fatalf("expected an open breaker to never touch the queue, got length %d", got)
+	}
+}
+
+func TestWithCircuitBreakerRecoversAfterCooldown(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithCircuitBreaker(0.5, time.Minute, time.Minute))
+	bw.SetCredit("", 0)
+
+	for i := 0; i < 4; i++ {
+		acquireWithDeadline(t, bw, clock.Now())
+	}
+	if got := bw.circuitBreaker.State(); got != CircuitBreakerOpen {
+		t.Fatalf("expected the breaker to be open, got %v", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	bw.SetCredit("", 5)
+	if _, err := bw.acquireCreditLoop(context.Background(), clock.Now(), ""); err != nil {
+		t.Fatalf("expected the probe to be admitted and succeed, got %v", err)
+	}
+	if got := bw.circuitBreaker.State(); got != CircuitClosed {
+		t.Fatalf("expected a successful probe to close the breaker, got %v", got)
+	}
+}
+
+func TestStatsReportsCircuitState(t *testing.T) {
+	bw := New(BWParametersDefault)
+	if got := bw.Stats().CircuitState; got != CircuitClosed {
+		t.Fatalf("expected CircuitClosed when WithCircuitBreaker was never set, got %v", got)
+	}
+
+	clock := &manualClock{now: time.Unix(0, 0)}
+	tripped := New(BWParametersDefault, WithClock(clock), WithCircuitBreaker(0.5, time.Minute, time.Minute))
+	tripped.SetCredit("", 0)
+	for i := 0; i < 4; i++ {
+		acquireWithDeadline(t, tripped, clock.Now())
+	}
+	if got := tripped.Stats().CircuitState; got != CircuitBreakerOpen {
+		t.Fatalf("expected Stats().CircuitState to report the tripped breaker, got %v", got)
+	}
+}
+
+func TestWithCircuitBreakerRejectsInvalidParameters(t *testing.T) {
+	bw := New(BWParametersDefault, WithCircuitBreaker(0, time.Minute, time.Minute))
+	if bw.circuitBreaker != nil {
+		t.Fatal("expected a non-positive threshold to leave the breaker unset")
+	}
+
+	bw = New(BWParametersDefault, WithCircuitBreaker(0.5, 0, time.Minute))
+	if bw.circuitBreaker != nil {
+		t.Fatal("expected a non-positive window to leave the breaker unset")
+	}
+
+	bw = New(BWParametersDefault, WithCircuitBreaker(0.5, time.Minute, 0))
+	if bw.circuitBreaker != nil {
+		t.Fatal("expected a non-positive cooldown to leave the breaker unset")
+	}
+}
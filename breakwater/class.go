@@ -0,0 +1,53 @@
+package breakwater
+
+import "context"
+
+type classCtxKey struct{}
+
+// defaultClassName is the pool WithCreditClasses' untagged requests, and
+// any request tagged with a name WithCreditClasses never configured a
+// share for, fall into.
+const defaultClassName = "default"
+
+// classKeyPrefix namespaces class pool keys so they can never collide
+// with a gRPC method path, which always starts with "/".
+const classKeyPrefix = "\x1fclass\x1f"
+
+func classPoolKey(name string) string {
+	return classKeyPrefix + name
+}
+
+/*
+WithClass tags ctx with a workload class name, so the client
+interceptors draw credit and queue capacity from that class's own pool
+-- set up via WithCreditClasses -- instead of the instance-wide or
+per-method one. Has no effect unless WithCreditClasses is also
+configured; a name WithCreditClasses never gave a share to falls back to
+the default class, the same as not calling WithClass at all.
+*/
+func WithClass(ctx context.Context, className string) context.Context {
+	return context.WithValue(ctx, classCtxKey{}, className)
+}
+
+// classFromContext returns the class name attached by WithClass, and
+// whether one was present at all.
+func classFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(classCtxKey{}).(string)
+	return name, ok && name != ""
+}
+
+// ClassCredit returns className's current outgoing credit balance, the
+// same way PeekCredit does for a method. Reads the default class's
+// balance when className has no configured share.
+func (b *Breakwater) ClassCredit(className string) int64 {
+	return b.PeekCredit(classPoolKey(b.resolveClass(className)))
+}
+
+// resolveClass returns name if WithCreditClasses configured a share for
+// it, or defaultClassName otherwise.
+func (b *Breakwater) resolveClass(name string) string {
+	if _, ok := b.methodConfigs[classPoolKey(name)]; ok {
+		return name
+	}
+	return defaultClassName
+}
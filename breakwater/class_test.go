@@ -0,0 +1,57 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithCreditClassesSplitsBalanceByShare(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithInitialCredits(10),
+		WithCreditClasses(map[string]float64{"interactive": 0.7, "batch": 0.3}),
+	)
+
+	if got := bw.ClassCredit("interactive"); got != 7 {
+		t.Fatalf("expected interactive's pool to start with 7 credits, got %d", got)
+	}
+	if got := bw.ClassCredit("batch"); got != 3 {
+		t.Fatalf("expected batch's pool to start with 3 credits, got %d", got)
+	}
+}
+
+func TestWithCreditClassesUntaggedFallsBackToDefault(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithInitialCredits(10),
+		WithCreditClasses(map[string]float64{"interactive": 0.7, "batch": 0.3}),
+	)
+
+	if got := bw.ClassCredit("default"); got != 1 {
+		t.Fatalf("expected the default class to get the 1-credit floor of its leftover 0%% share, got %d", got)
+	}
+	if got := bw.ClassCredit("unknown-class"); got != 1 {
+		t.Fatalf("expected an unconfigured class name to fall back to the default class, got %d", got)
+	}
+}
+
+func TestWithCreditClassesIsolatesQueuesBetweenClasses(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithInitialCredits(10),
+		WithCreditClasses(map[string]float64{"interactive": 0.7, "batch": 0.3}),
+	)
+	bw.SetCredit(classPoolKey("batch"), 0)
+
+	batchCtx, cancel := context.WithTimeout(WithClass(context.Background(), "batch"), 10*time.Millisecond)
+	defer cancel()
+	batchKey := bw.poolKey(batchCtx, "/svc/Batch")
+	if _, err := bw.acquireCreditLoop(batchCtx, bw.clock.Now(), batchKey); err == nil {
+		t.Fatal("expected batch's own empty pool to drop the request")
+	}
+
+	// interactive's pool is untouched by batch being starved.
+	interactiveCtx := WithClass(context.Background(), "interactive")
+	interactiveKey := bw.poolKey(interactiveCtx, "/svc/Interactive")
+	if _, err := bw.acquireCreditLoop(interactiveCtx, bw.clock.Now(), interactiveKey); err != nil {
+		t.Fatalf("expected interactive's pool to be unaffected by batch's drop, got %v", err)
+	}
+}
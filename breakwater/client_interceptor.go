@@ -2,7 +2,13 @@ package breakwater
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
@@ -11,21 +17,47 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-/*
-Helper to get current demand (not exact due to race conditions, but gives a
-fairly precise idea of number of outgoing requests in queue)
-*/
-func (b *Breakwater) getDemand() (demand int) {
-	return len(b.pendingOutgoing)
+// errAdmitRateExpired is awaitAdmitRate's sentinel for "the request's
+// own expiration budget ran out while waiting on admitRateLimiter",
+// distinct from ctx.Err()/context.DeadlineExceeded so acquireCreditLoop
+// can route all three to the same Expired drop path without caring
+// which one it saw.
+var errAdmitRateExpired = errors.New("breakwater: admit rate limiter wait exceeded expiration")
+
+// Demand returns the exact number of requests currently queued for
+// method (or the instance-wide queue when method has no override pool;
+// see WithMethodConfig), backed by an atomic.Int64 maintained alongside
+// pendingOutgoing by queueRequest/dequeueRequest. Unlike len(channel),
+// it cannot race, so it's safe for the server's overcommitment math as
+// well as dashboards.
+func (b *Breakwater) Demand(method string) int {
+	if p := b.poolFor(method); p != nil {
+		return p.demand()
+	}
+	return int(b.demandCount.Load())
+}
+
+// getDemand is a thin, unexported wrapper kept for the existing call
+// sites in this package; prefer Demand for anything outside it.
+func (b *Breakwater) getDemand(method string) (demand int) {
+	return b.Demand(method)
 }
 
 /*
-Adds request to the outgoing queue, returns false
-and drops request if there are > 50 elements in channel
+Adds request to method's outgoing queue, returns false and drops the
+request if the queue is already at capacity.
 */
-func (b *Breakwater) queueRequest() bool {
+func (b *Breakwater) queueRequest(method string) bool {
+	if b.closed.Load() {
+		return false
+	}
+	if p := b.poolFor(method); p != nil {
+		return p.queue()
+	}
 	select {
 	case b.pendingOutgoing <- 1:
+		b.demandCount.Add(1)
+		b.demandIdleSince.Store(0)
 		return true
 	default:
 		return false
@@ -33,12 +65,72 @@ func (b *Breakwater) queueRequest() bool {
 }
 
 /*
-Dequeues request to the outgoing queue,
+waitToQueue retries queueRequest for a wait-for-ready call that found
+method's queue full, instead of the fail-fast drop useClientQueueLength
+would otherwise apply. Returns true once a slot opens up, or false if
+ctx is cancelled or this instance starts shutting down first -- either
+of which the caller handles exactly like an ordinary queueRequest
+failure.
+*/
+func (b *Breakwater) waitToQueue(ctx context.Context, method string) bool {
+	ticker := time.NewTicker(waitForReadyQueuePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-b.shutdownCh:
+			return false
+		case <-ticker.C:
+			if b.queueRequest(method) {
+				return true
+			}
+		}
+	}
+}
+
+// waitToQueueWithTimeout backs QueueFullPolicy's BlockWithTimeout: the
+// same poll loop as waitToQueue, but bounded by timeout instead of
+// running until ctx is done, so a request that opted into blocking on a
+// full queue still gives up and falls back to Reject's drop rather than
+// potentially waiting as long as ctx allows.
+func (b *Breakwater) waitToQueueWithTimeout(ctx context.Context, method string, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return false
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(waitForReadyQueuePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-b.shutdownCh:
+			return false
+		case <-deadline.C:
+			return false
+		case <-ticker.C:
+			if b.queueRequest(method) {
+				return true
+			}
+		}
+	}
+}
+
+/*
+Dequeues request from method's outgoing queue,
 returns false if queue channel is empty
 */
-func (b *Breakwater) dequeueRequest() bool {
+func (b *Breakwater) dequeueRequest(method string) bool {
+	if p := b.poolFor(method); p != nil {
+		return p.dequeue()
+	}
 	select {
 	case <-b.pendingOutgoing:
+		if b.demandCount.Add(-1) <= 0 {
+			b.demandIdleSince.CompareAndSwap(0, time.Now().UnixNano())
+		}
 		return true
 	default:
 		return false
@@ -46,26 +138,266 @@ func (b *Breakwater) dequeueRequest() bool {
 }
 
 /*
-Unblocks blockingCreditQueue
+Unblocks method's no-credit blocker.
 */
-func (b *Breakwater) unblockNoCreditBlock() {
-	select {
-	case b.noCreditBlocker <- 1:
-		return
-	default:
+// unblockNoCreditBlock wakes one waiter parked on method's noCreditBlocker.
+func (b *Breakwater) unblockNoCreditBlock(method string) {
+	b.unblockNoCreditBlockN(method, 1)
+}
+
+// unblockNoCreditBlockN wakes up to n waiters parked on method's
+// noCreditBlocker at once, for an update that just made n credits newly
+// available instead of only one -- eg a server-reported balance jump
+// covering a burst of waiters. See unblockChan for why it's safe to
+// request more than the number of waiters actually parked.
+func (b *Breakwater) unblockNoCreditBlockN(method string, n int64) {
+	if p := b.poolFor(method); p != nil {
+		p.unblockN(n)
 		return
 	}
+	unblockChan(b.noCreditBlocker, n)
 }
 
-func (b *Breakwater) UnaryInterceptorClient(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+/*
+waitForTurn blocks until ticket is the one currently being served, when
+fifo is enabled; it is a no-op otherwise. Waiters register a channel in
+ticketWaiters before checking servingTicket, so a concurrent advanceTicket
+can't advance past them between the check and the wait: either
+servingTicket already matches by the time we check (advanceTicket ran
+first), or we're still in the map to be woken (advanceTicket runs after).
+Ticket ordering is instance-wide, not per-method: it only orders the
+attempt, not which pool a request then draws a credit from.
+*/
+func (b *Breakwater) waitForTurn(ctx context.Context, ticket int64) error {
+	if !b.fifo {
+		return nil
+	}
+
+	ch := make(chan struct{})
+	b.ticketWaiters.Store(ticket, ch)
+	defer b.ticketWaiters.Delete(ticket)
+
+	if b.servingTicket.Load() == ticket {
+		return nil
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	// retrieve price table for downstream clients queueing delay
-	timeStart := time.Now()
+/*
+advanceTicket lets the next queued ticket attempt credit acquisition. It
+is deferred by acquireCredit whenever fifo is enabled, so the next ticket
+is only released once the current one has fully finished its attempt
+(success, drop, or cancellation).
+*/
+func (b *Breakwater) advanceTicket() {
+	next := b.servingTicket.Add(1)
+	if ch, ok := b.ticketWaiters.LoadAndDelete(next); ok {
+		close(ch.(chan struct{}))
+	}
+}
+
+/*
+acquireCredit queues the request against method's pool and blocks until
+a credit is available, mirroring the wait loop previously inlined in
+UnaryInterceptorClient. method selects which credit pool and queue to
+use: its own, if WithMethodConfig gave it one, otherwise the
+instance-wide pool shared by every other method. On success it returns
+the demand snapshot (scoped to that same pool) to attach to outgoing
+metadata. On failure (queue too long, or request expired in queue) it
+returns a DropError and the caller should return immediately without
+dequeueing again.
+*/
+func (b *Breakwater) acquireCredit(ctx context.Context, timeStart time.Time, method string) (demand int, err error) {
+	spanCtx, span := b.startQueueWaitSpan(ctx, method)
+	demand, err = b.acquireCreditLoop(spanCtx, timeStart, method)
+	queueWaitUs := elapsedMicros(b.clock.Now(), timeStart)
+	if err != nil {
+		reason := Cancelled
+		if de, ok := err.(*DropError); ok {
+			reason = de.Reason
+		}
+		endQueueWaitSpanOnDrop(span, reason, queueWaitUs)
+		return demand, err
+	}
+	endQueueWaitSpanOnAdmit(span, demand, b.PeekCredit(method), queueWaitUs)
+	b.waitLatency.record(queueWaitUs)
+	return demand, nil
+}
+
+/*
+fastPathEligible reports whether this instance's configuration allows
+acquireCreditLoop's uncontended fast path at all. fifo ordering, admit-
+rate pacing, and a non-default AdmissionPolicy all rely on bookkeeping
+the fast path deliberately skips, so any of them disables it entirely
+rather than risk silently overriding policy the caller opted into; an
+instance that never touches those stays fully eligible.
+*/
+func (b *Breakwater) fastPathEligible() bool {
+	if b.fifo || b.admitRateLimiter != nil {
+		return false
+	}
+	_, defaultPolicy := b.admissionPolicy.(CreditPolicy)
+	return defaultPolicy
+}
+
+/*
+tryFastPathAdmit is acquireCreditLoop's uncontended admission check: when
+method's pool has nothing else queued, ctx isn't already past its
+deadline, and this instance's configuration allows it (see
+fastPathEligible), it claims method's queue slot and takes cost credits
+with a single atomic CAS, skipping noCreditBlockerFor's select wait and
+every priority/dropOldest/stall registration the slow path below
+maintains purely to order waiters against each other -- there are none
+to order against here. queueRequest/dequeueRequest are still used (and
+still cheap: an uncontended buffered channel send/receive) so
+useClientQueueLength's queue-full accounting behaves identically whether
+a request takes this path or the slow one. ok is false whenever the fast
+path doesn't apply -- contention, a full queue, a low balance, an
+already-expired ctx, or an incompatible feature enabled -- and the
+caller must fall through to the slow path, exactly as if this check had
+never run.
+*/
+func (b *Breakwater) tryFastPathAdmit(ctx context.Context, method string, cost int64) (demand int, ok bool) {
+	if b.closed.Load() || !b.fastPathEligible() {
+		return 0, false
+	}
+	if deadline, deadlineSet := ctx.Deadline(); deadlineSet && !b.clock.Now().Before(deadline) {
+		return 0, false
+	}
+	if b.Demand(method) != 0 {
+		return 0, false
+	}
+	if !b.queueRequest(method) {
+		return 0, false
+	}
+	if !b.TryAcquireCreditN(method, cost) {
+		b.dequeueRequest(method)
+		return 0, false
+	}
+	b.dequeueRequest(method)
+	return b.Demand(method), true
+}
+
+/*
+acquireCreditLoop does the actual queueing and blocking wait for a
+credit that acquireCredit wraps with a breakwater.queue_wait span.
+*/
+func (b *Breakwater) acquireCreditLoop(ctx context.Context, timeStart time.Time, method string) (demand int, err error) {
+	b.windowRequests.Add(1)
+	cost := costFromContext(ctx)
+
+	// WithCircuitBreaker: Open fails every request fast without ever
+	// queuing it; HalfOpen lets exactly one trickle probe through to the
+	// rest of this function and fails fast for anything else arriving
+	// meanwhile. Closed (the default, nil breaker) never intervenes here.
+	// A request let through -- Closed, or the lone HalfOpen probe -- has
+	// its eventual outcome folded back into the breaker via
+	// recordResult, deferred so it runs no matter which of this
+	// function's many return paths below actually fires.
+	if b.circuitBreaker != nil {
+		if !b.circuitBreaker.allow(b.clock.Now()) {
+			if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(CircuitOpen, waitedUs, &b.droppedCount) {
+				return 0, &DropError{
+					Reason:   CircuitOpen,
+					Code:     b.dropCode(CircuitOpen, codes.Unavailable),
+					Message:  fmt.Sprintf("Client id %s: circuit breaker open, request dropped without queuing.", b.idStr),
+					ClientID: b.idStr,
+					WaitedUs: waitedUs,
+				}
+			}
+			return b.admitWithoutQueueSlot(method)
+		}
+		defer func() {
+			b.circuitBreaker.recordResult(err != nil, b.clock.Now())
+		}()
+	}
+
+	// Uncontended fast path: when nothing is already queued against
+	// method's pool, a single atomic CAS on the balance is the whole
+	// admission decision, so take it and return before touching
+	// queueRequest, noCreditBlockerFor, or any of the per-waiter
+	// registries the slow path below maintains purely for ordering
+	// waiters against each other. See tryFastPathAdmit.
+	if demand, ok := b.tryFastPathAdmit(ctx, method, cost); ok {
+		return demand, nil
+	}
+
+	if b.fifo {
+		ticket := b.ticketCounter.Add(1) - 1
+		defer b.advanceTicket()
+		if werr := b.waitForTurn(ctx, ticket); werr != nil {
+			if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(Cancelled, waitedUs, nil) {
+				return 0, b.dropErrorFromContext(Cancelled, waitedUs, werr)
+			}
+		}
+	}
+
+	// Close stops admission unconditionally, regardless of
+	// useClientQueueLength: a shut-down instance must never let a new
+	// request reach the wait loop below, where it would otherwise only
+	// be woken by shutdownCh, ctx.Done(), or a credit becoming available.
+	if b.closed.Load() {
+		if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(ShuttingDown, waitedUs, &b.droppedCount) {
+			return 0, &DropError{
+				Reason:   ShuttingDown,
+				Code:     b.dropCode(ShuttingDown, codes.Unavailable),
+				Message:  fmt.Sprintf("Client id %s is shutting down, request dropped.", b.idStr),
+				ClientID: b.idStr,
+				WaitedUs: waitedUs,
+			}
+		}
+		// Shadow mode: no queue slot was ever taken for this request, so
+		// admit it directly instead of falling into the wait loop below,
+		// which would otherwise wait on a real credit that may never come.
+		return b.admitWithoutQueueSlot(method)
+	}
 
 	// Check if queue is too long
-	var added bool = b.queueRequest()
-	if useClientQueueLength && !added {
-		return status.Errorf(codes.ResourceExhausted, "Client queue too long, request dropped at client %s", b.id.String())
+	var added bool = b.queueRequest(method)
+	if !added && waitForReadyFromContext(ctx) {
+		// The caller opted into gRPC's own wait-for-ready contract, so a
+		// momentarily full queue isn't grounds to drop; keep retrying
+		// queueRequest instead of failing fast. added stays false (and we
+		// fall straight into the useClientQueueLength drop below, same as
+		// any other caller) if ctx is done or we're shutting down first.
+		added = b.waitToQueue(ctx, method)
+	}
+	if !added {
+		switch b.queueFullPolicy {
+		case BlockWithTimeout:
+			added = b.waitToQueueWithTimeout(ctx, method, b.queueFullBlockTimeout)
+		case DropOldest:
+			// Evict whoever has been parked longest to free our slot,
+			// rather than rejecting this new arrival; the evicted waiter
+			// notices via its queueTicket and drops itself (see the
+			// ticket.dropped case in the wait loop below). If there was
+			// nothing to evict (queue briefly emptied on its own), added
+			// stays false and falls into Reject's drop like any other
+			// policy.
+			if b.dropOldestQueueFor(method).evictOldest() {
+				added = b.waitToQueue(ctx, method)
+			}
+		}
+	}
+	if b.useClientQueueLength && !added {
+		if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(QueueFull, waitedUs, &b.droppedCount) {
+			return 0, &DropError{
+				Reason:   QueueFull,
+				Code:     b.dropCode(QueueFull, codes.ResourceExhausted),
+				Message:  fmt.Sprintf("Client queue too long, request dropped at client %s", b.idStr),
+				ClientID: b.idStr,
+				WaitedUs: waitedUs,
+			}
+		}
+		// Shadow mode: same as the closed.Load() case above, added is
+		// false so there is no queue slot to wait behind; admit directly.
+		return b.admitWithoutQueueSlot(method)
 	}
 
 	// A note on non-deterministic channel waiting:
@@ -73,94 +405,1054 @@ func (b *Breakwater) UnaryInterceptorClient(ctx context.Context, method string,
 	// Current implementations use FIFO queues:
 	// https://stackoverflow.com/questions/25860633/order-of-goroutine-unblocking-on-single-channel
 
+	// stallTimer watches for this waiter being parked on noCreditBlocker
+	// past WithStallTimeout's budget; nil (the default, stallTimeout<=0)
+	// means stallCh below is always nil, so that select case never fires
+	// and this adds nothing for users who haven't opted in.
+	var stallTimer *time.Timer
+	if b.stallTimeout > 0 {
+		stallTimer = time.NewTimer(b.stallTimeout)
+		defer stallTimer.Stop()
+	}
+
+	// creditWaitTimer backs WithCreditWaitTimeout: a one-shot, normally
+	// shorter deadline than clientExpiration, specifically on the
+	// noCreditBlocker wait. nil (the default, creditWaitTimeout<=0) means
+	// creditWaitCh below is always nil, so that select case never fires.
+	var creditWaitTimer *time.Timer
+	if b.creditWaitTimeout > 0 {
+		creditWaitTimer = time.NewTimer(b.creditWaitTimeout)
+		defer creditWaitTimer.Stop()
+	}
+
+	// priorityTicket tracks this waiter for priorityQueueFor(method), so
+	// that every other waiter on the same pool can check whether it
+	// should yield a just-received token back to us; see the
+	// hasHigherPriority check below and priority_queue.go.
+	priorityQueue := b.priorityQueueFor(method)
+	priorityTicket := priorityQueue.register(priorityFromContext(ctx))
+	defer priorityQueue.unregister(priorityTicket)
+
+	// queueTicket registers this waiter's slot with dropOldestQueueFor so
+	// QueueFullPolicy's DropOldest can evict it to make room for a later
+	// arrival; harmless bookkeeping when DropOldest is never in effect,
+	// since nothing ever calls evictOldest in that case. See the
+	// dropTicket.dropped case below and queue_full_policy.go.
+	dropOldestQ := b.dropOldestQueueFor(method)
+	dropTicket := dropOldestQ.register()
+	defer dropOldestQ.unregister(dropTicket)
+
+	zeroCreditStreak := 0
 	for {
 		// Unblock if credits are available
-		logger("[Waiting in queue]:	Checking if unblock available\n")
-		// blocks until credit available
-		<-b.noCreditBlocker
+		b.logDebug("checking if unblock available")
+		var stallCh <-chan time.Time
+		if stallTimer != nil {
+			stallCh = stallTimer.C
+		}
+		var creditWaitCh <-chan time.Time
+		if creditWaitTimer != nil {
+			creditWaitCh = creditWaitTimer.C
+		}
+		// blocks until credit available, or the caller gives up on us
+		select {
+		case <-b.noCreditBlockerFor(method):
+		case <-dropTicket.dropped:
+			// QueueFullPolicy's DropOldest evicted us to free our slot for
+			// a newer arrival. We never took the noCreditBlocker token, so
+			// there is nothing to hand back there; just dequeue our slot,
+			// same as any other drop in this wait loop.
+			b.logDebug("evicted under DropOldest queue-full policy", slog.String("method", method))
+			if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(QueueFull, waitedUs, &b.droppedCount) {
+				b.dequeueRequest(method)
+				return 0, &DropError{
+					Reason:   QueueFull,
+					Code:     b.dropCode(QueueFull, codes.ResourceExhausted),
+					Message:  fmt.Sprintf("Client id %s: request evicted by a newer arrival under the DropOldest queue-full policy.", b.idStr),
+					ClientID: b.idStr,
+					WaitedUs: waitedUs,
+				}
+			}
+			return b.getDemandAndDequeue(method)
+		case <-b.shutdownCh:
+			// Same as ctx.Done() below: we never took the noCreditBlocker
+			// token, so just dequeue our slot and drop (or, in shadow mode,
+			// admit as if the credit we never took had been available).
+			b.logDebug("shutting down while waiting for unblock")
+			if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(ShuttingDown, waitedUs, &b.droppedCount) {
+				b.dequeueRequest(method)
+				return 0, &DropError{
+					Reason:   ShuttingDown,
+					Code:     b.dropCode(ShuttingDown, codes.Unavailable),
+					Message:  fmt.Sprintf("Client id %s is shutting down, request dropped.", b.idStr),
+					ClientID: b.idStr,
+					WaitedUs: waitedUs,
+				}
+			}
+			return b.getDemandAndDequeue(method)
+		case <-ctx.Done():
+			// We never took the noCreditBlocker token, so there is nothing
+			// to hand back there; just dequeue our slot so we don't strand
+			// other waiters behind it.
+			b.logDebug("context cancelled while waiting for unblock", slog.Any("err", ctx.Err()))
+			if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(Cancelled, waitedUs, nil) {
+				b.dequeueRequest(method)
+				return 0, b.dropErrorFromContext(Cancelled, waitedUs, ctx.Err())
+			}
+			return b.getDemandAndDequeue(method)
+		case <-stallCh:
+			// A one-shot timer: this fires at most once per request, the
+			// first time it's been parked here longer than stallTimeout.
+			// We never took the noCreditBlocker token, so just report the
+			// stall and keep waiting, unless stallFailsRequest says to
+			// give up on this request outright.
+			atomic.AddUint64(&b.stallCount, 1)
+			waitedUs := elapsedMicros(b.clock.Now(), timeStart)
+			b.logWarn("client stalled waiting for credit", slog.String("method", method), slog.Int64("waitedUs", waitedUs))
+			if b.onStall != nil {
+				b.onStall(b.idStr, method, waitedUs)
+			}
+			if b.stallFailsRequest {
+				if b.noteDrop(Stalled, waitedUs, &b.droppedCount) {
+					b.dequeueRequest(method)
+					return 0, &DropError{
+						Reason:   Stalled,
+						Code:     b.dropCode(Stalled, codes.Unavailable),
+						Message:  fmt.Sprintf("Client id %s stalled waiting for credit past %s.", b.idStr, b.stallTimeout),
+						ClientID: b.idStr,
+						WaitedUs: waitedUs,
+					}
+				}
+				return b.getDemandAndDequeue(method)
+			}
+			continue
+		case <-creditWaitCh:
+			// A one-shot timer, distinct from stallCh: it always drops the
+			// request rather than offering a report-only mode, since the
+			// point of WithCreditWaitTimeout is failing fast on this
+			// specific wait. We never took the noCreditBlocker token, so
+			// there is nothing to hand back there; just dequeue our slot.
+			waitedUs := elapsedMicros(b.clock.Now(), timeStart)
+			b.logWarn("client timed out waiting for credit", slog.String("method", method), slog.Int64("waitedUs", waitedUs))
+			if b.noteDrop(CreditTimeout, waitedUs, &b.droppedCount) {
+				b.dequeueRequest(method)
+				return 0, &DropError{
+					Reason:   CreditTimeout,
+					Code:     b.dropCode(CreditTimeout, codes.Unavailable),
+					Message:  fmt.Sprintf("Client id %s timed out waiting for credit past %s.", b.idStr, b.creditWaitTimeout),
+					ClientID: b.idStr,
+					WaitedUs: waitedUs,
+				}
+			}
+			return b.getDemandAndDequeue(method)
+		}
+
+		// We just took a noCreditBlocker token. Before spending it,
+		// check whether some other still-parked waiter on this pool
+		// currently outranks us (its level plus aging beats ours) --
+		// if so, this is the small priority queue WithPriority and
+		// WithPriorityAging document: hand the token back for that
+		// waiter instead of consuming it ourselves, and go back to
+		// waiting our own turn.
+		if priorityQueue.hasHigherPriority(priorityTicket) {
+			b.unblockNoCreditBlock(method)
+			continue
+		}
 
 		// check that our time spent in queue has not exceeded the aqm threshold
 		// if so, we should drop the request
 		// time in microseconds
-		if useClientTimeExpiration {
-			timeTaken := time.Since(timeStart).Microseconds()
-			if timeTaken > b.clientExpiration {
+		if b.useClientTimeExpiration && !waitForReadyFromContext(ctx) {
+			timeTaken := elapsedMicros(b.clock.Now(), timeStart)
+			if timeTaken > b.effectiveExpiration() {
 				// drop request
-				logger("[Client Req Expired]:	Dropping request due to client side req expiration. Delay (us) was: %d\n", timeTaken)
-				b.unblockNoCreditBlock()
-				b.dequeueRequest()
-				return status.Errorf(codes.ResourceExhausted,
-					"Client id %s request expired in queue.", b.id.String())
+				b.logDebug("dropping request, client side expiration reached", slog.Int64("delayMicros", timeTaken))
+				b.unblockNoCreditBlock(method)
+				if b.noteDrop(Expired, timeTaken, &b.expiredCount) {
+					b.dequeueRequest(method)
+					return 0, &DropError{
+						Reason:   Expired,
+						Code:     b.dropCode(Expired, codes.ResourceExhausted),
+						Message:  fmt.Sprintf("Client id %s request expired in queue.", b.idStr),
+						ClientID: b.idStr,
+						WaitedUs: timeTaken,
+					}
+				}
+				return b.getDemandAndDequeue(method)
 			}
 		}
 
-		logger("[Waiting in queue]:	Unblock available, checking if credits are sufficient\n")
-		// Check actual number of credits (channel for binary semaphore)
-		creditBalance := <-b.outgoingCredits
-		if creditBalance > 0 {
-			// Decrement credit balance
-			creditBalance--
-			// Send updated credit balance
-			b.outgoingCredits <- creditBalance
+		// The caller's own context deadline takes priority over the fixed
+		// clientExpiration budget: whichever runs out first should drop the
+		// request, so this check runs every iteration regardless of
+		// useClientTimeExpiration.
+		if deadline, ok := ctx.Deadline(); ok && !b.clock.Now().Before(deadline) {
+			b.logDebug("dropping request, context deadline exceeded while waiting for credit")
+			b.unblockNoCreditBlock(method)
+			if waitedUs := elapsedMicros(b.clock.Now(), timeStart); b.noteDrop(Expired, waitedUs, &b.expiredCount) {
+				b.dequeueRequest(method)
+				return 0, &DropError{
+					Reason:   Expired,
+					Code:     b.dropCode(Expired, codes.DeadlineExceeded),
+					Message:  fmt.Sprintf("Client id %s context deadline exceeded while waiting for credit.", b.idStr),
+					ClientID: b.idStr,
+					WaitedUs: waitedUs,
+				}
+			}
+			return b.getDemandAndDequeue(method)
+		}
 
-			// If there are still credits, unblock other requests
-			if creditBalance > 0 {
-				b.unblockNoCreditBlock()
+		b.logDebug("unblock available, checking if credits are sufficient")
+		// TryAcquireCreditN is a non-blocking CAS, so there is no ctx.Done()
+		// branch here the way there used to be for the channel receive: a
+		// cancellation arriving in this instant is instead caught on our
+		// next trip through the noCreditBlocker select above. cost is 1
+		// unless the caller attached a different one via WithCost.
+		if b.TryAcquireCreditN(method, cost) {
+			b.logDebug("unblocked", slog.Int64("creditBalance", b.PeekCredit(method)))
+			waitedUs := elapsedMicros(b.clock.Now(), timeStart)
+			if admit, reason := b.admissionPolicy.Admit(ctx, int64(b.Demand(method)), b.PeekCredit(method), waitedUs); !admit {
+				// The policy vetoed a request that already held a credit;
+				// hand it straight back rather than leave it stranded on
+				// this request, the same as every other drop path above.
+				b.ReleaseCredit(method, cost)
+				if b.noteDrop(reason, waitedUs, &b.droppedCount) {
+					b.dequeueRequest(method)
+					return 0, &DropError{
+						Reason:   reason,
+						Code:     b.dropCode(reason, codes.ResourceExhausted),
+						Message:  fmt.Sprintf("Client id %s: admission policy declined request.", b.idStr),
+						ClientID: b.idStr,
+						WaitedUs: waitedUs,
+					}
+				}
+				return b.getDemandAndDequeue(method)
+			}
+			// WithMaxAdmitRate: the request already holds its credit, but
+			// still paces itself against the admit-rate token bucket
+			// before actually being handed to the caller, same as the
+			// admission policy veto above gives the credit back on a
+			// failure this late.
+			if rerr := b.awaitAdmitRate(ctx, timeStart); rerr != nil {
+				b.ReleaseCredit(method, cost)
+				waitedUs = elapsedMicros(b.clock.Now(), timeStart)
+				if b.noteDrop(Expired, waitedUs, &b.expiredCount) {
+					b.dequeueRequest(method)
+					return 0, &DropError{
+						Reason:   Expired,
+						Code:     b.dropCode(Expired, codes.ResourceExhausted),
+						Message:  fmt.Sprintf("Client id %s: admit rate limiter wait exceeded expiration.", b.idStr),
+						ClientID: b.idStr,
+						WaitedUs: waitedUs,
+					}
+				}
+				return b.getDemandAndDequeue(method)
+			}
+			return b.getDemandAndDequeue(method)
+		}
+		// No credits of our own available, but a registered broker (see
+		// WithBroker) may be able to move one over from an idle peer
+		// before we fall back to backing off; try that first so a starved
+		// instance doesn't wait out a full backoff cycle a work-conserving
+		// peer could have covered instantly.
+		if b.broker != nil && b.broker.Lend(b, method) {
+			continue
+		}
+		// No credits available: a false wake, or we lost the race for the
+		// one credit that just arrived. Rather than park back on
+		// noCreditBlocker -- which is re-signaled by every credit event,
+		// including ones other waiters just lost the same race on --
+		// re-park on creditAvailableSignal, woken only by a genuine
+		// <=0-to->0 balance transition. The backoff timer stays as a
+		// bound in case that signal was itself missed (eg this waiter
+		// grabbed it in the instant between the miss above and the
+		// transition), so this can never wait indefinitely; it still
+		// grows with consecutive misses so a sustained-overload herd
+		// doesn't hammer TryAcquireCreditN in lockstep every time one of
+		// them is woken.
+		zeroCreditStreak++
+		signal := b.creditAvailableSignal()
+		if backoff := b.zeroCreditBackoff(zeroCreditStreak, timeStart); backoff > 0 {
+			atomic.AddUint64(&b.backoffMicros, uint64(backoff.Microseconds()))
+			timer := time.NewTimer(backoff)
+			select {
+			case <-signal:
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// zeroCreditBackoff returns how long acquireCreditLoop should sleep after
+// its streak-th consecutive TryAcquireCredit miss: doubling from
+// zeroCreditBackoffBase, jittered to avoid every waiter in the herd
+// retrying in lockstep, and capped so it never pushes a request past its
+// clientExpiration budget regardless of how long the streak has run.
+func (b *Breakwater) zeroCreditBackoff(streak int, timeStart time.Time) time.Duration {
+	shift := streak - 1
+	if shift > zeroCreditBackoffMaxShift {
+		shift = zeroCreditBackoffMaxShift
+	}
+	backoff := zeroCreditBackoffBase * time.Duration(int64(1)<<uint(shift))
+	backoff = time.Duration(float64(backoff) * (0.5 + rand.Float64()*0.5))
+
+	elapsedUs := elapsedMicros(b.clock.Now(), timeStart)
+	remaining := time.Duration(b.clientExpiration.Load()-elapsedUs) * time.Microsecond
+	if remaining < backoff {
+		backoff = remaining
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+	return backoff
+}
+
+// noCreditBlockerFor returns method's parking channel, or the
+// instance-wide one when method has no override pool.
+func (b *Breakwater) noCreditBlockerFor(method string) chan int64 {
+	if p := b.poolFor(method); p != nil {
+		return p.noCreditBlocker
+	}
+	return b.noCreditBlocker
+}
+
+// priorityQueueFor returns method's admission-order tracker, or the
+// instance-wide one when method has no override pool, mirroring
+// noCreditBlockerFor.
+func (b *Breakwater) priorityQueueFor(method string) *priorityWaitQueue {
+	if p := b.poolFor(method); p != nil {
+		return p.priorityQueue
+	}
+	return b.priorityQueue
+}
+
+// dropOldestQueueFor returns method's FIFO eviction tracker for
+// QueueFullPolicy's DropOldest, or the instance-wide one when method
+// has no override pool, mirroring noCreditBlockerFor/priorityQueueFor.
+func (b *Breakwater) dropOldestQueueFor(method string) *dropOldestQueue {
+	if p := b.poolFor(method); p != nil {
+		return p.dropOldestQueue
+	}
+	return b.dropOldestQueue
+}
+
+/*
+awaitAdmitRate paces admission through admitRateLimiter (see
+WithMaxAdmitRate), independent of and applied after credit acquisition:
+a request that already holds a credit still waits here if the token
+bucket is dry. A nil admitRateLimiter (the default, disabled) returns
+immediately. The wait still obeys ctx.Done(), the caller's deadline, and
+-- unless waitForReadyFromContext -- the usual clientExpiration budget,
+so a rate cap can never hold a request past its own timeout budget; see
+errAdmitRateExpired.
+*/
+func (b *Breakwater) awaitAdmitRate(ctx context.Context, timeStart time.Time) error {
+	for !b.admitRateLimiter.take(b.clock.Now()) {
+		wait := b.admitRateLimiter.retryAfter()
+		if b.useClientTimeExpiration && !waitForReadyFromContext(ctx) {
+			// Wake no later than the expiration budget runs out, so the
+			// check below fires on time even when the refill is far off.
+			if remaining := time.Duration(b.effectiveExpiration()-elapsedMicros(b.clock.Now(), timeStart)) * time.Microsecond; remaining < wait {
+				wait = remaining
 			}
-			logger("[Waiting in queue]:	Unblocked with credit balance %d\n", creditBalance)
-			break
-		} else {
-			// Else, return to binary semaphore and keep looping
-			// Set a minimum credit balance of 0
-			b.outgoingCredits <- 0
-			// TODO: Consider adding a timeout here
 		}
-		logger("[Before Req]:	The method name for price table is %s\n")
-		// noCreditBlocker will unblock again when another request returns with
-		// more credits
+		if wait <= 0 {
+			wait = time.Millisecond
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if b.useClientTimeExpiration && !waitForReadyFromContext(ctx) {
+			if elapsedMicros(b.clock.Now(), timeStart) > b.effectiveExpiration() {
+				return errAdmitRateExpired
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && !b.clock.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
 	}
+	return nil
+}
 
-	// Get demand
-	demand := b.getDemand()
-	logger("[Waiting in queue]:	demand is %d\n", demand)
-	ctx = metadata.AppendToOutgoingContext(ctx, "demand", strconv.Itoa(demand), "id", b.id.String())
+/*
+getDemandAndDequeue reads method's current demand snapshot and removes
+this request's slot from its outgoing queue. Called once a credit has
+been successfully acquired.
+*/
+func (b *Breakwater) getDemandAndDequeue(method string) (demand int, err error) {
+	demand = b.getDemand(method)
+	b.logDebug("demand snapshot", slog.Int("demand", demand))
 
-	// After breaking out of request loop, remove request from queue and send request
 	// This should never be blocked
-	logger("[Waiting in queue]:	Dequeueing and handling request\n")
-	b.dequeueRequest()
+	b.logDebug("dequeueing and handling request")
+	b.dequeueRequest(method)
 
-	var header metadata.MD // variable to store header and trailer
-	err := invoker(ctx, method, req, reply, cc, grpc.Header(&header))
+	if b.metrics != nil {
+		b.metrics.OnAdmit(b.idStr, b.PeekCredit(method), int64(demand))
+	}
+	return demand, nil
+}
+
+// admitWithoutQueueSlot reports an immediate admit the same way
+// getDemandAndDequeue does, but without the dequeueRequest: for shadow
+// mode's (see WithShadowMode) pre-loop decision points, where this
+// request never occupied a queue slot to begin with (closed.Load(), the
+// queue-full check), so there is nothing to pop.
+func (b *Breakwater) admitWithoutQueueSlot(method string) (demand int, err error) {
+	demand = b.getDemand(method)
+	if b.metrics != nil {
+		b.metrics.OnAdmit(b.idStr, b.PeekCredit(method), int64(demand))
+	}
+	return demand, nil
+}
+
+/*
+returnCreditOnFail adds cost credits back to method's pool when
+creditsOnFail is enabled and the downstream call failed, mirroring the
+behavior previously inlined in UnaryInterceptorClient. cost matches
+whatever acquireCredit originally debited for this request (1, or
+WithCost's value).
+
+cost is clamped to 1 before debiting (see TryAcquireCreditN), so cost<=0
+here should only happen if a future call site debits differently; when
+it does, there's nothing to give back, so this counts it and moves on
+rather than issuing a ReleaseCredit(method, 0) that would silently do
+nothing.
+*/
+func (b *Breakwater) returnCreditOnFail(method string, cost int64) {
+	if !b.creditsOnFail {
+		return
+	}
+	if cost <= 0 {
+		atomic.AddUint64(&b.creditsOnFailNoop, 1)
+		b.logDebug("returnCreditOnFail: nothing to return", slog.Int64("cost", cost))
+		return
+	}
+	b.ReleaseCredit(method, cost)
+}
+
+// resetOnError reports whether err's gRPC code is one WithResetOnErrorCodes
+// marked as unrecoverable, in which case the failed call's credit should
+// be reset rather than returned via returnCreditOnFail.
+func (b *Breakwater) resetOnError(err error) bool {
+	if len(b.resetOnErrorCodes) == 0 {
+		return false
+	}
+	return b.resetOnErrorCodes[status.Code(err)]
+}
+
+// resetCreditOnError resets method's pool back to its starting balance,
+// per WithResetOnErrorCodes. It deliberately leaves slowStartDone alone,
+// the same way Reset does -- see WithResetOnErrorCodes' doc comment for
+// why that composes sensibly with WithInitialCredits' ramp.
+func (b *Breakwater) resetCreditOnError(method string) {
+	initial := b.initialCredits
+	if p := b.poolFor(method); p != nil {
+		initial = p.initialCredits
+	}
+	b.SetCredit(method, initial)
+}
+
+/*
+updateCreditsFromTrailer reads the "credits" entry of md, if present, and
+uses it to update method's outgoing credit balance, unblocking other
+waiters on that same pool. Mirrors the behavior previously inlined in
+UnaryInterceptorClient.
+
+Call sites invoke this at most once per logical RPC, but md itself can
+still carry more than one "credits" entry -- invoker may wrap a retrying
+transport that appended to the same header/trailer across attempts. The
+last entry reflects the attempt that actually produced reply, so that's
+the one applied; this makes the update idempotent with respect to how
+many attempts ran underneath, rather than double-counting or applying a
+stale value from an earlier attempt.
+*/
+func (b *Breakwater) updateCreditsFromTrailer(md metadata.MD, method string) {
+	floor := b.creditFloor.Load()
+	if p := b.poolFor(method); p != nil {
+		floor = p.creditFloor
+	}
+
+	var reported int64
+	values := md["credits"]
+	hasReported := len(values) > 0
+	isDelta := false
+	switch {
+	case hasReported:
+		parsed, err := strconv.ParseInt(values[len(values)-1], 10, 64)
+		if err != nil {
+			// A malformed "credits" trailer is treated the same as one
+			// that was never attached, rather than silently applying the
+			// zero value ParseInt leaves reported at on error.
+			b.logWarn("malformed credits trailer, ignoring", slog.String("credits", values[len(values)-1]), slog.Any("err", err))
+			hasReported = false
+			break
+		}
+		reported = parsed
+		b.slowStartDone.Store(true)
+		isDelta = b.creditUpdateMode == Additive
+		b.checkCreditModeMismatch(md, method)
+		b.logDebug("updated credits from response", slog.Int64("creditBalance", reported), slog.Bool("isDelta", isDelta))
+	case b.slowStartEnabled && !b.slowStartDone.Load():
+		// Slow-start always grows by doubling the current balance
+		// outright; it has no meaning as a delta, so this always
+		// merges as an absolute value regardless of creditUpdateMode.
+		reported = b.growSlowStart(method)
+		hasReported = true
+		b.logDebug("slow-start doubling credits", slog.Int64("creditBalance", reported))
+	default:
+		b.logDebug("no credits attached to response")
+	}
+	// mergeCredit/mergeCreditDelta read and write the balance as a single
+	// CAS-loop critical section, so a concurrent response settling at the
+	// same time can't have its update silently lost between this call's
+	// read and write.
+	var newBalance int64
+	if isDelta {
+		newBalance = b.mergeCreditDelta(method, reported, floor)
+	} else {
+		newBalance = b.mergeCredit(method, reported, hasReported, floor)
+	}
+
+	b.noteCreditBalance(newBalance)
+	if b.metrics != nil {
+		b.metrics.OnCreditUpdate(b.idStr, newBalance)
+	}
+}
+
+// growSlowStart doubles method's current balance, the same shape as
+// TCP's slow-start window growth, guaranteeing forward progress even
+// from a starting balance of 0 or 1 (0 -> 1 -> 2 -> 4 -> ...). Runs
+// once per response while WithInitialCredits' ramp is active; see
+// updateCreditsFromTrailer.
+func (b *Breakwater) growSlowStart(method string) int64 {
+	cur := b.PeekCredit(method)
+	next := cur * 2
+	if next <= cur {
+		next = cur + 1
+	}
+	return next
+}
+
+/*
+noteServerLoad reads the "load" entry of md, if present, and stores it in
+lastServerLoad for Stats().ServerLoad to report. It is a read-only
+signal: unlike updateCreditsFromTrailer, it never touches the credit
+balance, so capturing it has no effect on admission behavior unless the
+caller of Stats() acts on it themselves. Called on both success and
+failure, since the server may attach "load" even to a rejected request.
+*/
+func (b *Breakwater) noteServerLoad(md metadata.MD) {
+	if len(md["load"]) == 0 {
+		return
+	}
+	level, err := strconv.ParseInt(md["load"][0], 10, 64)
 	if err != nil {
-		// The request failed. if flag creditsOnFail is set, then we should add back one credit to the credit balance
-		if creditsOnFail {
-			select {
-			case credit := <-b.outgoingCredits:
-				b.outgoingCredits <- credit + 1
-			default:
-				// Log an error or handle the situation when there are no credits to retrieve
-				status.Errorf(codes.ResourceExhausted, "Client id %s has no credits to add back.", b.id.String())
+		return
+	}
+	b.lastServerLoad.Store(level)
+}
+
+/*
+noteCreditBalance records when the outgoing credit balance first drops to
+zero (or below), so
+watchZeroCredit can warn if it stays there past zeroCreditWarnAfter. A
+positive balance clears the marker. This only tracks the instance-wide
+balance; per-method pools don't get their own zero-credit watcher.
+*/
+func (b *Breakwater) noteCreditBalance(balance int64) {
+	if balance > 0 {
+		b.zeroCreditSince.Store(0)
+		return
+	}
+	b.zeroCreditSince.CompareAndSwap(0, time.Now().UnixNano())
+}
+
+/*
+watchZeroCredit periodically checks whether the client has been sitting at
+zero credits for longer than window, warning if so. It only makes sense
+when creditFloor is 0 (see WithCreditFloor); with a positive floor the
+balance never settles at zero between requests. Started once, for the
+life of the Breakwater instance, by WithZeroCreditWarning.
+*/
+func (b *Breakwater) watchZeroCredit(window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for range ticker.C {
+		since := b.zeroCreditSince.Load()
+		if since == 0 {
+			continue
+		}
+		if stalled := time.Since(time.Unix(0, since)); stalled > window {
+			b.logWarn("client has had zero credits past the configured window",
+				slog.Duration("stalled", stalled), slog.Duration("window", window))
+		}
+	}
+}
+
+/*
+watchCreditDecay periodically checks whether the instance-wide demand
+(see Demand) has sat at zero for longer than idleWindow and, if so,
+shrinks outgoingCredits toward creditFloor by rate -- a fraction of the
+balance above the floor -- on every tick past that point. A client
+holding an overcommitted balance it isn't using no longer sits on it
+indefinitely; the next real request this client sends reports its
+actual (low) demand, letting the server's own overcommitment math
+reclaim the rest. Started once, for the life of the instance, by
+WithCreditDecay.
+*/
+func (b *Breakwater) watchCreditDecay(idleWindow time.Duration, rate float64) {
+	ticker := time.NewTicker(idleWindow)
+	defer ticker.Stop()
+	for range ticker.C {
+		since := b.demandIdleSince.Load()
+		if since == 0 {
+			continue
+		}
+		if time.Since(time.Unix(0, since)) < idleWindow {
+			continue
+		}
+		b.decayCredit(rate)
+	}
+}
+
+/*
+decayCredit shrinks outgoingCredits towards creditFloor by rate, as a
+single CAS-loop critical section so a concurrent TryAcquireCreditN or
+ReleaseCredit can't have its update silently lost between a read and a
+write here. Never moves the balance below creditFloor, and always
+shrinks by at least 1 credit so a fractional rate against a small excess
+still makes progress.
+*/
+func (b *Breakwater) decayCredit(rate float64) {
+	floor := b.creditFloor.Load()
+	for {
+		cur := b.outgoingCredits.Load()
+		if cur <= floor {
+			return
+		}
+		shrink := int64(math.Ceil(float64(cur-floor) * rate))
+		if shrink < 1 {
+			shrink = 1
+		}
+		next := max(cur-shrink, floor)
+		if b.outgoingCredits.CompareAndSwap(cur, next) {
+			b.logDebug("decayed idle credit balance", slog.Int64("from", cur), slog.Int64("to", next))
+			return
+		}
+	}
+}
+
+/*
+recordRTT folds d, an observed invoker round-trip time, into
+ewmaRTTMicros. Called once per unary call, regardless of outcome, so the
+average reflects failures (which often run faster, ie fail fast) as
+well as successes.
+*/
+func (b *Breakwater) recordRTT(d time.Duration) {
+	us := d.Microseconds()
+	for {
+		cur := b.ewmaRTTMicros.Load()
+		var next int64
+		if cur == 0 {
+			next = us
+		} else {
+			next = int64(rttEWMAAlpha*float64(us) + (1-rttEWMAAlpha)*float64(cur))
+		}
+		if b.ewmaRTTMicros.CompareAndSwap(cur, next) {
+			return
+		}
+	}
+}
+
+/*
+effectiveExpiration returns the client-side queueing budget acquireCredit
+should enforce: the static clientExpiration normally, or
+adaptiveMultiplier * ewmaRTTMicros when WithAdaptiveExpiration is set and
+a sample has been recorded, clamped to clientExpiration as an upper
+bound so a sudden RTT spike can't let requests queue indefinitely.
+*/
+func (b *Breakwater) effectiveExpiration() int64 {
+	clientExpiration := b.clientExpiration.Load()
+	if !b.adaptiveExpiration {
+		return clientExpiration
+	}
+	ewma := b.ewmaRTTMicros.Load()
+	if ewma == 0 {
+		return clientExpiration
+	}
+	if adaptive := int64(b.adaptiveMultiplier * float64(ewma)); adaptive < clientExpiration {
+		return adaptive
+	}
+	return clientExpiration
+}
+
+/*
+smoothedDemand folds raw, a fresh Demand() snapshot, into
+demandEWMABits and returns the updated average, rounded to the nearest
+int. The raw instantaneous count is noisy -- it reflects a momentary
+queue depth -- which can make the server's overcommitment math overreact
+to a single spike; what goes out on the wire is this running average
+instead, while Demand() itself keeps returning the exact raw count for
+stats and dashboards.
+*/
+func (b *Breakwater) smoothedDemand(raw int) int {
+	rawF := float64(raw)
+	if !b.demandEWMASeeded.Load() {
+		b.demandEWMABits.Store(int64(math.Float64bits(rawF)))
+		b.demandEWMASeeded.Store(true)
+		return raw
+	}
+	for {
+		curBits := b.demandEWMABits.Load()
+		cur := math.Float64frombits(uint64(curBits))
+		next := b.demandEWMAAlpha*rawF + (1-b.demandEWMAAlpha)*cur
+		nextBits := int64(math.Float64bits(next))
+		if b.demandEWMABits.CompareAndSwap(curBits, nextBits) {
+			return int(math.Round(next))
+		}
+	}
+}
+
+/*
+shouldReportDemand reports whether this call should attach a fresh
+"demand" header, backing WithDemandReportInterval. With the default
+interval of 0 it always returns true. Otherwise it claims the report
+slot with a CAS against lastDemandReportAt so that under concurrent
+calls racing past the deadline, exactly one of them reports and resets
+the clock; the rest fall back to whatever the server already has via
+lastKnownDemand.
+*/
+func (b *Breakwater) shouldReportDemand(now time.Time) bool {
+	if b.demandReportInterval <= 0 {
+		return true
+	}
+	last := b.lastDemandReportAt.Load()
+	// last starts at math.MinInt64 (see InitBreakwater), never a real
+	// timestamp, so time.Unix(0, last) rather than now.UnixNano()-last
+	// avoids overflowing back past it.
+	if now.Sub(time.Unix(0, last)) < b.demandReportInterval {
+		return false
+	}
+	return b.lastDemandReportAt.CompareAndSwap(last, now.UnixNano())
+}
+
+/*
+applyAdmitJitter sleeps for a random duration in [0, admitJitter) when
+WithAdmitJitter is set, and is a no-op otherwise. Called once a credit
+has been acquired but before invoker/streamer runs, so a batch of
+waiters released by the same credit arrival don't all call the
+downstream server in lockstep.
+*/
+func (b *Breakwater) applyAdmitJitter() {
+	if b.admitJitter <= 0 {
+		return
+	}
+	time.Sleep(time.Duration(rand.Int64N(int64(b.admitJitter))))
+}
+
+/*
+debitCredit deducts one credit from method's outgoing balance without
+waiting for one to become available, floored at 0. Used to account for
+ongoing stream traffic between the credit acquired at stream-open and the
+credit update delivered on the eventual trailer.
+*/
+func (b *Breakwater) debitCredit(method string) {
+	if p := b.poolFor(method); p != nil {
+		for {
+			cur := p.outgoingCredits.Load()
+			if cur <= 0 {
+				return
+			}
+			if p.outgoingCredits.CompareAndSwap(cur, cur-1) {
+				return
 			}
-			b.unblockNoCreditBlock()
 		}
-		return err
+	}
+	for {
+		cur := b.outgoingCredits.Load()
+		if cur <= 0 {
+			return
+		}
+		if b.outgoingCredits.CompareAndSwap(cur, cur-1) {
+			return
+		}
+	}
+}
+
+/*
+restoreCreditAfterPanic unconditionally adds cost credits back to
+method's pool and unblocks a waiter, regardless of creditsOnFail. It
+exists for the panic-recovery path: a credit that was already acquired
+before invoker panicked must always go back, independent of the
+failure-accounting policy that governs an ordinary error return. cost
+matches whatever acquireCredit originally debited for this request.
+*/
+func (b *Breakwater) restoreCreditAfterPanic(method string, cost int64) {
+	b.ReleaseCredit(method, cost)
+}
+
+/*
+acquireAdmission is the gRPC-independent core of the client admission
+gate, shared by UnaryInterceptorClient and the public Acquire: resolve
+which pool this call draws from, queue for a slot, wait for (or acquire)
+a credit respecting clientExpiration/ctx's deadline, and enforce
+WithMaxInFlight. On success it returns a context carrying AdmitInfo (see
+AdmitInfoFromContext), the resolved pool key and cost (needed by the
+caller's own credit bookkeeping afterward), and the demand observed at
+admission time. The caller must call releaseAdmission exactly once after
+a successful return, whether or not the guarded call itself succeeds.
+*/
+func (b *Breakwater) acquireAdmission(ctx context.Context, method string) (outCtx context.Context, poolKey string, cost int64, demand int, err error) {
+	timeStart := b.clock.Now()
+
+	// poolKey selects which credit pool this call draws from: method
+	// alone, unless WithCreditPartition is set, in which case it also
+	// folds in the caller-supplied partition (eg the backend address the
+	// picker routed this call to), giving each partition its own
+	// independent balance/queue under the same per-method-pool machinery.
+	poolKey = b.poolKey(ctx, method)
+	cost = costFromContext(ctx)
+
+	demand, err = b.acquireCredit(ctx, timeStart, poolKey)
+	if err != nil {
+		return ctx, poolKey, cost, demand, err
+	}
+	b.applyAdmitJitter()
+
+	// WithMaxInFlight's cap is orthogonal to credits: it can still reject
+	// a request that already holds one, in which case that credit is
+	// wasted unless we hand it straight back.
+	if !b.acquireInFlightSlot() {
+		b.ReleaseCredit(poolKey, cost)
+		atomic.AddUint64(&b.droppedCount, 1)
+		if b.metrics != nil {
+			b.metrics.OnDrop(b.idStr, InFlightLimit.String())
+		}
+		waitedUs := elapsedMicros(b.clock.Now(), timeStart)
+		b.fireOnDrop(InFlightLimit, waitedUs)
+		return ctx, poolKey, cost, demand, &DropError{
+			Reason:   InFlightLimit,
+			Code:     b.dropCode(InFlightLimit, codes.ResourceExhausted),
+			Message:  fmt.Sprintf("Client id %s max in-flight limit reached", b.idStr),
+			ClientID: b.idStr,
+			WaitedUs: waitedUs,
+		}
+	}
+
+	// Counted from here, not from entry: acquireCredit can block for a
+	// while, and Close only needs to drain calls that have actually been
+	// handed to invoker, not ones still waiting on a credit (those are
+	// woken directly via shutdownCh instead).
+	b.inFlight.Add(1)
+
+	// The request is admitted at this point (credit debited, in-flight
+	// slot held): stash how long it waited and the state it was admitted
+	// under so downstream code can read it back without either value
+	// being threaded through manually. AdmitInfoFromContext only makes
+	// sense past this line -- a request that's dropped earlier never
+	// reaches invoker, so it never sees this context value either.
+	outCtx = withAdmitInfo(ctx, AdmitInfo{
+		WaitedUs:      elapsedMicros(b.clock.Now(), timeStart),
+		CreditBalance: b.PeekCredit(poolKey),
+		Demand:        demand,
+	})
+	return outCtx, poolKey, cost, demand, nil
+}
+
+// releaseAdmission undoes the in-flight bookkeeping acquireAdmission
+// sets up on a successful return: the WithMaxInFlight slot and the
+// inFlight counter Close drains on. It has nothing to do with the
+// credit itself -- callers handle that separately (ReleaseCredit,
+// SetCredit, or updateCreditsFromTrailer), since how a credit should be
+// settled varies by caller in a way this doesn't need to know about.
+func (b *Breakwater) releaseAdmission() {
+	b.inFlight.Done()
+	b.releaseInFlightSlot()
+}
+
+// Acquire is acquireAdmission's public, gRPC-independent counterpart,
+// for gating an arbitrary operation -- not necessarily a gRPC call --
+// with breakwater's queueing, credit, and WithMaxInFlight admission
+// control. On success it returns a context carrying AdmitInfo and a
+// release function the caller must call exactly once when the guarded
+// operation finishes; on failure release is nil and err is a *DropError,
+// with nothing to release.
+//
+// release's optional newCredit reports an authoritative credit balance
+// learned after the operation completed (eg a downstream response's own
+// credit grant), adopting it outright via SetCredit. release() with no
+// argument just gives the spent cost back via ReleaseCredit, the usual
+// choice for a caller with no such out-of-band signal.
+func (b *Breakwater) Acquire(ctx context.Context, method string) (context.Context, func(newCredit ...int64), error) {
+	outCtx, poolKey, cost, _, err := b.acquireAdmission(ctx, method)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	var released atomic.Bool
+	release := func(newCredit ...int64) {
+		if !released.CompareAndSwap(false, true) {
+			return
+		}
+		defer b.releaseAdmission()
+		if len(newCredit) > 0 {
+			b.SetCredit(poolKey, newCredit[0])
+			return
+		}
+		b.ReleaseCredit(poolKey, cost)
+	}
+	return outCtx, release, nil
+}
+
+/*
+AcquireN is Acquire's batch counterpart, for admitting a fan-out of n
+sub-requests as a unit instead of acquiring credits for each one
+individually and serializing on the credit loop before the fan-out even
+starts. Unlike Acquire, it never parks on noCreditBlocker -- it only ever
+takes what method's balance can cover right now -- so a fan-out either
+starts immediately or is told immediately that it can't, rather than
+some of its sub-requests stalling behind others (the head-of-line
+problem a one-credit-at-a-time loop has).
+
+What happens when fewer than n credits are available is controlled by
+WithAcquireNPolicy:
+
+  - AllOrNothing (the default): all n or nothing. granted is either n
+    (err is nil) or 0 (err is a *InsufficientCredit DropError).
+  - BestEffort: takes as many as are available, up to n. granted can be
+    anywhere from 1 to n; err is only non-nil if granted is 0. Callers
+    using BestEffort must size their fan-out to granted, not n.
+
+On success, release must be called exactly once, when the fan-out
+finishes, to give back however many credits were actually granted;
+release's optional newCredit argument adopts an authoritative balance
+the same way Acquire's release does. release is nil when err is non-nil,
+since there is nothing to release.
+*/
+func (b *Breakwater) AcquireN(ctx context.Context, method string, n int64) (release func(newCredit ...int64), granted int64, err error) {
+	poolKey := b.poolKey(ctx, method)
+	if n <= 0 {
+		n = 1
+	}
+
+	switch b.acquireNPolicy {
+	case BestEffort:
+		granted = b.tryAcquireUpToN(poolKey, n)
+	default:
+		if b.TryAcquireCreditN(poolKey, n) {
+			granted = n
+		}
+	}
+	if granted <= 0 {
+		return nil, 0, &DropError{
+			Reason:   InsufficientCredit,
+			Code:     b.dropCode(InsufficientCredit, codes.ResourceExhausted),
+			Message:  fmt.Sprintf("Client id %s could not acquire %d credits (policy=%s)", b.idStr, n, b.acquireNPolicy),
+			ClientID: b.idStr,
+		}
 	}
 
-	if len(header["credits"]) > 0 {
-		cXNew, _ := strconv.ParseInt(header["credits"][0], 10, 64)
-		logger("[Received Resp]:	Updated credits cXnew to spend is %d\n", cXNew)
+	var released atomic.Bool
+	release = func(newCredit ...int64) {
+		if !released.CompareAndSwap(false, true) {
+			return
+		}
+		if len(newCredit) > 0 {
+			b.SetCredit(poolKey, newCredit[0])
+			return
+		}
+		b.ReleaseCredit(poolKey, granted)
+	}
+	return release, granted, nil
+}
+
+func (b *Breakwater) UnaryInterceptorClient(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+
+	if !b.enabled.Load() || priorityFromContext(ctx) == High {
+		return b.bypassUnary(ctx, method, req, reply, cc, invoker, opts...)
+	}
 
-		// Update credits and unblock other requests
-		<-b.outgoingCredits
-		b.outgoingCredits <- max(cXNew, 1)
-		b.unblockNoCreditBlock()
+	timeStart := b.clock.Now()
+	ctx = withWaitForReady(ctx, waitForReadyFromOpts(opts))
+
+	ctx, poolKey, cost, demand, err := b.acquireAdmission(ctx, method)
+	if err != nil {
+		return err
+	}
+	defer b.releaseAdmission()
+
+	if b.shouldReportDemand(b.clock.Now()) {
+		ctx = metadata.AppendToOutgoingContext(ctx, "demand", b.demandString(b.smoothedDemand(demand)), "id", b.idStr, "credit-mode", b.creditUpdateMode.String())
 	} else {
-		logger("[Received Resp]:	No attached credits in response\n")
-		// If no response, then just put to 1
-		outgoingCredits := <-b.outgoingCredits
-		b.outgoingCredits <- max(outgoingCredits, 1)
-		b.unblockNoCreditBlock()
+		ctx = metadata.AppendToOutgoingContext(ctx, "id", b.idStr, "credit-mode", b.creditUpdateMode.String())
 	}
+
+	// getDemandAndDequeue already dequeued our slot once the credit was
+	// acquired, so a panicking invoker only needs its credit restored, not
+	// a second dequeue. Re-panic once the credit is back so the caller
+	// still observes the crash.
+	defer func() {
+		if r := recover(); r != nil {
+			b.logWarn("invoker panicked, restoring credit", slog.Any("panic", r))
+			b.restoreCreditAfterPanic(poolKey, cost)
+			panic(r)
+		}
+	}()
+
+	var header metadata.MD // variable to store header and trailer
+	err = invoker(ctx, method, req, reply, cc, grpc.Header(&header))
+	b.recordRTT(b.clock.Now().Sub(timeStart))
+	b.noteServerLoad(header)
+	if err != nil {
+		// The server still attaches "credits" to a rejection header when
+		// its own AQM is what produced the error (eg ResourceExhausted),
+		// explicitly telling this client how much to back off by. Honor
+		// that over creditsOnFail's blanket add-one, which would otherwise
+		// let the client over-send right after being told to slow down.
+		if len(header["credits"]) > 0 {
+			b.updateCreditsFromTrailer(header, poolKey)
+		} else if b.resetOnError(err) {
+			b.resetCreditOnError(poolKey)
+		} else {
+			b.returnCreditOnFail(poolKey, cost)
+		}
+		return err
+	}
+
+	if cheapHintFromContext(ctx) && b.clock.Now().Sub(timeStart) < b.cheapHintThreshold {
+		// The caller told us this request would be cheap and the server
+		// proved it right: release the credit now rather than leaving it
+		// pinned until the next "credits" trailer happens to update this
+		// method's balance, which under batched demand reporting could be
+		// several requests away.
+		b.ReleaseCredit(poolKey, cost)
+		atomic.AddUint64(&b.cheapHintReleases, 1)
+	}
+
+	b.updateCreditsFromTrailer(header, poolKey)
 	return err
 }
+
+/*
+bypassUnary handles requests made with WithPriority(ctx, High), and every
+request while this instance is disabled (see Disable/WithDisabled): it
+skips queueing, credit acquisition, and client-side expiration entirely,
+passing straight through to invoker. The "id" metadata is still
+attached so the server can attribute the request to this client, but no
+"demand" is reported since a bypassed request never enters the queue
+it would describe. There is no credit to restore on panic, so unlike
+UnaryInterceptorClient this has no recover/re-panic wrapper.
+*/
+func (b *Breakwater) bypassUnary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	atomic.AddUint64(&b.bypassedCount, 1)
+	ctx = metadata.AppendToOutgoingContext(ctx, "id", b.idStr)
+	b.inFlight.Add(1)
+	defer b.inFlight.Done()
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,36 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+/*
+panickingInvoker simulates a broken codec or transport that panics instead
+of returning an error.
+*/
+func panickingInvoker(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	panic("simulated invoker panic")
+}
+
+func TestUnaryInterceptorClientRestoresCreditOnPanic(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+
+	before := bw.PeekCredit("")
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected UnaryInterceptorClient to re-panic, but it did not")
+		}
+
+		after := bw.PeekCredit("")
+		if after != before {
+			t.Fatalf("credit balance changed across panic: before=%d after=%d", before, after)
+		}
+	}()
+
+	bw.UnaryInterceptorClient(context.Background(), "/test.Service/Method", nil, nil, nil, panickingInvoker)
+}
@@ -0,0 +1,65 @@
+package breakwater
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clientKey resolves the key UnaryInterceptor registers/looks up this
+// request's Connection under: clientKeyFunc if WithClientKeyFunc
+// overrode it, else defaultClientKey. Returning "" means "no usable
+// key", and UnaryInterceptor then bypasses overload control for this
+// request.
+func (b *Breakwater) clientKey(ctx context.Context, md metadata.MD) string {
+	if b.clientKeyFunc != nil {
+		return b.clientKeyFunc(ctx)
+	}
+	return b.defaultClientKey(ctx, md)
+}
+
+/*
+defaultClientKey is the out-of-the-box trust model. Ordinarily it's just
+the client-supplied "id" metadata. A missing id no longer bypasses
+overload control outright, though: it falls back to a key derived from
+the transport peer (peer.FromContext), so a buggy or malicious client
+that omits id still lands its own Connection instead of escaping
+per-client accounting altogether.
+
+When peerValidatedID is set (see WithPeerValidatedID), the peer address
+is folded into the key even when an id is present, so a claimed id
+can't be replayed from a different connection to pollute or steal
+another client's credit balance. This is strictly more restrictive than
+the default, so operators opt into it rather than getting it for free.
+*/
+func (b *Breakwater) defaultClientKey(ctx context.Context, md metadata.MD) string {
+	id, hasID := "", false
+	if values := md.Get("id"); len(values) > 0 {
+		id, hasID = values[0], true
+	}
+
+	peerAddr := peerAddrFromContext(ctx)
+
+	switch {
+	case hasID && b.peerValidatedID && peerAddr != "":
+		return id + "@" + peerAddr
+	case hasID:
+		return id
+	case peerAddr != "":
+		return "peer:" + peerAddr
+	default:
+		return ""
+	}
+}
+
+// peerAddrFromContext returns the transport peer's address string, or ""
+// if ctx carries no peer.Peer -- eg a context built by hand in a test
+// rather than by a real gRPC server.
+func peerAddrFromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
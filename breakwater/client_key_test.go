@@ -0,0 +1,82 @@
+package breakwater
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func withTestPeer(ctx context.Context) context.Context {
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345}})
+}
+
+func TestDefaultClientKeyUsesIDWhenPresent(t *testing.T) {
+	bw := New(BWParametersDefault)
+	ctx := withTestPeer(context.Background())
+	md := metadata.Pairs("id", "client-1")
+
+	if got := bw.defaultClientKey(ctx, md); got != "client-1" {
+		t.Fatalf("expected the supplied id to be used as-is, got %q", got)
+	}
+}
+
+func TestDefaultClientKeyFallsBackToPeerWhenIDMissing(t *testing.T) {
+	bw := New(BWParametersDefault)
+	ctx := withTestPeer(context.Background())
+
+	if got := bw.defaultClientKey(ctx, metadata.MD{}); got == "" {
+		t.Fatal("expected a peer-derived key when id is missing but a peer is present")
+	}
+}
+
+func TestDefaultClientKeyEmptyWithNeitherIDNorPeer(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	if got := bw.defaultClientKey(context.Background(), metadata.MD{}); got != "" {
+		t.Fatalf("expected an empty key with no id and no peer, got %q", got)
+	}
+}
+
+func TestWithPeerValidatedIDFoldsPeerIntoAnIDBasedKey(t *testing.T) {
+	bw := New(BWParametersDefault, WithPeerValidatedID(true))
+	ctx := withTestPeer(context.Background())
+	md := metadata.Pairs("id", "client-1")
+
+	got := bw.defaultClientKey(ctx, md)
+	if got == "client-1" {
+		t.Fatal("expected WithPeerValidatedID to fold the peer address into the key, not leave it bare")
+	}
+	if got == "" {
+		t.Fatal("expected a non-empty key")
+	}
+}
+
+func TestWithClientKeyFuncOverridesDefaultResolution(t *testing.T) {
+	bw := New(BWParametersDefault, WithClientKeyFunc(func(ctx context.Context) string {
+		return "always-this-key"
+	}))
+	md := metadata.Pairs("id", "client-1")
+
+	if got := bw.clientKey(context.Background(), md); got != "always-this-key" {
+		t.Fatalf("expected the override func's return value, got %q", got)
+	}
+}
+
+func TestUnaryInterceptorRegistersClientFromPeerWhenIDIsMissing(t *testing.T) {
+	params := BWParametersDefault
+	params.ServerSide = true
+	bw := New(params)
+	ctx := withTestPeer(metadata.NewIncomingContext(context.Background(), metadata.MD{}))
+
+	if _, err := bw.UnaryInterceptor(ctx, struct{}{}, nil, noopHandlerFunc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := bw.defaultClientKey(ctx, metadata.MD{})
+	if _, ok := bw.lastKnownDemand(key); !ok {
+		t.Fatalf("expected a client registered under the peer-derived key %q", key)
+	}
+}
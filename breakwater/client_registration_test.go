@@ -0,0 +1,30 @@
+package breakwater
+
+import (
+	"testing"
+)
+
+func TestDeregisterClientDecrementsNumClients(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+
+	idA, idB := "client-a", "client-b"
+	bw.RegisterClient(idA, 1)
+	bw.RegisterClient(idB, 1)
+	if got := bw.NumClients(); got != 2 {
+		t.Fatalf("expected 2 registered clients, got %d", got)
+	}
+
+	bw.DeregisterClient(idA)
+	if got := bw.NumClients(); got != 1 {
+		t.Fatalf("expected 1 registered client after deregistering idA, got %d", got)
+	}
+	if _, ok := bw.clientMap.Load(idA); ok {
+		t.Fatal("expected idA's Connection entry to be removed")
+	}
+
+	// Deregistering an already-gone client is a no-op, not a double decrement.
+	bw.DeregisterClient(idA)
+	if got := bw.NumClients(); got != 1 {
+		t.Fatalf("expected NumClients to stay at 1 after a redundant deregister, got %d", got)
+	}
+}
@@ -0,0 +1,35 @@
+package breakwater
+
+import "time"
+
+/*
+Clock abstracts the client interceptor's time reads so tests can drive
+expiration and RTT logic deterministically instead of sleeping in real
+time. WithClock installs one; Breakwater defaults to realClock, which
+just calls through to the time package.
+*/
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+/*
+elapsedMicros returns now's elapsed microseconds since start, clamped to
+0 instead of going negative. time.Time.Sub already prefers each value's
+monotonic reading when both carry one (as any time.Now() does), so a
+plain now.Sub(start) is safe against wall-clock adjustments on its own;
+this guard exists for the case that reading didn't: a WithClock fake, or
+a future timeStart that arrived deserialized or otherwise stripped of
+its monotonic component (eg propagated from another process). A
+negative duration there must never be read as "already long expired" by
+a Microseconds() comparison against it.
+*/
+func elapsedMicros(now, start time.Time) int64 {
+	if now.Before(start) {
+		return 0
+	}
+	return now.Sub(start).Microseconds()
+}
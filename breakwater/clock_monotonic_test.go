@@ -0,0 +1,67 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestElapsedMicrosClampsToZeroOnBackwardClockJump(t *testing.T) {
+	start := time.Unix(100, 0)
+	backward := time.Unix(90, 0)
+
+	if got := elapsedMicros(backward, start); got != 0 {
+		t.Fatalf("expected a backward clock jump to report 0 elapsed, got %d", got)
+	}
+	if got := elapsedMicros(start, start); got != 0 {
+		t.Fatalf("expected no elapsed time to report 0, got %d", got)
+	}
+	if got := elapsedMicros(start.Add(5*time.Millisecond), start); got != 5000 {
+		t.Fatalf("expected 5000us elapsed, got %d", got)
+	}
+}
+
+// TestBackwardClockJumpDoesNotExpireAQueuedRequest simulates timeStart
+// having been recorded, then the injected clock jumping backwards
+// before the expiration check runs -- eg a wall-clock adjustment, or
+// (per the motivating scenario) a timeStart propagated from another
+// process's slightly-ahead clock. Without elapsedMicros's guard, the
+// resulting negative duration would either expire the request
+// instantly (if Microseconds() is read as a huge unsigned value by
+// some future call site) or silently never expire it; either way it
+// must not be misread as "already expired".
+func TestBackwardClockJumpDoesNotExpireAQueuedRequest(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithClientTimeExpiration(true))
+	bw.clientExpiration.Store(1_000_000) // 1s budget, comfortably wider than the jump below
+
+	// Drain the starting credit so acquireCreditLoop has to park.
+	if !bw.TryAcquireCredit("") {
+		t.Fatal("expected the starting credit to be available")
+	}
+
+	timeStart := clock.Now()
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), timeStart, "")
+		result <- err
+	}()
+
+	// Jump the clock backwards past timeStart, then wake the waiter so it
+	// re-checks its expiration budget against a nominally negative
+	// elapsed duration.
+	clock.mu.Lock()
+	clock.now = clock.now.Add(-10 * time.Second)
+	clock.mu.Unlock()
+	bw.unblockNoCreditBlock("")
+	bw.SetCredit("", 1)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected the request to be admitted, not spuriously dropped, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquireCreditLoop did not return in time")
+	}
+}
@@ -0,0 +1,71 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestCloseRejectsNewRequestsAndDrainsInFlight(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	release := make(chan struct{})
+	invokerStarted := make(chan struct{})
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		close(invokerStarted)
+		<-release
+		return nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- bw.UnaryInterceptorClient(context.Background(), "/svc/Slow", nil, nil, nil, invoker)
+	}()
+	<-invokerStarted
+
+	closeErrCh := make(chan error, 1)
+	go func() { closeErrCh <- bw.Close(time.Second) }()
+
+	// A new request arriving after Close has started must be rejected
+	// immediately rather than queueing.
+	rejected := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		t.Fatal("invoker should not run for a request admitted after Close")
+		return nil
+	}
+	var de *DropError
+	err := bw.UnaryInterceptorClient(context.Background(), "/svc/New", nil, nil, nil, rejected)
+	if !errors.As(err, &de) || de.Reason != ShuttingDown {
+		t.Fatalf("expected a ShuttingDown DropError, got %v", err)
+	}
+
+	close(release)
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error from the in-flight call: %v", err)
+	}
+	if err := <-closeErrCh; err != nil {
+		t.Fatalf("expected Close to drain cleanly, got %v", err)
+	}
+}
+
+func TestCloseReturnsErrorOnDrainTimeout(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	invokerStarted := make(chan struct{})
+	block := make(chan struct{})
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		close(invokerStarted)
+		<-block
+		return nil
+	}
+	go bw.UnaryInterceptorClient(context.Background(), "/svc/Stuck", nil, nil, nil, invoker)
+	<-invokerStarted
+	defer close(block)
+
+	if err := bw.Close(10 * time.Millisecond); err == nil {
+		t.Fatal("expected Close to report a drain timeout while the invoker is still running")
+	}
+}
@@ -0,0 +1,107 @@
+package breakwater
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Config is a JSON-serializable snapshot of the handful of Breakwater
+// parameters operators most often want to retune from a config file
+// without restarting: queue capacity, client-side expiration, the
+// outgoing credit floor, and the AQM delay target. It deliberately
+// doesn't cover every BWParameters/Option knob -- most of those (factors,
+// SLO, credit classes, ...) are either set once at rollout and never
+// touched again, or already have their own dedicated Option. Fields left
+// at their zero value are treated as "leave unchanged" by ApplyConfig,
+// not "reset to zero".
+type Config struct {
+	// QueueCapacity, if positive, replaces the instance's queueCapacity.
+	// See ApplyConfig for what this does and doesn't affect.
+	QueueCapacity int64 `json:"queue_capacity,omitempty"`
+	// ClientExpirationMicros, if positive, replaces clientExpiration.
+	ClientExpirationMicros int64 `json:"client_expiration_micros,omitempty"`
+	// CreditFloor, if non-negative, replaces creditFloor. 0 is a valid
+	// floor (see WithCreditFloor), so it's distinguished from "unset" by
+	// CreditFloorSet rather than by being the zero value.
+	CreditFloor    int64 `json:"credit_floor"`
+	CreditFloorSet bool  `json:"-"`
+	// ThresholdDelayMicros, if positive, replaces thresholdDelay; aqmDelay
+	// is kept at 2x thresholdDelay, the same ratio WithThresholdDelay uses.
+	ThresholdDelayMicros float64 `json:"threshold_delay_micros,omitempty"`
+}
+
+// ApplyConfig atomically updates the subset of this instance's tunables
+// that cfg sets, validating ranges first so a bad config can't partially
+// apply. Fields left at their zero value (except CreditFloor, gated by
+// CreditFloorSet) are left unchanged.
+//
+// Changing QueueCapacity here does NOT resize pendingOutgoing or
+// noCreditBlocker: those channels are sized once at construction, and a
+// Go channel can't be resized without recreating it, which would risk
+// losing in-flight tokens or waiters parked on the old one. It only
+// changes the capacity used when a new per-method pool is lazily created
+// via poolFor (see WithMethodConfig, WithCreditPartition), and the value
+// reported by Config. Existing per-method pools are likewise
+// unaffected until they're recreated.
+func (b *Breakwater) ApplyConfig(cfg Config) error {
+	if cfg.QueueCapacity < 0 {
+		return fmt.Errorf("breakwater: QueueCapacity must be >= 0, got %d", cfg.QueueCapacity)
+	}
+	if cfg.ClientExpirationMicros < 0 {
+		return fmt.Errorf("breakwater: ClientExpirationMicros must be >= 0, got %d", cfg.ClientExpirationMicros)
+	}
+	if cfg.CreditFloorSet && cfg.CreditFloor < 0 {
+		return fmt.Errorf("breakwater: CreditFloor must be >= 0, got %d", cfg.CreditFloor)
+	}
+	if cfg.ThresholdDelayMicros < 0 {
+		return fmt.Errorf("breakwater: ThresholdDelayMicros must be >= 0, got %f", cfg.ThresholdDelayMicros)
+	}
+
+	if cfg.QueueCapacity > 0 {
+		b.queueCapacity.Store(cfg.QueueCapacity)
+	}
+	if cfg.ClientExpirationMicros > 0 {
+		b.clientExpiration.Store(cfg.ClientExpirationMicros)
+	}
+	if cfg.CreditFloorSet {
+		b.creditFloor.Store(cfg.CreditFloor)
+	}
+	if cfg.ThresholdDelayMicros > 0 {
+		b.thresholdDelay.Store(cfg.ThresholdDelayMicros)
+		b.aqmDelay.Store(cfg.ThresholdDelayMicros * 2.0)
+	}
+	return nil
+}
+
+// Config returns a snapshot of this instance's current hot-reloadable
+// tunables -- exactly the fields ApplyConfig/WithConfig can change,
+// read back off the same atomics they write, so it always reflects any
+// runtime change ApplyConfig made, not just what New/InitBreakwater
+// started with. Useful for logging the effective configuration at
+// startup or serving it from an admin/debug endpoint. Feeding the result
+// straight back into ApplyConfig is a no-op, since every field is
+// already at its current value.
+func (b *Breakwater) Config() Config {
+	return Config{
+		QueueCapacity:          b.queueCapacity.Load(),
+		ClientExpirationMicros: b.clientExpiration.Load(),
+		CreditFloor:            b.creditFloor.Load(),
+		CreditFloorSet:         true,
+		ThresholdDelayMicros:   b.thresholdDelay.Load(),
+	}
+}
+
+// WithConfig seeds a new Breakwater's hot-reloadable tunables from cfg at
+// construction time, so callers who already manage a Config for
+// ApplyConfig don't need a second code path to set the same fields up
+// front. Equivalent to calling ApplyConfig(cfg) immediately after New.
+// An out-of-range cfg is logged and otherwise ignored, the same
+// leave-it-at-the-default handling Option functions use elsewhere for a
+// bad argument (see WithMaxDemand) rather than panicking construction.
+func WithConfig(cfg Config) Option {
+	return func(bw *Breakwater) {
+		if err := bw.ApplyConfig(cfg); err != nil {
+			bw.logWarn("invalid Config passed to WithConfig, ignoring", slog.Any("err", err))
+		}
+	}
+}
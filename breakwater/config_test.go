@@ -0,0 +1,117 @@
+package breakwater
+
+import "testing"
+
+func TestApplyConfigUpdatesLiveInstance(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+
+	err := bw.ApplyConfig(Config{
+		QueueCapacity:          10,
+		ClientExpirationMicros: 2_000_000,
+		CreditFloor:            3,
+		CreditFloorSet:         true,
+		ThresholdDelayMicros:   500,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := bw.queueCapacity.Load(); got != 10 {
+		t.Fatalf("expected queueCapacity 10, got %d", got)
+	}
+	if got := bw.clientExpiration.Load(); got != 2_000_000 {
+		t.Fatalf("expected clientExpiration 2000000, got %d", got)
+	}
+	if got := bw.creditFloor.Load(); got != 3 {
+		t.Fatalf("expected creditFloor 3, got %d", got)
+	}
+	if got := bw.thresholdDelay.Load(); got != 500 {
+		t.Fatalf("expected thresholdDelay 500, got %v", got)
+	}
+	if got := bw.aqmDelay.Load(); got != 1000 {
+		t.Fatalf("expected aqmDelay 1000, got %v", got)
+	}
+}
+
+func TestApplyConfigLeavesUnsetFieldsUnchanged(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.creditFloor.Store(7)
+
+	if err := bw.ApplyConfig(Config{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := bw.queueCapacity.Load(); got != MAX_Q_LENGTH {
+		t.Fatalf("expected queueCapacity unchanged at %d, got %d", MAX_Q_LENGTH, got)
+	}
+	if got := bw.creditFloor.Load(); got != 7 {
+		t.Fatalf("expected creditFloor unchanged at 7, got %d", got)
+	}
+}
+
+func TestApplyConfigRejectsNegativeValues(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+
+	if err := bw.ApplyConfig(Config{QueueCapacity: -1}); err == nil {
+		t.Fatal("expected an error for a negative QueueCapacity")
+	}
+	if err := bw.ApplyConfig(Config{ClientExpirationMicros: -1}); err == nil {
+		t.Fatal("expected an error for a negative ClientExpirationMicros")
+	}
+	if err := bw.ApplyConfig(Config{CreditFloor: -1, CreditFloorSet: true}); err == nil {
+		t.Fatal("expected an error for a negative CreditFloor")
+	}
+	if err := bw.ApplyConfig(Config{ThresholdDelayMicros: -1}); err == nil {
+		t.Fatal("expected an error for a negative ThresholdDelayMicros")
+	}
+}
+
+func TestWithConfigSeedsConstructionTimeValues(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithConfig(Config{
+		CreditFloor:    4,
+		CreditFloorSet: true,
+	}))
+
+	if got := bw.creditFloor.Load(); got != 4 {
+		t.Fatalf("expected creditFloor 4, got %d", got)
+	}
+}
+
+func TestConfigReflectsApplyConfigChanges(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+
+	if err := bw.ApplyConfig(Config{
+		QueueCapacity:          10,
+		ClientExpirationMicros: 2_000_000,
+		CreditFloor:            3,
+		CreditFloorSet:         true,
+		ThresholdDelayMicros:   500,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := bw.Config()
+	want := Config{
+		QueueCapacity:          10,
+		ClientExpirationMicros: 2_000_000,
+		CreditFloor:            3,
+		CreditFloorSet:         true,
+		ThresholdDelayMicros:   500,
+	}
+	if got != want {
+		t.Fatalf("expected Config() to reflect the applied config\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestConfigRoundTripsThroughApplyConfig(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.ApplyConfig(Config{QueueCapacity: 20, CreditFloor: 2, CreditFloorSet: true, ThresholdDelayMicros: 100, ClientExpirationMicros: 1})
+
+	before := bw.Config()
+	if err := bw.ApplyConfig(before); err != nil {
+		t.Fatalf("unexpected error re-applying Config(): %v", err)
+	}
+	if after := bw.Config(); after != before {
+		t.Fatalf("expected Config() to be stable across a round trip\nbefore: %+v\nafter:  %+v", before, after)
+	}
+}
@@ -0,0 +1,30 @@
+package breakwater
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+/*
+Controller is the subset of *Breakwater's public surface most callers
+actually depend on directly: admitting a request, reading its current
+state, and toggling or tearing it down. It exists so downstream packages
+that take a Breakwater as a dependency can declare that dependency as
+Controller instead of the concrete type, and substitute a fake in their
+own unit tests instead of running a real credit loop. *Breakwater
+satisfies it, and is still what New/InitBreakwater return -- Controller
+is purely an additional, narrower view for callers who want it, not a
+replacement for the concrete type.
+*/
+type Controller interface {
+	UnaryInterceptorClient(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error
+	Stats() Stats
+	Demand(method string) int
+	Enable()
+	Disable()
+	Close(timeout time.Duration) error
+}
+
+var _ Controller = (*Breakwater)(nil)
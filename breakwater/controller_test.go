@@ -0,0 +1,42 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type fakeController struct {
+	enabled bool
+}
+
+func (f *fakeController) UnaryInterceptorClient(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+func (f *fakeController) Stats() Stats              { return Stats{} }
+func (f *fakeController) Demand(string) int         { return 0 }
+func (f *fakeController) Enable()                   { f.enabled = true }
+func (f *fakeController) Disable()                  { f.enabled = false }
+func (f *fakeController) Close(time.Duration) error { return nil }
+
+func useController(c Controller) Stats {
+	c.Enable()
+	return c.Stats()
+}
+
+func TestFakeControllerSatisfiesTheInterface(t *testing.T) {
+	var c Controller = &fakeController{}
+	useController(c)
+	if !c.(*fakeController).enabled {
+		t.Fatal("expected Enable to have been called through the interface")
+	}
+}
+
+func TestBreakwaterSatisfiesController(t *testing.T) {
+	var c Controller = New(BWParametersDefault)
+	if got := c.Demand(""); got != 0 {
+		t.Fatalf("expected a fresh instance to report zero demand, got %d", got)
+	}
+}
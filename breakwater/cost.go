@@ -0,0 +1,26 @@
+package breakwater
+
+import "context"
+
+type costKey struct{}
+
+/*
+WithCost marks ctx so the client interceptors debit n credits for this
+request instead of the default 1, for RPCs whose server-side work isn't
+uniform -- a batch call might cost 10x a point lookup. The expiration
+and queue-length logic are unchanged; only the credit balance decrement
+in the admit path scales with n. n <= 0 is treated as the default of 1.
+*/
+func WithCost(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, costKey{}, n)
+}
+
+// costFromContext returns the cost attached by WithCost, or 1 (the
+// default, preserving prior per-request behavior) if ctx carries none or
+// an invalid value.
+func costFromContext(ctx context.Context) int64 {
+	if n, ok := ctx.Value(costKey{}).(int64); ok && n > 0 {
+		return n
+	}
+	return 1
+}
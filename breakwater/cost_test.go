@@ -0,0 +1,49 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCostFromContextDefaultsToOne(t *testing.T) {
+	if got := costFromContext(context.Background()); got != 1 {
+		t.Fatalf("expected default cost of 1, got %d", got)
+	}
+}
+
+func TestWithCostDebitsRequestedAmount(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 10)
+
+	ctx := WithCost(context.Background(), 4)
+	if !bw.TryAcquireCreditN("", costFromContext(ctx)) {
+		t.Fatal("expected enough balance to admit a 4-credit request")
+	}
+	if got := bw.PeekCredit(""); got != 6 {
+		t.Fatalf("expected balance 6 after debiting 4 from 10, got %d", got)
+	}
+}
+
+func TestWithCostNonPositiveFallsBackToOne(t *testing.T) {
+	if got := costFromContext(WithCost(context.Background(), 0)); got != 1 {
+		t.Fatalf("expected non-positive cost to fall back to 1, got %d", got)
+	}
+	if got := costFromContext(WithCost(context.Background(), -5)); got != 1 {
+		t.Fatalf("expected negative cost to fall back to 1, got %d", got)
+	}
+}
+
+func TestReleaseCreditRestoresFullCostAfterFailure(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 10)
+
+	cost := int64(4)
+	if !bw.TryAcquireCreditN("", cost) {
+		t.Fatal("expected admission")
+	}
+	bw.ReleaseCredit("", cost)
+
+	if got := bw.PeekCredit(""); got != 10 {
+		t.Fatalf("expected full cost restored, got %d", got)
+	}
+}
@@ -0,0 +1,72 @@
+package breakwater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCreditAvailabilityReportsTransitionToZero(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	if !bw.TryAcquireCredit("") {
+		t.Fatal("expected the starting credit to be available")
+	}
+
+	select {
+	case avail := <-bw.CreditAvailability():
+		if avail {
+			t.Fatal("expected a false transition once the last credit was taken")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a transition to be reported")
+	}
+}
+
+func TestCreditAvailabilityReportsTransitionBackToPositive(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.TryAcquireCredit("") // drain to 0, draining the false transition below
+
+	<-bw.CreditAvailability()
+
+	bw.ReleaseCredit("", 1)
+	select {
+	case avail := <-bw.CreditAvailability():
+		if !avail {
+			t.Fatal("expected a true transition once a credit was released")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a transition to be reported")
+	}
+}
+
+func TestCreditAvailabilityDoesNotReportNonTransitions(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, InitialCredits: 5})
+	bw.SetCredit("", 5)
+
+	bw.ReleaseCredit("", 1) // 5 -> 6, still positive both sides, no transition
+	select {
+	case avail := <-bw.CreditAvailability():
+		t.Fatalf("expected no transition to be reported, got %v", avail)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCreditAvailabilityDoesNotBlockTheHotPathUnderBackpressure(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	for i := 0; i < 10; i++ {
+		bw.SetCredit("", 0)
+		bw.SetCredit("", 1)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bw.SetCredit("", 0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected SetCredit to return without blocking on a full, undrained channel")
+	}
+}
@@ -0,0 +1,89 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestCreditAvailableSignalIgnoresGenericUnblock confirms
+// creditAvailableSignal is the dedicated zero-credit condition synth-62
+// asked for: a flood of generic noCreditBlocker wakeups (the kind every
+// waiter racing for the same credit already causes each other) must not
+// close it, since none of them represent a genuine balance increase.
+func TestCreditAvailableSignalIgnoresGenericUnblock(t *testing.T) {
+	bw := New(BWParametersDefault, WithClientQueueLength(true))
+	bw.SetCredit("", 0)
+
+	sig := bw.creditAvailableSignal()
+	for i := 0; i < 1000; i++ {
+		bw.unblockNoCreditBlock("")
+	}
+
+	select {
+	case <-sig:
+		t.Fatal("expected creditAvailableSignal to stay open: no real credit increase happened")
+	default:
+	}
+}
+
+// TestCreditAvailableSignalFiresOnlyOnRealIncrease is the converse: a
+// genuine <=0-to->0 transition, and only that, closes the signal.
+func TestCreditAvailableSignalFiresOnlyOnRealIncrease(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 0)
+
+	sig := bw.creditAvailableSignal()
+	bw.ReleaseCredit("", 1)
+
+	select {
+	case <-sig:
+	default:
+		t.Fatal("expected creditAvailableSignal to fire after a real credit increase")
+	}
+}
+
+// TestAcquireCreditLoopDoesNotSpinUnderGenericReSignaling drives sustained
+// overload: a flood of generic unblocks with the balance pinned at 0, the
+// same pattern that used to wake a zero-credit waiter on every signal
+// instead of only on a genuine increase. If the fix regresses back to a
+// tight loop, this waiter would burn CPU polling TryAcquireCreditN instead
+// of actually sleeping, and BackoffMicros would stay near zero despite the
+// wall-clock time elapsed; asserting it tracks the elapsed time is a
+// bounded-iteration proxy for "this isn't spinning".
+func TestAcquireCreditLoopDoesNotSpinUnderGenericReSignaling(t *testing.T) {
+	bw := New(BWParameters{ClientExpiration: 1_000_000, QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 0)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				bw.unblockNoCreditBlock("")
+				time.Sleep(10 * time.Microsecond)
+			}
+		}
+	}()
+
+	const window = 40 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+
+	start := bw.clock.Now()
+	if _, err := bw.acquireCreditLoop(ctx, start, ""); err == nil {
+		t.Fatal("expected the request to eventually be dropped, never got a credit")
+	}
+	elapsed := bw.clock.Now().Sub(start)
+
+	backoffSpent := time.Duration(bw.Stats().BackoffMicros) * time.Microsecond
+	// A spinning loop would spend the whole window busy-polling instead
+	// of sleeping, so BackoffMicros would be a small fraction of elapsed;
+	// require it account for most of the window instead.
+	if backoffSpent < elapsed/2 {
+		t.Fatalf("expected most of the %v window to be spent backing off, only slept %v -- looks like a busy spin", elapsed, backoffSpent)
+	}
+}
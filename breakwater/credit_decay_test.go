@@ -0,0 +1,49 @@
+package breakwater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecayCreditShrinksTowardFloorByRate(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditFloor(2))
+	bw.SetCredit("", 10)
+
+	bw.decayCredit(0.5)
+	if got := bw.PeekCredit(""); got != 6 {
+		t.Fatalf("expected balance to shrink by half its excess over the floor (10-2=8, half=4) to 6, got %d", got)
+	}
+
+	bw.decayCredit(1)
+	if got := bw.PeekCredit(""); got != 2 {
+		t.Fatalf("expected a rate of 1 to decay straight to the floor, got %d", got)
+	}
+
+	// Already at the floor: decaying further must not push it below.
+	bw.decayCredit(1)
+	if got := bw.PeekCredit(""); got != 2 {
+		t.Fatalf("expected the balance to stay at the floor, got %d", got)
+	}
+}
+
+func TestWatchCreditDecayOnlyActsOnceDemandHasBeenIdleForTheWindow(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditFloor(0))
+	bw.SetCredit("", 100)
+
+	// Demand starts nonzero; no decay should happen even past the window.
+	bw.queueRequest("")
+
+	const window = 20 * time.Millisecond
+	go bw.watchCreditDecay(window, 1)
+
+	time.Sleep(3 * window)
+	if got := bw.PeekCredit(""); got != 100 {
+		t.Fatalf("expected no decay while demand is nonzero, got %d", got)
+	}
+
+	bw.dequeueRequest("")
+	time.Sleep(3 * window)
+	if got := bw.PeekCredit(""); got != 0 {
+		t.Fatalf("expected the balance to decay to the floor once idle past the window, got %d", got)
+	}
+}
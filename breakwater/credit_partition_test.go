@@ -0,0 +1,66 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+type backendKey struct{}
+
+func withBackend(ctx context.Context, backend string) context.Context {
+	return context.WithValue(ctx, backendKey{}, backend)
+}
+
+func backendFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(backendKey{}).(string); ok {
+		return v
+	}
+	return ""
+}
+
+func TestCreditPartitionGivesEachKeyItsOwnPool(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditPartition(backendFromContext))
+
+	// Drain the instance-wide pool's starting credit; if partitioning
+	// weren't wired up, every call below would share this and block.
+	if !bw.TryAcquireCredit("") {
+		t.Fatal("expected the starting credit to be available")
+	}
+
+	invoked := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		invoked++
+		return nil
+	}
+
+	ctxA := withBackend(context.Background(), "backend-a")
+	ctxB := withBackend(context.Background(), "backend-b")
+
+	if err := bw.UnaryInterceptorClient(ctxA, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("backend-a call failed: %v", err)
+	}
+	if err := bw.UnaryInterceptorClient(ctxB, "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("backend-b call failed: %v", err)
+	}
+	if invoked != 2 {
+		t.Fatalf("expected 2 invocations, got %d", invoked)
+	}
+
+	poolA := bw.poolFor(bw.poolKey(ctxA, "/svc/Method"))
+	poolB := bw.poolFor(bw.poolKey(ctxB, "/svc/Method"))
+	if poolA == nil || poolB == nil {
+		t.Fatal("expected both partitions to have their own pool")
+	}
+	if poolA == poolB {
+		t.Fatal("expected distinct pools for distinct partition keys")
+	}
+}
+
+func TestPoolKeyWithoutPartitioningReturnsMethodUnchanged(t *testing.T) {
+	bw := New(BWParametersDefault)
+	if got := bw.poolKey(context.Background(), "/svc/Method"); got != "/svc/Method" {
+		t.Fatalf("expected method unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package breakwater
+
+import "time"
+
+/*
+CreditStore lets a Breakwater client instance persist its outgoing
+credit balance across process restarts; see WithCreditStore. It is
+intentionally minimal -- just the two operations the interceptor
+actually needs -- so a caller can back it with whatever they already
+have (a local file, an attached volume, Redis, etc.) without adapting to
+a wider interface.
+
+Implementations must be safe for concurrent use: Save can be called from
+watchCreditStore's periodic tick and from Close at roughly the same
+time during shutdown.
+*/
+type CreditStore interface {
+	// Load returns the last persisted balance and true, or ok=false if
+	// nothing has been persisted yet (eg this client's very first run).
+	Load() (int64, bool)
+	// Save persists balance so a future Load call can resume near it.
+	Save(balance int64)
+}
+
+/*
+watchCreditStore periodically calls store.Save with the current
+instance-wide balance, so a process that crashes instead of reaching
+Close still has a recent balance on disk to resume from. Started once,
+for the life of the instance, by WithCreditStore; stops the moment
+shutdownCh closes, since Close itself takes over persisting the final
+balance from there (see saveCreditStore).
+*/
+func (b *Breakwater) watchCreditStore(store CreditStore, saveInterval time.Duration) {
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			store.Save(b.PeekCredit(""))
+		case <-b.shutdownCh:
+			return
+		}
+	}
+}
+
+// saveCreditStore persists the current instance-wide balance via
+// WithCreditStore's CreditStore, if one is configured; a no-op
+// otherwise. Called by Close so a graceful shutdown always leaves a
+// fresh balance behind, even if saveInterval hasn't ticked recently.
+func (b *Breakwater) saveCreditStore() {
+	if b.creditStore != nil {
+		b.creditStore.Save(b.PeekCredit(""))
+	}
+}
@@ -0,0 +1,94 @@
+package breakwater
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCreditStore is an in-memory CreditStore for tests: Save records
+// every call, Load returns the most recent one (or ok=false if Save was
+// never called, the "brand new client" case).
+type fakeCreditStore struct {
+	mu      sync.Mutex
+	balance int64
+	loaded  bool
+	saves   int
+}
+
+func (s *fakeCreditStore) Load() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balance, s.loaded
+}
+
+func (s *fakeCreditStore) Save(balance int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balance = balance
+	s.loaded = true
+	s.saves++
+}
+
+func (s *fakeCreditStore) saveCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+func TestWithCreditStoreRestoresBalanceOnLoad(t *testing.T) {
+	store := &fakeCreditStore{balance: 42, loaded: true}
+	bw := New(BWParameters{}, WithCreditStore(store, 0))
+
+	if got := bw.PeekCredit(""); got != 42 {
+		t.Fatalf("expected the restored balance 42, got %d", got)
+	}
+}
+
+func TestWithCreditStoreLeavesDefaultBalanceWhenNothingWasSaved(t *testing.T) {
+	store := &fakeCreditStore{}
+	bw := New(BWParametersDefault, WithCreditStore(store, 0))
+
+	if got := bw.PeekCredit(""); got != 1 {
+		t.Fatalf("expected the untouched default starting balance 1, got %d", got)
+	}
+}
+
+func TestWithCreditStoreSavesPeriodically(t *testing.T) {
+	store := &fakeCreditStore{}
+	bw := New(BWParametersDefault, WithInitialCredits(3), WithCreditStore(store, time.Millisecond))
+	defer bw.Close(time.Second)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if store.saveCount() > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if balance, ok := store.Load(); !ok || balance != 3 {
+		t.Fatalf("expected a periodic save of balance 3, got balance=%d ok=%v", balance, ok)
+	}
+}
+
+func TestWithCreditStoreSavesOnceMoreOnClose(t *testing.T) {
+	store := &fakeCreditStore{}
+	bw := New(BWParametersDefault, WithCreditStore(store, time.Hour))
+
+	bw.SetCredit("", 9)
+	if err := bw.Close(time.Second); err != nil {
+		t.Fatalf("unexpected error from Close: %v", err)
+	}
+
+	if balance, ok := store.Load(); !ok || balance != 9 {
+		t.Fatalf("expected Close to save the final balance 9, got balance=%d ok=%v", balance, ok)
+	}
+}
+
+func TestWithCreditStoreNilStoreIsANoOp(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditStore(nil, time.Millisecond))
+	if err := bw.Close(time.Second); err != nil {
+		t.Fatalf("unexpected error from Close: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package breakwater
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+CreditPush is one credit adjustment a server pushes to an idle client
+outside the normal request/response credit-trailer path, so a client
+parked at zero credits with no in-flight request to carry a trailer can
+still learn the server has recovered, instead of deadlocking until its
+next attempt times out. ClientID targets a single client's Connection on
+a shared stream; Method scopes the push to one method's pool the same
+way WithMethodConfig does, or the instance-wide pool when empty. Credits
+replaces (not adds to) the target balance, mirroring SetCredit.
+
+This package owns no .proto of its own -- every RPC it intercepts
+belongs to the application. Declaring the actual CreditStream service
+method (server-streaming CreditPush to a client, or bidi if the client
+also needs to ack) is therefore left to the application's own proto
+definition; CreditPushSender/CreditPushReceiver below are the seam
+PushCredit and ConsumeCreditStream need to drive whatever stub that
+generates, without this package needing to generate or own the message
+type transmitted over it.
+*/
+type CreditPush struct {
+	ClientID string
+	Method   string
+	Credits  int64
+}
+
+// CreditPushSender is the send half of a server->client CreditStream
+// RPC, satisfied by that RPC's generated server-stream stub.
+type CreditPushSender interface {
+	Send(*CreditPush) error
+}
+
+// CreditPushReceiver is the receive half of the same stream, satisfied
+// by the client-stream stub the application dials with.
+type CreditPushReceiver interface {
+	Recv() (*CreditPush, error)
+}
+
+/*
+ApplyPushedCredit applies a CreditPush the client received over its
+CreditStream: it replaces push.Method's balance the same way SetCredit
+does, through the same outgoingCredits/unblockNoCreditBlock pair, so a
+recovering server's push wakes waiters parked on zero credits -- as many
+as the push made newly available -- rather than waiting for the next
+trailer.
+*/
+func (b *Breakwater) ApplyPushedCredit(push *CreditPush) {
+	if push == nil {
+		return
+	}
+	b.SetCredit(push.Method, push.Credits)
+}
+
+/*
+ConsumeCreditStream runs the client side of a CreditStream RPC: it loops
+receiving pushes from stream and applying each one via ApplyPushedCredit
+until stream.Recv returns an error (the stream ended, or the call was
+cancelled) or ctx is done, whichever comes first. It's meant to run for
+the life of the connection in its own goroutine, started once right
+after dialing the server's CreditStream method; cancelling ctx is the
+normal way to stop it, eg alongside Close.
+*/
+func (b *Breakwater) ConsumeCreditStream(ctx context.Context, stream CreditPushReceiver) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		push, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		b.ApplyPushedCredit(push)
+	}
+}
+
+/*
+PushCredit sends a CreditPush for clientID over sender -- the server
+side of a CreditStream RPC, wired up by the application to notice a
+client that's gone quiet with no recent trailer to carry a credit update
+and proactively wake it instead of waiting for its next request. method
+scopes the push the same way CreditPush.Method does; credits replaces
+that client's balance outright on arrival.
+*/
+func (b *Breakwater) PushCredit(sender CreditPushSender, clientID, method string, credits int64) error {
+	if sender == nil {
+		return fmt.Errorf("breakwater: PushCredit requires a non-nil sender")
+	}
+	return sender.Send(&CreditPush{ClientID: clientID, Method: method, Credits: credits})
+}
@@ -0,0 +1,139 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCreditStream is an in-memory CreditPushSender/CreditPushReceiver
+// pair connecting PushCredit's server side directly to
+// ConsumeCreditStream's client side, without a real gRPC connection.
+type fakeCreditStream struct {
+	mu     sync.Mutex
+	pushes []*CreditPush
+	closed bool
+}
+
+func (s *fakeCreditStream) Send(push *CreditPush) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return io.EOF
+	}
+	s.pushes = append(s.pushes, push)
+	return nil
+}
+
+func (s *fakeCreditStream) Recv() (*CreditPush, error) {
+	for {
+		s.mu.Lock()
+		if len(s.pushes) > 0 {
+			push := s.pushes[0]
+			s.pushes = s.pushes[1:]
+			s.mu.Unlock()
+			return push, nil
+		}
+		closed := s.closed
+		s.mu.Unlock()
+		if closed {
+			return nil, io.EOF
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *fakeCreditStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+func TestApplyPushedCreditReplacesBalanceAndWakesWaiters(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 0)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		done <- err
+	}()
+	waitForDemand(t, bw, "", 1)
+
+	bw.ApplyPushedCredit(&CreditPush{Credits: 3})
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected the pushed credit to admit the parked waiter, got err=%v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the pushed credit to wake the parked waiter")
+	}
+}
+
+func TestApplyPushedCreditNilIsANoOp(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+	bw.SetCredit("", 5)
+	bw.ApplyPushedCredit(nil)
+	if got := bw.PeekCredit(""); got != 5 {
+		t.Fatalf("expected a nil push to leave the balance untouched, got %d", got)
+	}
+}
+
+func TestPushCreditSendsOverTheStream(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+	stream := &fakeCreditStream{}
+
+	if err := bw.PushCredit(stream, "client-1", "", 7); err != nil {
+		t.Fatalf("unexpected error from PushCredit: %v", err)
+	}
+
+	push, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error receiving the push: %v", err)
+	}
+	if push.ClientID != "client-1" || push.Credits != 7 {
+		t.Fatalf("unexpected push %+v", push)
+	}
+}
+
+func TestPushCreditRejectsNilSender(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+	if err := bw.PushCredit(nil, "client-1", "", 7); err == nil {
+		t.Fatal("expected an error from a nil sender")
+	}
+}
+
+func TestConsumeCreditStreamAppliesPushesUntilStreamEnds(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+	bw.SetCredit("", 0)
+	stream := &fakeCreditStream{}
+
+	if err := bw.PushCredit(stream, "client-1", "", 4); err != nil {
+		t.Fatalf("unexpected error from PushCredit: %v", err)
+	}
+	stream.Close()
+
+	err := bw.ConsumeCreditStream(context.Background(), stream)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF once the stream closed, got %v", err)
+	}
+	if got := bw.PeekCredit(""); got != 4 {
+		t.Fatalf("expected the queued push to be applied before EOF, got %d", got)
+	}
+}
+
+func TestConsumeCreditStreamStopsWhenContextIsDone(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+	stream := &fakeCreditStream{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := bw.ConsumeCreditStream(ctx, stream); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package breakwater
+
+// CreditUpdateMode controls how the "credits" header/trailer value is
+// interpreted on the wire; see WithCreditUpdateMode. The same field
+// picks the wire format for whichever role (client, server, or both) an
+// instance plays, so a client and the server it talks to must be
+// configured with the same mode.
+type CreditUpdateMode int
+
+const (
+	// Replace is the default: the value is the receiver's new balance
+	// outright, exactly the behavior before this option existed.
+	Replace CreditUpdateMode = iota
+	// Additive: the value is a signed delta (credits granted this
+	// round) that the receiver adds to its existing balance instead of
+	// replacing it. This tolerates a dropped response better than
+	// Replace -- missing one update just means the next delta catches
+	// up, rather than the receiver being stuck on a stale total. The
+	// result is still clamped at the configured credit floor either
+	// way.
+	Additive
+)
+
+func (m CreditUpdateMode) String() string {
+	if m == Additive {
+		return "additive"
+	}
+	return "replace"
+}
@@ -0,0 +1,27 @@
+package breakwater
+
+import (
+	"log/slog"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// checkCreditModeMismatch logs a warning when the peer's "credit-mode"
+// metadata (see CreditUpdateMode) disagrees with this instance's own
+// creditUpdateMode, so a Replace/Additive mismatch across a connection
+// is caught in the logs instead of silently misinterpreting every
+// credit update. It never changes behavior on its own -- each side
+// keeps acting on its own configured mode regardless.
+func (b *Breakwater) checkCreditModeMismatch(md metadata.MD, peerID string) {
+	values := md["credit-mode"]
+	if len(values) == 0 {
+		return
+	}
+	peerMode := values[len(values)-1]
+	if peerMode != b.creditUpdateMode.String() {
+		b.logWarn("credit update mode mismatch",
+			slog.String("peerID", peerID),
+			slog.String("peerMode", peerMode),
+			slog.String("localMode", b.creditUpdateMode.String()))
+	}
+}
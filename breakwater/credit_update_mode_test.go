@@ -0,0 +1,81 @@
+package breakwater
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// flagHandler is a minimal slog.Handler that just flips a bool, for
+// tests that only care whether something got logged, not what.
+type flagHandler struct{ flag *bool }
+
+func (h flagHandler) Enabled(context.Context, slog.Level) bool  { return true }
+func (h flagHandler) Handle(context.Context, slog.Record) error { *h.flag = true; return nil }
+func (h flagHandler) WithAttrs(attrs []slog.Attr) slog.Handler  { return h }
+func (h flagHandler) WithGroup(name string) slog.Handler        { return h }
+
+func newCapturingLogger(flag *bool) *slog.Logger {
+	return slog.New(flagHandler{flag: flag})
+}
+
+func TestAdditiveModeAddsTheTrailerDeltaInsteadOfReplacing(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithCreditUpdateMode(Additive))
+	bw.SetCredit("", 5)
+
+	trailer := metadata.Pairs("credits", "3")
+	bw.updateCreditsFromTrailer(trailer, "")
+
+	if got := bw.PeekCredit(""); got != 8 {
+		t.Fatalf("expected 5+3=8 after an additive update, got %d", got)
+	}
+
+	negativeTrailer := metadata.Pairs("credits", "-10")
+	bw.updateCreditsFromTrailer(negativeTrailer, "")
+
+	if got := bw.PeekCredit(""); got != bw.creditFloor.Load() {
+		t.Fatalf("expected a large negative delta to clamp at the floor (%d), got %d", bw.creditFloor.Load(), got)
+	}
+}
+
+func TestReplaceModeStillReplacesTheBalanceOutright(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 5)
+
+	trailer := metadata.Pairs("credits", "3")
+	bw.updateCreditsFromTrailer(trailer, "")
+
+	if got := bw.PeekCredit(""); got != 3 {
+		t.Fatalf("expected the balance replaced outright with 3, got %d", got)
+	}
+}
+
+func TestCheckCreditModeMismatchWarnsOnDisagreement(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithCreditUpdateMode(Additive))
+
+	var warned bool
+	bw.slogger = newCapturingLogger(&warned)
+
+	md := metadata.Pairs("credit-mode", "replace")
+	bw.checkCreditModeMismatch(md, "peer-a")
+
+	if !warned {
+		t.Fatal("expected a mismatched credit-mode to be logged")
+	}
+}
+
+func TestCheckCreditModeMismatchStaysQuietOnAgreement(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithCreditUpdateMode(Additive))
+
+	var warned bool
+	bw.slogger = newCapturingLogger(&warned)
+
+	md := metadata.Pairs("credit-mode", "additive")
+	bw.checkCreditModeMismatch(md, "peer-a")
+
+	if warned {
+		t.Fatal("expected a matching credit-mode to stay quiet")
+	}
+}
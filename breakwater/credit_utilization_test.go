@@ -0,0 +1,42 @@
+package breakwater
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestStatsTracksCreditsGrantedAndConsumed(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+
+	if got := bw.Stats().CreditsGranted; got != 0 {
+		t.Fatalf("expected no credits granted yet, got %d", got)
+	}
+
+	// InitBreakwater seeds outgoingCredits to 1 directly, bypassing
+	// mergeCredit, so the first trailer-driven grant only counts the
+	// increase over that starting balance: 1 -> 5 is 4 newly granted.
+	bw.updateCreditsFromTrailer(metadata.Pairs("credits", "5"), "")
+	if !bw.TryAcquireCreditN("", 3) {
+		t.Fatal("expected TryAcquireCreditN to succeed against a balance of 5")
+	}
+
+	stats := bw.Stats()
+	if stats.CreditsGranted != 4 {
+		t.Fatalf("expected 4 credits granted, got %d", stats.CreditsGranted)
+	}
+	if stats.CreditsConsumed != 3 {
+		t.Fatalf("expected 3 credits consumed, got %d", stats.CreditsConsumed)
+	}
+	if got, want := stats.CreditUtilization, 3.0/4.0; got != want {
+		t.Fatalf("expected utilization %f, got %f", want, got)
+	}
+}
+
+func TestStatsCreditUtilizationIsZeroBeforeAnyGrant(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+
+	if got := bw.Stats().CreditUtilization; got != 0 {
+		t.Fatalf("expected utilization 0 before any grant, got %f", got)
+	}
+}
@@ -0,0 +1,71 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreditWaitTimeoutDropsTheRequest(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithCreditWaitTimeout(20*time.Millisecond))
+	bw.SetCredit("", 0)
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	if err == nil {
+		t.Fatal("expected the request to be dropped once the credit wait timeout elapsed")
+	}
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError, got %v", err)
+	}
+	if de.Reason != CreditTimeout {
+		t.Fatalf("expected Reason CreditTimeout, got %v", de.Reason)
+	}
+}
+
+func TestCreditWaitTimeoutDisabledByDefault(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 0)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		result <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	bw.SetCredit("", 1)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected the waiter to be admitted once credit arrived, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the waiter to be admitted")
+	}
+}
+
+func TestCreditWaitTimeoutDoesNotFireOnceCreditArrivesFirst(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithCreditWaitTimeout(2*time.Second))
+	bw.SetCredit("", 0)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		result <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	bw.SetCredit("", 1)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected the waiter to be admitted before the credit wait timeout fired, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the waiter to be admitted")
+	}
+}
@@ -0,0 +1,72 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// setHeaderOpt finds the grpc.Header(&md) CallOption among opts and
+// populates it, mirroring what the real transport does when a server
+// calls grpc.SetHeader before returning an error.
+func setHeaderOpt(opts []grpc.CallOption, md metadata.MD) {
+	for _, opt := range opts {
+		if h, ok := opt.(grpc.HeaderCallOption); ok {
+			*h.HeaderAddr = md
+		}
+	}
+}
+
+func TestUnaryInterceptorClientHonorsCreditsHeaderOnError(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditsOnFail(true))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		setHeaderOpt(opts, metadata.Pairs("credits", "5"))
+		return errors.New("resource exhausted")
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err == nil {
+		t.Fatal("expected the invoker error to propagate")
+	}
+
+	// The server explicitly reported 5: that must win over creditsOnFail's
+	// blanket add-one (which started from 0 after the credit was acquired
+	// and would have landed on 1).
+	if got := bw.PeekCredit(""); got != 5 {
+		t.Fatalf("expected credit balance 5 from the error header, got %d", got)
+	}
+}
+
+func TestReturnCreditOnFailCountsNoopInsteadOfSilentlyDoingNothing(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditsOnFail(true))
+	before := bw.PeekCredit("")
+
+	bw.returnCreditOnFail("", 0)
+
+	if got := bw.Stats().CreditsOnFailNoop; got != 1 {
+		t.Fatalf("expected CreditsOnFailNoop to be incremented once, got %d", got)
+	}
+	if got := bw.PeekCredit(""); got != before {
+		t.Fatalf("expected the balance to stay unchanged with nothing to return, got %d want %d", got, before)
+	}
+}
+
+func TestUnaryInterceptorClientFallsBackToCreditsOnFailWithoutHeader(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditsOnFail(true))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return errors.New("unavailable")
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err == nil {
+		t.Fatal("expected the invoker error to propagate")
+	}
+
+	// No credits header: falls back to the old add-one-back behavior.
+	if got := bw.PeekCredit(""); got != 1 {
+		t.Fatalf("expected credit balance 1 from creditsOnFail fallback, got %d", got)
+	}
+}
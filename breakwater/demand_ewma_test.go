@@ -0,0 +1,37 @@
+package breakwater
+
+import "testing"
+
+func TestSmoothedDemandSeedsFromFirstSample(t *testing.T) {
+	bw := New(BWParametersDefault)
+	if got := bw.smoothedDemand(10); got != 10 {
+		t.Fatalf("expected first sample to seed the average unchanged, got %d", got)
+	}
+}
+
+func TestSmoothedDemandTracksTowardsSustainedLoad(t *testing.T) {
+	bw := New(BWParametersDefault, WithDemandEWMAAlpha(0.5))
+
+	bw.smoothedDemand(0)
+	first := bw.smoothedDemand(10)
+	second := bw.smoothedDemand(10)
+
+	if first >= 10 {
+		t.Fatalf("expected a momentary spike to only partially move the average, got %d", first)
+	}
+	if second <= first || second > 10 {
+		t.Fatalf("expected the average to keep climbing towards sustained demand, got first=%d second=%d", first, second)
+	}
+}
+
+func TestSmoothedDemandDoesNotAffectRawDemand(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.queueRequest("")
+	bw.queueRequest("")
+
+	bw.smoothedDemand(500) // wildly different from the real queue depth
+
+	if got := bw.Demand(""); got != 2 {
+		t.Fatalf("expected Demand() to stay exact at 2, got %d", got)
+	}
+}
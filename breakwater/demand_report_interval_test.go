@@ -0,0 +1,91 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithDemandReportIntervalOmitsDemandBetweenReports(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithDemandReportInterval(time.Second))
+
+	var sawDemand []bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		_, ok := md["demand"]
+		sawDemand = append(sawDemand, ok)
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		bw.SetCredit("", 1)
+		if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+
+	if want := []bool{true, false, false}; !boolsEqual(sawDemand, want) {
+		t.Fatalf("expected demand reported only on the first call, got %v", sawDemand)
+	}
+
+	clock.Advance(2 * time.Second)
+	bw.SetCredit("", 1)
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := sawDemand[len(sawDemand)-1]; !got {
+		t.Fatal("expected demand to be reported again once the interval elapsed")
+	}
+}
+
+func TestWithoutDemandReportIntervalAlwaysReportsDemand(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	var sawDemand bool
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		_, sawDemand = md["demand"]
+		return nil
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !sawDemand {
+		t.Fatal("expected demand to be reported on every call by default")
+	}
+}
+
+func TestServerFallsBackToLastKnownDemandWhenOmitted(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.RegisterClient("client-a", 7)
+	bw.updateClientDemand("client-a", 7)
+
+	got, ok := bw.lastKnownDemand("client-a")
+	if !ok {
+		t.Fatal("expected client-a to be registered")
+	}
+	if got != 7 {
+		t.Fatalf("expected last known demand 7, got %d", got)
+	}
+
+	if _, ok := bw.lastKnownDemand("unregistered"); ok {
+		t.Fatal("expected an unregistered client to report not-ok")
+	}
+}
+
+func boolsEqual(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package breakwater
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestDemandReturnsToZeroAfterBurst drives a burst of queueRequest/
+// dequeueRequest pairs through many goroutines and asserts Demand ends
+// up back at exactly zero, the property len(pendingOutgoing) already
+// gave when read without racing concurrent writers, but which Demand
+// must also hold now that it's backed by its own counter.
+func TestDemandReturnsToZeroAfterBurst(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	const n = 300
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			if bw.queueRequest("") {
+				bw.dequeueRequest("")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := bw.Demand(""); got != 0 {
+		t.Fatalf("expected Demand to return to 0 after the burst, got %d", got)
+	}
+}
@@ -0,0 +1,93 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestClampDemand(t *testing.T) {
+	tests := []struct {
+		name   string
+		demand int64
+		max    int64
+		want   int64
+	}{
+		{"within range", 10, 100, 10},
+		{"negative clamps to zero", -1, 100, 0},
+		{"over max clamps to max", 1000, 100, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clampDemand(tt.demand, tt.max); got != tt.want {
+				t.Fatalf("clampDemand(%d, %d) = %d, want %d", tt.demand, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func noopHandlerFunc(ctx context.Context, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func TestUnaryInterceptorClampsNegativeDemandToZero(t *testing.T) {
+	params := BWParametersDefault
+	params.ServerSide = true
+	bw := New(params)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("id", "client-1", "demand", "-1"))
+
+	if _, err := bw.UnaryInterceptor(ctx, struct{}{}, nil, noopHandlerFunc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := bw.lastKnownDemand("client-1"); !ok || got != 0 {
+		t.Fatalf("expected negative demand to clamp to 0, got %d (ok=%v)", got, ok)
+	}
+}
+
+func TestUnaryInterceptorCapsDemandAtConfiguredMaximum(t *testing.T) {
+	params := BWParametersDefault
+	params.ServerSide = true
+	bw := New(params, WithMaxDemand(5))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("id", "client-1", "demand", "1000"))
+
+	if _, err := bw.UnaryInterceptor(ctx, struct{}{}, nil, noopHandlerFunc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := bw.lastKnownDemand("client-1"); !ok || got != 5 {
+		t.Fatalf("expected demand to be capped at 5, got %d (ok=%v)", got, ok)
+	}
+}
+
+func TestUnaryInterceptorRejectsOutOfRangeDemand(t *testing.T) {
+	params := BWParametersDefault
+	params.ServerSide = true
+	bw := New(params)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("id", "client-1", "demand", "99999999999999999999"))
+
+	if _, err := bw.UnaryInterceptor(ctx, struct{}{}, nil, noopHandlerFunc); err == nil {
+		t.Fatal("expected an out-of-int64-range demand to be rejected")
+	}
+}
+
+func TestUnaryInterceptorRejectsMalformedDemand(t *testing.T) {
+	params := BWParametersDefault
+	params.ServerSide = true
+	bw := New(params)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("id", "client-1", "demand", "not-a-number"))
+
+	if _, err := bw.UnaryInterceptor(ctx, struct{}{}, nil, noopHandlerFunc); err == nil {
+		t.Fatal("expected a non-numeric demand to be rejected")
+	}
+}
+
+func TestUpdateCreditsFromTrailerIgnoresMalformedCredits(t *testing.T) {
+	bw := New(BWParametersDefault)
+	before := bw.PeekCredit("")
+
+	bw.updateCreditsFromTrailer(metadata.Pairs("credits", "abc"), "")
+
+	if got := bw.PeekCredit(""); got != before {
+		t.Fatalf("expected a malformed credits trailer to leave the balance at %d, got %d", before, got)
+	}
+}
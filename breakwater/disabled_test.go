@@ -0,0 +1,80 @@
+package breakwater
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestDisabledSkipsAdmissionControl(t *testing.T) {
+	bw := New(BWParametersDefault, WithDisabled())
+
+	// Drain the starting credit; a disabled instance must not care.
+	if !bw.TryAcquireCredit("") {
+		t.Fatal("expected the starting credit to be available")
+	}
+
+	invoked := false
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		invoked = true
+		return nil
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !invoked {
+		t.Fatal("expected invoker to be called despite zero credits")
+	}
+	if got := bw.Stats().Bypassed; got != 1 {
+		t.Fatalf("expected Bypassed 1, got %d", got)
+	}
+}
+
+func TestEnableResumesAdmissionControl(t *testing.T) {
+	bw := New(BWParametersDefault, WithDisabled())
+	bw.Enable()
+
+	if !bw.TryAcquireCredit("") {
+		t.Fatal("expected the starting credit to be available")
+	}
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // no credits and an already-cancelled context: must drop, not pass through
+
+	err := bw.UnaryInterceptorClient(ctx, "/svc/Method", nil, nil, nil, invoker)
+	if err == nil {
+		t.Fatal("expected admission control to drop the request once re-enabled")
+	}
+}
+
+func TestDisableIsSafeUnderConcurrentRequests(t *testing.T) {
+	bw := New(BWParametersDefault)
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker)
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bw.Disable()
+			bw.Enable()
+		}()
+	}
+	wg.Wait()
+}
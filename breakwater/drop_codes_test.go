@@ -0,0 +1,56 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithDropCodesOverridesQueueFullCode(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithClientQueueLength(true),
+		WithDropCodes(map[DropReason]codes.Code{QueueFull: codes.Unavailable}),
+	)
+	bw.SetCredit("", 0)
+
+	for i := 0; i < MAX_Q_LENGTH; i++ {
+		if !bw.queueRequest("") {
+			t.Fatalf("expected queue slot %d to be available", i)
+		}
+	}
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError, got %v", err)
+	}
+	if de.Reason != QueueFull {
+		t.Fatalf("expected QueueFull, got %v", de.Reason)
+	}
+	if got := status.Code(de); got != codes.Unavailable {
+		t.Fatalf("expected the overridden code Unavailable, got %v", got)
+	}
+}
+
+func TestDropCodesDefaultToPreExistingCodesWhenUnset(t *testing.T) {
+	bw := New(BWParametersDefault, WithClientQueueLength(true))
+	bw.SetCredit("", 0)
+
+	for i := 0; i < MAX_Q_LENGTH; i++ {
+		if !bw.queueRequest("") {
+			t.Fatalf("expected queue slot %d to be available", i)
+		}
+	}
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError, got %v", err)
+	}
+	if got := status.Code(de); got != codes.ResourceExhausted {
+		t.Fatalf("expected the unmodified default ResourceExhausted, got %v", got)
+	}
+}
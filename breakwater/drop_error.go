@@ -0,0 +1,179 @@
+package breakwater
+
+import (
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dropErrorDomain identifies this package as the source of the
+// ErrorInfo detail GRPCStatus attaches, per google.rpc.ErrorInfo's
+// convention of a reverse-DNS-ish domain string.
+const dropErrorDomain = "breakwater"
+
+/*
+DropReason identifies why the client interceptor declined to send a
+request, so callers can branch on it with errors.As instead of
+string-matching a status message.
+*/
+type DropReason int
+
+const (
+	// QueueFull means the client's outgoing queue already held
+	// queueCapacity requests when this one arrived.
+	QueueFull DropReason = iota
+	// Expired means the request waited past its client-side expiration
+	// budget (clientExpiration) or past its context deadline.
+	Expired
+	// Cancelled means the caller's context was cancelled while the
+	// request was queued or waiting for a credit.
+	Cancelled
+	// ShuttingDown means Close was called on this Breakwater instance
+	// while the request was queued, waiting for a credit, or had not yet
+	// been admitted.
+	ShuttingDown
+	// InFlightLimit means the request already held a credit but was
+	// dropped because WithMaxInFlight's cap on simultaneous invoker calls
+	// was already reached.
+	InFlightLimit
+	// Stalled means the request was parked waiting for a credit for
+	// longer than WithStallTimeout's configured duration, and
+	// WithStallTimeout was configured to fail such requests rather than
+	// just report them via OnStall.
+	Stalled
+	// CreditTimeout means the request was parked waiting for a credit for
+	// longer than WithCreditWaitTimeout's configured duration. Unlike
+	// Expired, this is specific to the noCreditBlocker wait and is meant
+	// to fire well before the overall expiration budget, so it can
+	// distinguish "server slow" from "server dead".
+	CreditTimeout
+	// InsufficientCredit means AcquireN could not acquire any credits:
+	// under AllOrNothing, fewer than the requested n were available;
+	// under BestEffort, none were available at all. See AcquireNPolicy.
+	InsufficientCredit
+	// CircuitOpen means WithCircuitBreaker's breaker was tripped open (or
+	// half-open and already probing) when the request arrived, so it was
+	// failed fast before ever reaching the queue. See circuit_breaker.go.
+	CircuitOpen
+)
+
+func (r DropReason) String() string {
+	switch r {
+	case QueueFull:
+		return "queue_full"
+	case Expired:
+		return "expired"
+	case Cancelled:
+		return "cancelled"
+	case ShuttingDown:
+		return "shutting_down"
+	case InFlightLimit:
+		return "in_flight_limit"
+	case Stalled:
+		return "stalled"
+	case CreditTimeout:
+		return "credit_timeout"
+	case InsufficientCredit:
+		return "insufficient_credit"
+	case CircuitOpen:
+		return "circuit_open"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+DropError is returned by the client interceptors when a request is
+dropped before it is sent. It implements error and GRPCStatus, so it
+keeps producing the same gRPC status code on the wire for callers that
+don't inspect it, while callers that do can recover the structured
+Reason with errors.As:
+
+	var de *breakwater.DropError
+	if errors.As(err, &de) {
+	    switch de.Reason {
+	    case breakwater.QueueFull: ...
+	    }
+	}
+*/
+type DropError struct {
+	Reason  DropReason
+	Code    codes.Code
+	Message string
+	// Err is the underlying error, when the drop originated from a
+	// context error (ctx.Err()); nil for drops synthesized directly by
+	// the interceptor (queue full, client-side expiration).
+	Err error
+	// ClientID is this instance's WithID string, so a caller on the other
+	// side of a chained setup can tell which hop dropped the request
+	// without having to parse Message.
+	ClientID string
+	// WaitedUs is how long the request waited before being dropped,
+	// matching the waitedUs already passed to noteDrop/fireOnDrop at each
+	// drop site.
+	WaitedUs int64
+}
+
+func (e *DropError) Error() string {
+	return e.Message
+}
+
+// Unwrap lets errors.Is/errors.As see through to the originating
+// context error, when there is one.
+func (e *DropError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus implements the interface github.com/grpc/grpc-go's
+// status.FromError checks for, so *DropError keeps mapping to Code on
+// the wire exactly like the status.Errorf it replaces. It also attaches
+// a google.rpc.ErrorInfo detail carrying Reason, ClientID, and WaitedUs,
+// so server-side access logs and callers in a chained setup can
+// introspect the drop without string parsing.
+func (e *DropError) GRPCStatus() *status.Status {
+	plain := status.New(e.Code, e.Message)
+	withDetails, err := plain.WithDetails(&errdetails.ErrorInfo{
+		Reason: e.Reason.String(),
+		Domain: dropErrorDomain,
+		Metadata: map[string]string{
+			"client_id": e.ClientID,
+			"waited_us": strconv.FormatInt(e.WaitedUs, 10),
+		},
+	})
+	if err != nil {
+		// WithDetails only fails if Code is OK, which a drop never is;
+		// fall back to the plain status rather than lose the drop itself
+		// over a detail that couldn't be attached.
+		return plain
+	}
+	return withDetails
+}
+
+// dropErrorFromContext builds a DropError for a drop triggered by a
+// cancelled or expired context, translating ctx.Err() to the matching
+// gRPC code the same way grpc-go's own status.FromContextError does,
+// unless WithDropCodes overrides reason's code.
+func (b *Breakwater) dropErrorFromContext(reason DropReason, waitedUs int64, err error) *DropError {
+	s := status.FromContextError(err)
+	return &DropError{
+		Reason:   reason,
+		Code:     b.dropCode(reason, s.Code()),
+		Message:  s.Message(),
+		Err:      err,
+		ClientID: b.idStr,
+		WaitedUs: waitedUs,
+	}
+}
+
+// dropCode returns the gRPC code WithDropCodes maps reason to, or
+// fallback when no override was configured for reason. fallback is the
+// code each drop site would have used before WithDropCodes existed, so
+// an operator who never calls it sees identical behavior to before.
+func (b *Breakwater) dropCode(reason DropReason, fallback codes.Code) codes.Code {
+	if code, ok := b.dropCodes[reason]; ok {
+		return code
+	}
+	return fallback
+}
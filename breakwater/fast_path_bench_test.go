@@ -0,0 +1,45 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+/*
+BenchmarkAcquireCreditLoopFastPath measures acquireCreditLoop's
+uncontended fast path: an empty queue and a comfortably positive
+balance, so every call resolves via tryFastPathAdmit's single CAS
+without ever touching queueRequest or noCreditBlockerFor.
+*/
+func BenchmarkAcquireCreditLoopFastPath(b *testing.B) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bw.SetCredit("", 1)
+		if _, err := bw.acquireCreditLoop(ctx, bw.clock.Now(), ""); err != nil {
+			b.Fatalf("unexpected drop: %v", err)
+		}
+	}
+}
+
+/*
+BenchmarkAcquireCreditLoopSlowPath measures the same admission with the
+fast path disabled via WithFIFO, so every call pays the full
+queueRequest/noCreditBlockerFor/priority-registry dance the fast path
+above skips, for a side-by-side comparison.
+*/
+func BenchmarkAcquireCreditLoopSlowPath(b *testing.B) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	WithFIFO(true)(bw)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bw.SetCredit("", 1)
+		if _, err := bw.acquireCreditLoop(ctx, bw.clock.Now(), ""); err != nil {
+			b.Fatalf("unexpected drop: %v", err)
+		}
+	}
+}
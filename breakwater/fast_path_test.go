@@ -0,0 +1,91 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTryFastPathAdmitTakesACreditWithoutQueueing(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 5)
+
+	demand, ok := bw.tryFastPathAdmit(context.Background(), "", 1)
+	if !ok {
+		t.Fatal("expected the fast path to admit an uncontended request")
+	}
+	if demand != 0 {
+		t.Fatalf("expected demand to stay 0 since the fast path never queues, got %d", demand)
+	}
+	if got := bw.PeekCredit(""); got != 4 {
+		t.Fatalf("expected the balance to drop by cost, got %d", got)
+	}
+}
+
+func TestTryFastPathAdmitFailsWhenBalanceTooLow(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 0)
+
+	if _, ok := bw.tryFastPathAdmit(context.Background(), "", 1); ok {
+		t.Fatal("expected the fast path to decline an empty balance")
+	}
+}
+
+func TestTryFastPathAdmitFailsWhenQueueIsNotEmpty(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 5)
+	bw.queueRequest("")
+	defer bw.dequeueRequest("")
+
+	if _, ok := bw.tryFastPathAdmit(context.Background(), "", 1); ok {
+		t.Fatal("expected the fast path to defer to the slow path under contention")
+	}
+}
+
+func TestTryFastPathAdmitFailsWithFIFOEnabled(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	WithFIFO(true)(bw)
+	bw.SetCredit("", 5)
+
+	if _, ok := bw.tryFastPathAdmit(context.Background(), "", 1); ok {
+		t.Fatal("expected the fast path to be disabled under WithFIFO")
+	}
+}
+
+func TestTryFastPathAdmitFailsWithCustomAdmissionPolicy(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	WithAdmissionPolicy(rejectAllPolicy{})(bw)
+	bw.SetCredit("", 5)
+
+	if _, ok := bw.tryFastPathAdmit(context.Background(), "", 1); ok {
+		t.Fatal("expected the fast path to be disabled under a non-default AdmissionPolicy")
+	}
+}
+
+func TestTryFastPathAdmitFailsPastDeadline(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 5)
+
+	ctx, cancel := context.WithDeadline(context.Background(), bw.clock.Now().Add(-time.Second))
+	defer cancel()
+
+	if _, ok := bw.tryFastPathAdmit(ctx, "", 1); ok {
+		t.Fatal("expected the fast path to decline a request already past its deadline")
+	}
+}
+
+func TestAcquireCreditLoopUsesFastPathWhenUncontended(t *testing.T) {
+	bw := InitBreakwater(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 1)
+
+	demand, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	if err != nil {
+		t.Fatalf("unexpected drop: %v", err)
+	}
+	if demand != 0 {
+		t.Fatalf("expected demand 0 from the fast path, got %d", demand)
+	}
+	if got := bw.PeekCredit(""); got != 0 {
+		t.Fatalf("expected the credit to be spent, got %d", got)
+	}
+}
@@ -0,0 +1,44 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+/*
+TestWaitForTurnOrdersByTicket exercises waitForTurn/advanceTicket
+directly: tickets are requested to wait in reverse order (n-1 first),
+but since each one parks until servingTicket reaches it, advancing the
+ticket in ascending order must release them in ascending order
+regardless of the order they started waiting in.
+*/
+func TestWaitForTurnOrdersByTicket(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+	bw.fifo = true
+
+	const n = 5
+	done := make(chan int, n)
+
+	for i := n - 1; i >= 0; i-- {
+		ticket := int64(i)
+		go func() {
+			if err := bw.waitForTurn(context.Background(), ticket); err != nil {
+				t.Errorf("waitForTurn(%d) returned error: %v", ticket, err)
+				return
+			}
+			done <- int(ticket)
+		}()
+	}
+
+	// Ticket 0 is already "serving" (servingTicket's zero value), so
+	// release it first, then walk the rest forward in order.
+	for i := 0; i < n; i++ {
+		got := <-done
+		if got != i {
+			t.Fatalf("ticket %d finished out of order, expected %d", got, i)
+		}
+		if i < n-1 {
+			bw.advanceTicket()
+		}
+	}
+}
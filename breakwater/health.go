@@ -0,0 +1,84 @@
+package breakwater
+
+import (
+	"time"
+)
+
+// OverloadLevel summarizes this instance's recent admission behavior for
+// something like a gRPC health service to report on, without that caller
+// needing to understand credits, queues, or drop reasons itself.
+type OverloadLevel int
+
+const (
+	// OverloadNormal means no drops in the current window and a
+	// non-scarce credit balance: admit as usual.
+	OverloadNormal OverloadLevel = iota
+	// OverloadDegraded means at least one drop occurred in the current
+	// window, or the credit balance has hit creditFloor, but the drop
+	// rate hasn't crossed overloadSheddingThreshold yet.
+	OverloadDegraded
+	// OverloadShedding means the drop rate in the current window is at
+	// or beyond overloadSheddingThreshold: this instance is actively
+	// shedding load, not just occasionally dropping.
+	OverloadShedding
+)
+
+func (l OverloadLevel) String() string {
+	switch l {
+	case OverloadNormal:
+		return "Normal"
+	case OverloadDegraded:
+		return "Degraded"
+	case OverloadShedding:
+		return "Shedding"
+	default:
+		return "Unknown"
+	}
+}
+
+// defaultOverloadWindow and defaultOverloadSheddingThreshold are the
+// fallbacks InitBreakwater seeds every instance with; see
+// WithOverloadWindow and WithOverloadSheddingThreshold to override them.
+const (
+	defaultOverloadWindow            = 10 * time.Second
+	defaultOverloadSheddingThreshold = 0.5
+)
+
+// rollOverloadWindow resets windowRequests/windowDrops once
+// overloadWindow has elapsed since they were last reset, so OverloadLevel
+// reflects recent behavior instead of an all-time total. Like
+// zeroCreditSince, overloadWindowStart holds UnixNano and is advanced
+// with a CAS so concurrent callers agree on a single winner to do the
+// reset.
+func (b *Breakwater) rollOverloadWindow() {
+	now := b.clock.Now().UnixNano()
+	start := b.overloadWindowStart.Load()
+	if start != 0 && time.Duration(now-start) < b.overloadWindow {
+		return
+	}
+	if b.overloadWindowStart.CompareAndSwap(start, now) {
+		b.windowRequests.Store(0)
+		b.windowDrops.Store(0)
+	}
+}
+
+// OverloadLevel reports this instance's current view of its own health,
+// derived from the drop rate over the last overloadWindow and whether
+// the outgoing credit balance is currently scarce (at or below
+// creditFloor). It's cheap enough to call from a health check's hot
+// path: no locking beyond the atomics rollOverloadWindow already uses.
+func (b *Breakwater) OverloadLevel() OverloadLevel {
+	b.rollOverloadWindow()
+
+	drops := b.windowDrops.Load()
+	total := b.windowRequests.Load()
+	scarce := b.PeekCredit("") <= b.creditFloor.Load()
+
+	if total > 0 && float64(drops)/float64(total) >= b.overloadSheddingThreshold {
+		return OverloadShedding
+	}
+	if drops > 0 || scarce {
+		return OverloadDegraded
+	}
+	return OverloadNormal
+}
@@ -0,0 +1,44 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOverloadLevelNormalWithNoDropsAndHealthyCredit(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 10)
+
+	if _, err := bw.acquireCredit(context.Background(), bw.clock.Now(), ""); err != nil {
+		t.Fatalf("unexpected drop: %v", err)
+	}
+
+	if got := bw.OverloadLevel(); got != OverloadNormal {
+		t.Fatalf("expected OverloadNormal, got %v", got)
+	}
+}
+
+func TestOverloadLevelDegradedOnScarceCredit(t *testing.T) {
+	bw := New(BWParametersDefault, WithCreditFloor(0))
+	bw.SetCredit("", 0)
+
+	if got := bw.OverloadLevel(); got != OverloadDegraded {
+		t.Fatalf("expected OverloadDegraded once credit is at the floor, got %v", got)
+	}
+}
+
+func TestOverloadLevelSheddingOnceDropRateCrossesThreshold(t *testing.T) {
+	bw := New(BWParametersDefault, WithOverloadSheddingThreshold(0.5))
+	bw.SetCredit("", 10)
+
+	// Two admitted requests, two drops: a 50% drop rate, at the
+	// threshold configured above. acquireCreditLoop counts every arrival
+	// in windowRequests, dropped or not, so that is four requests.
+	bw.windowRequests.Add(4)
+	bw.fireOnDrop(QueueFull, 0)
+	bw.fireOnDrop(QueueFull, 0)
+
+	if got := bw.OverloadLevel(); got != OverloadShedding {
+		t.Fatalf("expected OverloadShedding at a 50%% drop rate, got %v", got)
+	}
+}
@@ -0,0 +1,70 @@
+package breakwater
+
+import (
+	"testing"
+	"time"
+)
+
+// forcePostRTT makes the next updateCreditsToIssue call for every
+// registered client take the demand-driven branch instead of the
+// auto-decrement one: that branch only runs once per RTT_MICROSECOND
+// window, keyed on whether a connection's lastUpdated is before the
+// instance's lastUpdateTime.
+func forcePostRTT(bw *Breakwater) {
+	bw.lastUpdateTime = time.Now()
+}
+
+func TestWithMaxCreditsPerClientClampsIssuedCredits(t *testing.T) {
+	bw := New(BWParameters{InitialCredits: 100}, WithMaxCreditsPerClient(2))
+	bw.RegisterClient("hog", 1000)
+	forcePostRTT(bw)
+
+	issued, _ := bw.updateCreditsToIssue("hog", 1000)
+	if issued > 2 {
+		t.Fatalf("expected issued credits capped at 2, got %d", issued)
+	}
+
+	stats := bw.ServerStats()
+	if got := stats.PerClientIssued["hog"]; got != issued {
+		t.Fatalf("expected PerClientIssued to report %d, got %d", issued, got)
+	}
+}
+
+func TestWithoutMaxCreditsPerClientBehavesExactlyAsBefore(t *testing.T) {
+	bw := New(BWParameters{InitialCredits: 100})
+	bw.RegisterClient("hog", 1000)
+	forcePostRTT(bw)
+
+	issued, _ := bw.updateCreditsToIssue("hog", 1000)
+	if issued <= 2 {
+		t.Fatalf("expected an uncapped instance to issue more than the cap used elsewhere, got %d", issued)
+	}
+}
+
+func TestMaxCreditsPerClientLeavesRoomForOtherClients(t *testing.T) {
+	// Two clients sharing a small pool: the cap applies uniformly, but a
+	// hog that would otherwise have captured most of cTotal on its own
+	// now only consumes the cap's worth, leaving the rest of the pool
+	// free for fair -- the "redistribution" is just that the capped
+	// client's clamp keeps cIssued from ever reflecting its full
+	// uncapped demand.
+	bw := New(BWParameters{InitialCredits: 10}, WithMaxCreditsPerClient(2))
+	bw.RegisterClient("hog", 1000)
+	bw.RegisterClient("fair", 1)
+	forcePostRTT(bw)
+
+	hogIssued, _ := bw.updateCreditsToIssue("hog", 1000)
+	if hogIssued != 2 {
+		t.Fatalf("expected the capped client to be issued exactly 2, got %d", hogIssued)
+	}
+
+	stats := bw.ServerStats()
+	if stats.IssuedCredits != hogIssued {
+		t.Fatalf("expected the pool to reflect only the capped amount (%d), got %d", hogIssued, stats.IssuedCredits)
+	}
+
+	fairIssued, _ := bw.updateCreditsToIssue("fair", 1)
+	if fairIssued < 1 {
+		t.Fatalf("expected the pool capacity hog never claimed to still be available to fair, got %d", fairIssued)
+	}
+}
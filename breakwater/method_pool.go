@@ -0,0 +1,271 @@
+package breakwater
+
+import "sync/atomic"
+
+/*
+MethodConfig overrides the default, instance-wide credit pool for one
+gRPC method, so two RPCs sharing a connection -- one cheap, one
+expensive -- don't share a single credit balance and queue. Set via
+WithMethodConfig.
+*/
+type MethodConfig struct {
+	InitialCredits int64 // starting credit balance for this method's pool
+	CreditFloor    int64 // minimum balance updateCreditsFromTrailer settles on for this method, like Breakwater.creditFloor but scoped to it
+	QueueCapacity  int64 // size of this method's pendingOutgoing queue; <=0 falls back to the instance-wide queueCapacity
+}
+
+/*
+methodPool holds one method's independent credit balance, outgoing
+queue, and parking channel: the same three pieces of state Breakwater
+itself holds a single instance-wide copy of for methods without an
+override. acquireCredit and friends are parameterized over *methodPool
+so the wait/acquire/release logic is written once and shared between the
+default, instance-wide pool and every per-method override.
+*/
+type methodPool struct {
+	outgoingCredits atomic.Int64
+	noCreditBlocker chan int64
+	pendingOutgoing chan int64
+	creditFloor     int64
+	// initialCredits is this pool's starting balance, from MethodConfig
+	// (or the partition default of 1); Reset restores outgoingCredits to
+	// this rather than to the instance-wide default.
+	initialCredits int64
+	// demandCount is the exact count of requests currently queued in
+	// pendingOutgoing, maintained alongside it rather than read via
+	// len(pendingOutgoing), which races against concurrent queue/dequeue.
+	demandCount atomic.Int64
+	// priorityQueue is this pool's own admission-order tracker, separate
+	// from Breakwater.priorityQueue so a MethodConfig override's waiters
+	// only compete against each other; see priority_queue.go.
+	priorityQueue *priorityWaitQueue
+	// dropOldestQueue is this pool's own FIFO eviction tracker for
+	// QueueFullPolicy's DropOldest, separate from Breakwater.dropOldestQueue
+	// for the same reason priorityQueue is; see queue_full_policy.go.
+	dropOldestQueue *dropOldestQueue
+}
+
+func newMethodPool(cfg MethodConfig, fallbackQueueCapacity int64, priorityAgingPerSecond float64) *methodPool {
+	capacity := cfg.QueueCapacity
+	if capacity <= 0 {
+		capacity = fallbackQueueCapacity
+	}
+	p := &methodPool{
+		// See Breakwater.noCreditBlocker for why this is buffered to
+		// capacity rather than 1.
+		noCreditBlocker: make(chan int64, capacity),
+		pendingOutgoing: make(chan int64, capacity),
+		creditFloor:     cfg.CreditFloor,
+		initialCredits:  cfg.InitialCredits,
+		priorityQueue:   newPriorityWaitQueue(priorityAgingPerSecond),
+		dropOldestQueue: newDropOldestQueue(),
+	}
+	p.noCreditBlocker <- 1
+	p.outgoingCredits.Store(cfg.InitialCredits)
+	return p
+}
+
+// unblockChan sends up to n non-blocking tokens into ch, one per send,
+// stopping the moment a send would block. ch is always sized to hold at
+// least as many tokens as there can be waiters (see
+// Breakwater.noCreditBlocker), so stopping early means enough tokens are
+// already parked there for waiters to pick up -- not that some of n was
+// dropped. n <= 0 is treated as 1, so a caller that doesn't know the
+// exact delta still gets the single-wakeup behavior this replaced.
+func unblockChan(ch chan int64, n int64) {
+	if n <= 0 {
+		n = 1
+	}
+	for i := int64(0); i < n; i++ {
+		select {
+		case ch <- 1:
+		default:
+			return
+		}
+	}
+}
+
+// unblock wakes exactly one waiter, for call sites where the credit
+// balance only moved by one (or where the exact delta isn't known).
+func (p *methodPool) unblock() {
+	p.unblockN(1)
+}
+
+// unblockN wakes up to n parked waiters at once instead of one, for a
+// credit balance that just became available to more than one of them in
+// a single update (eg a server-reported jump covering a burst of
+// credits). It never wakes more than n, so a modest jump doesn't
+// needlessly bounce extra waiters back to sleep; see unblockChan for why
+// it also can't over-fill the channel.
+func (p *methodPool) unblockN(n int64) {
+	unblockChan(p.noCreditBlocker, n)
+}
+
+func (p *methodPool) tryAcquire() bool {
+	return p.tryAcquireN(1)
+}
+
+func (p *methodPool) tryAcquireN(n int64) bool {
+	if n <= 0 {
+		n = 1
+	}
+	for {
+		cur := p.outgoingCredits.Load()
+		if cur < n {
+			return false
+		}
+		if p.outgoingCredits.CompareAndSwap(cur, cur-n) {
+			if remaining := cur - n; remaining > 0 {
+				p.unblockN(remaining)
+			}
+			return true
+		}
+	}
+}
+
+// tryAcquireUpToN debits min(cur, n) credits atomically and returns how
+// many it took, for AcquireN's BestEffort policy. It returns 0 without
+// touching the balance if cur<=0, rather than returning a negative or
+// zero-sized "grant".
+func (p *methodPool) tryAcquireUpToN(n int64) int64 {
+	if n <= 0 {
+		n = 1
+	}
+	for {
+		cur := p.outgoingCredits.Load()
+		if cur <= 0 {
+			return 0
+		}
+		want := min(cur, n)
+		if p.outgoingCredits.CompareAndSwap(cur, cur-want) {
+			if remaining := cur - want; remaining > 0 {
+				p.unblockN(remaining)
+			}
+			return want
+		}
+	}
+}
+
+func (p *methodPool) release(n int64) {
+	after := p.outgoingCredits.Add(n)
+	if after-n <= 0 {
+		p.unblockN(n)
+	}
+}
+
+func (p *methodPool) set(v int64) {
+	prev := p.outgoingCredits.Swap(v)
+	if v > 0 {
+		p.unblockN(max(v-max(prev, 0), 1))
+	}
+}
+
+func (p *methodPool) peek() int64 {
+	return p.outgoingCredits.Load()
+}
+
+/*
+merge folds a server-reported credit balance into this pool's
+outgoingCredits as a single CAS-loop critical section, instead of a
+separate peek-then-set that could lose a concurrent update between the
+two. When hasReported is false (no "credits" trailer), it floors the
+current balance in place rather than a stale snapshot taken before the
+loop started.
+*/
+func (p *methodPool) merge(reported int64, hasReported bool, floor int64) int64 {
+	for {
+		cur := p.outgoingCredits.Load()
+		next := cur
+		if hasReported {
+			next = reported
+		}
+		if next < floor {
+			next = floor
+		}
+		if p.outgoingCredits.CompareAndSwap(cur, next) {
+			if next > 0 {
+				// Wake as many waiters as credits that just became newly
+				// available, not just one, so a burst grant (eg a server
+				// catching this client back up after a quiet period)
+				// doesn't cascade through the queue one at a time.
+				p.unblockN(max(next-max(cur, 0), 1))
+			}
+			return next
+		}
+	}
+}
+
+// mergeDelta is merge's Additive-mode counterpart: it adds delta to the
+// current balance instead of replacing it with a reported value.
+func (p *methodPool) mergeDelta(delta int64, floor int64) int64 {
+	for {
+		cur := p.outgoingCredits.Load()
+		next := cur + delta
+		if next < floor {
+			next = floor
+		}
+		if p.outgoingCredits.CompareAndSwap(cur, next) {
+			if next > 0 {
+				p.unblockN(max(next-max(cur, 0), 1))
+			}
+			return next
+		}
+	}
+}
+
+func (p *methodPool) queue() bool {
+	select {
+	case p.pendingOutgoing <- 1:
+		p.demandCount.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *methodPool) dequeue() bool {
+	select {
+	case <-p.pendingOutgoing:
+		p.demandCount.Add(-1)
+		return true
+	default:
+		return false
+	}
+}
+
+// demand returns the exact number of requests currently queued, read
+// from demandCount rather than len(pendingOutgoing).
+func (p *methodPool) demand() int {
+	return int(p.demandCount.Load())
+}
+
+// poolFor resolves key's override pool, creating it on first use, or
+// returns nil when key has no override (including the "" sentinel used
+// by call sites that aren't method-aware) so the caller falls back to
+// Breakwater's own instance-wide balance and queue. key is usually a
+// gRPC method, but UnaryInterceptorClient/StreamInterceptorClient widen
+// it to "method\x1fpartition" when WithCreditPartition is set; such a
+// composite key has no entry in methodConfigs, so it falls back to the
+// same defaults the instance-wide pool itself uses (creditFloor,
+// queueCapacity, starting at 1 credit) rather than returning nil, since
+// partitions are discovered dynamically and can't be pre-registered with
+// WithMethodConfig. The same defaults apply to an unconfigured service
+// key under PoolScopeService, so every service gets its own pool.
+func (b *Breakwater) poolFor(key string) *methodPool {
+	if key == "" {
+		return nil
+	}
+	if p, ok := b.methodPools.Load(key); ok {
+		return p.(*methodPool)
+	}
+	cfg, ok := b.methodConfigs[key]
+	if !ok {
+		if b.partitionKeyFunc == nil && b.poolScope != PoolScopeService {
+			return nil
+		}
+		cfg = MethodConfig{InitialCredits: 1, CreditFloor: b.creditFloor.Load(), QueueCapacity: b.queueCapacity.Load()}
+	}
+	p := newMethodPool(cfg, b.queueCapacity.Load(), b.priorityAgingPerSecond)
+	actual, _ := b.methodPools.LoadOrStore(key, p)
+	return actual.(*methodPool)
+}
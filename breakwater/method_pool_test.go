@@ -0,0 +1,50 @@
+package breakwater
+
+import "testing"
+
+func TestMethodConfigIsolatesCreditBalance(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithMethodConfig("/svc/Expensive", MethodConfig{InitialCredits: 1, QueueCapacity: 4}),
+	)
+
+	// Draining the expensive method's single credit must not touch the
+	// instance-wide balance used by every other method.
+	if !bw.TryAcquireCredit("/svc/Expensive") {
+		t.Fatal("expected the first credit on /svc/Expensive to be available")
+	}
+	if bw.TryAcquireCredit("/svc/Expensive") {
+		t.Fatal("expected /svc/Expensive to be out of credits after the first acquire")
+	}
+	if !bw.TryAcquireCredit("/svc/Cheap") {
+		t.Fatal("expected an unconfigured method to draw from the untouched instance-wide balance")
+	}
+
+	if got := bw.PeekCredit("/svc/Expensive"); got != 0 {
+		t.Fatalf("expected /svc/Expensive balance to be 0, got %d", got)
+	}
+	// The instance-wide balance always starts at 1 regardless of
+	// BWParameters.InitialCredits (which seeds the server-side cTotal pool,
+	// not the client's own outgoing balance), so draining it once leaves 0.
+	if got := bw.PeekCredit("/svc/Cheap"); got != 0 {
+		t.Fatalf("expected /svc/Cheap to report the drained instance-wide balance, got %d", got)
+	}
+}
+
+func TestMethodConfigQueueIsolation(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithMethodConfig("/svc/Expensive", MethodConfig{InitialCredits: 0, QueueCapacity: 1}),
+	)
+
+	if !bw.queueRequest("/svc/Expensive") {
+		t.Fatal("expected the first queue slot on /svc/Expensive to succeed")
+	}
+	if bw.queueRequest("/svc/Expensive") {
+		t.Fatal("expected /svc/Expensive's 1-deep queue to be full")
+	}
+	if bw.getDemand("/svc/Expensive") != 1 {
+		t.Fatalf("expected /svc/Expensive demand to be 1, got %d", bw.getDemand("/svc/Expensive"))
+	}
+	if bw.getDemand("/svc/Cheap") != 0 {
+		t.Fatalf("expected an unconfigured method's demand to be unaffected, got %d", bw.getDemand("/svc/Cheap"))
+	}
+}
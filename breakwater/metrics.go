@@ -0,0 +1,27 @@
+package breakwater
+
+// MetricsRecorder receives callbacks from a Breakwater instance at its
+// existing decision points, so that an observability backend (Prometheus,
+// OpenTelemetry, or an in-house system) can be wired in without this
+// package depending on any particular client library. See the
+// prometheus subpackage for a ready-made Prometheus-backed recorder.
+type MetricsRecorder interface {
+	// OnAdmit is called once a request successfully acquires a credit.
+	OnAdmit(id string, creditBalance int64, demand int64)
+	// OnDrop is called when a request is dropped before it is sent, e.g.
+	// for a full queue or an expired wait. reason is a short, stable
+	// machine-readable tag such as "queue_full" or "expired".
+	OnDrop(id string, reason string)
+	// OnCreditUpdate is called whenever the outgoing credit balance is
+	// updated from a server response trailer.
+	OnCreditUpdate(id string, creditBalance int64)
+}
+
+// WithMetricsRecorder attaches r to the Breakwater instance; its methods
+// are invoked from the client interceptor hot path, so implementations
+// must be cheap and safe for concurrent use.
+func WithMetricsRecorder(r MetricsRecorder) Option {
+	return func(bw *Breakwater) {
+		bw.metrics = r
+	}
+}
@@ -0,0 +1,35 @@
+package breakwater
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithOnDropFiresOnQueueFull(t *testing.T) {
+	var calls atomic.Int64
+	var lastReason DropReason
+	bw := New(BWParametersDefault,
+		WithClientQueueLength(true),
+		WithOnDrop(func(reason DropReason, id string, waitedUs int64) {
+			calls.Add(1)
+			lastReason = reason
+			if id == "" {
+				t.Error("expected a non-empty client id")
+			}
+		}),
+	)
+	bw.pendingOutgoing = make(chan int64) // capacity 0: queueRequest always fails
+
+	if _, err := bw.acquireCredit(context.Background(), time.Now(), ""); err == nil {
+		t.Fatal("expected the request to be dropped for a full queue")
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected WithOnDrop to fire exactly once, got %d", got)
+	}
+	if lastReason != QueueFull {
+		t.Fatalf("expected QueueFull, got %v", lastReason)
+	}
+}
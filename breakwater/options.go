@@ -0,0 +1,816 @@
+package breakwater
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+)
+
+// Option configures a Breakwater instance created via New. Options let
+// multiple Breakwater instances run with different policies in the same
+// process, instead of relying on the deprecated package-level globals.
+type Option func(*Breakwater)
+
+// WithClientQueueLength toggles whether the client interceptor enforces
+// the outgoing queue length limit (MAX_Q_LENGTH, or WithQueueCapacity if
+// set). Equivalent to the deprecated useClientQueueLength global.
+func WithClientQueueLength(enabled bool) Option {
+	return func(bw *Breakwater) {
+		bw.useClientQueueLength = enabled
+	}
+}
+
+// WithClientTimeExpiration toggles whether queued requests are dropped
+// once they exceed clientExpiration. Equivalent to the deprecated
+// useClientTimeExpiration global.
+func WithClientTimeExpiration(enabled bool) Option {
+	return func(bw *Breakwater) {
+		bw.useClientTimeExpiration = enabled
+	}
+}
+
+// WithCreditsOnFail toggles whether a credit is returned to the pool when
+// the downstream invoker call fails. Equivalent to the deprecated
+// creditsOnFail global.
+func WithCreditsOnFail(enabled bool) Option {
+	return func(bw *Breakwater) {
+		bw.creditsOnFail = enabled
+	}
+}
+
+// WithStreamCreditInterval makes StreamInterceptorClient debit one extra
+// credit every n messages sent on a long-lived stream, on top of the single
+// credit acquired when the stream is opened. A non-positive n disables
+// per-message debiting (the default).
+func WithStreamCreditInterval(n int64) Option {
+	return func(bw *Breakwater) {
+		bw.streamCreditInterval = n
+	}
+}
+
+// WithLogger routes a Breakwater instance's structured logging through l
+// instead of the default no-op logger. Pass a logger configured at
+// slog.LevelDebug to see the verbose per-request tracing the old printf
+// logger used to emit under Verbose.
+func WithLogger(l *slog.Logger) Option {
+	return func(bw *Breakwater) {
+		bw.slogger = l
+	}
+}
+
+// WithAFactor overrides the additive factor used to grow cTotal when the
+// measured server-side queueing delay is within thresholdDelay. Equivalent
+// to BWParameters.AFactor, but settable per-instance after construction.
+func WithAFactor(a float64) Option {
+	return func(bw *Breakwater) {
+		bw.aFactor = a
+	}
+}
+
+// WithBFactor overrides the multiplicative factor used to shrink cTotal
+// when the measured server-side queueing delay exceeds thresholdDelay.
+// Equivalent to BWParameters.BFactor, but settable per-instance after
+// construction.
+func WithBFactor(b float64) Option {
+	return func(bw *Breakwater) {
+		bw.bFactor = b
+	}
+}
+
+// WithThresholdDelay overrides the target server-side queueing delay (in
+// microseconds) that the AQM control loop tries to stay under. aqmDelay,
+// the harder load-shedding threshold, is kept at 2x thresholdDelay, the
+// same ratio InitBreakwater uses for BWParameters.SLO.
+func WithThresholdDelay(thresholdDelayMicros float64) Option {
+	return func(bw *Breakwater) {
+		bw.thresholdDelay.Store(thresholdDelayMicros)
+		bw.aqmDelay.Store(thresholdDelayMicros * 2.0)
+	}
+}
+
+// WithClientTTL makes the server-side interceptor evict a client's
+// Connection entry (and the credits it holds in cIssued) once ttl has
+// passed since that client was last seen, bounding clientMap's size under
+// long-running churn. A non-positive ttl (the default) disables eviction,
+// matching prior behavior.
+func WithClientTTL(ttl time.Duration) Option {
+	return func(bw *Breakwater) {
+		bw.clientTTL = ttl
+	}
+}
+
+// WithCreditFloor overrides the minimum outgoing credit balance that
+// updateCreditsFromTrailer will settle on, in place of the previously
+// hardcoded 1. The default of 1 guarantees a client can always make
+// forward progress. A floor of 0 lets an aggressively load-shedding
+// server fully pause a client; pairing it with WithZeroCreditWarning is
+// recommended, since a floor of 0 depends on the server eventually
+// sending a positive credit again, or the client stalls forever. A floor
+// above 1 trades admission-control precision for latency on flows that
+// cannot tolerate being paused down to a single in-flight request.
+func WithCreditFloor(floor int64) Option {
+	return func(bw *Breakwater) {
+		bw.creditFloor.Store(floor)
+	}
+}
+
+// WithMaxDemand overrides defaultMaxDemand, the cap UnaryInterceptor
+// applies to the "demand" value a client reports. max must be positive;
+// a non-positive value is ignored and the default is kept, rather than
+// disabling the cap entirely.
+func WithMaxDemand(max int64) Option {
+	return func(bw *Breakwater) {
+		if max <= 0 {
+			bw.logWarn("WithMaxDemand requires a positive value, ignoring")
+			return
+		}
+		bw.maxDemand.Store(max)
+	}
+}
+
+// WithZeroCreditWarning starts a background watcher that logs a warning
+// if the outgoing credit balance stays at creditFloor for longer than
+// window. It is only meaningful paired with WithCreditFloor(0), since
+// otherwise the balance never stays at the floor once the client is idle.
+// A non-positive window disables the watcher (the default).
+func WithZeroCreditWarning(window time.Duration) Option {
+	return func(bw *Breakwater) {
+		bw.zeroCreditWarnAfter = window
+		if window > 0 {
+			go bw.watchZeroCredit(window)
+		}
+	}
+}
+
+// WithCreditDecay starts a background watcher that gives back an idle
+// client's share of the server's overcommitment: once the instance-wide
+// demand (see Demand) has sat at zero for longer than idleWindow, it
+// shrinks outgoingCredits toward creditFloor by rate -- a fraction of
+// the balance above the floor -- on every idleWindow tick past that
+// point, rather than holding a balance the server granted for load
+// that's no longer there. rate is clamped to (0, 1]; 1 decays straight
+// to the floor on the first idle tick. A non-positive idleWindow
+// disables decay (the default).
+func WithCreditDecay(idleWindow time.Duration, rate float64) Option {
+	return func(bw *Breakwater) {
+		if idleWindow <= 0 {
+			return
+		}
+		if rate <= 0 {
+			rate = 0.01
+		} else if rate > 1 {
+			rate = 1
+		}
+		go bw.watchCreditDecay(idleWindow, rate)
+	}
+}
+
+// WithOverloadWindow overrides how far back OverloadLevel looks when
+// computing the recent drop rate. A non-positive window disables the
+// override and keeps defaultOverloadWindow.
+func WithOverloadWindow(window time.Duration) Option {
+	return func(bw *Breakwater) {
+		if window > 0 {
+			bw.overloadWindow = window
+		}
+	}
+}
+
+// WithOverloadSheddingThreshold overrides the drop rate, in [0, 1], at
+// or beyond which OverloadLevel reports OverloadShedding instead of
+// OverloadDegraded. A threshold outside (0, 1] disables the override
+// and keeps defaultOverloadSheddingThreshold.
+func WithOverloadSheddingThreshold(threshold float64) Option {
+	return func(bw *Breakwater) {
+		if threshold > 0 && threshold <= 1 {
+			bw.overloadSheddingThreshold = threshold
+		}
+	}
+}
+
+// WithDropCodes overrides the gRPC code DropError.GRPCStatus() reports
+// for specific drop reasons, in place of whatever code that reason's
+// drop site uses by default (ResourceExhausted for most; Unavailable
+// for ShuttingDown, DeadlineExceeded for an Expired drop triggered by
+// ctx.Err() rather than the client-side budget). Reasons not present in
+// codes keep their default. Calling WithDropCodes more than once merges
+// into the existing map rather than replacing it.
+func WithDropCodes(overrides map[DropReason]codes.Code) Option {
+	return func(bw *Breakwater) {
+		if bw.dropCodes == nil {
+			bw.dropCodes = make(map[DropReason]codes.Code, len(overrides))
+		}
+		for reason, code := range overrides {
+			bw.dropCodes[reason] = code
+		}
+	}
+}
+
+/*
+WithResetOnErrorCodes marks codes as unrecoverable enough that, when the
+invoker fails with one of them, holding onto the credit balance is
+pointless -- the backend itself is in trouble (codes.Unavailable being
+the typical case), not just momentarily over capacity the way a
+ResourceExhausted from the server's own AQM would be. For those codes,
+the credit balance is reset to its starting value (see WithInitialCredits)
+instead of running returnCreditOnFail's add-one logic, so once the
+backend does come back this client starts conservatively rather than
+resuming at whatever balance it happened to be holding when things broke.
+A server-attached "credits" trailer on the failed response, if present,
+still takes priority over this reset, the same as it already does over
+creditsOnFail -- an explicit instruction from the server about the exact
+balance to use beats resetting to a fixed default.
+
+This composes with WithInitialCredits' slow-start ramp the same way
+Reset does: slowStartDone is left untouched. If the ramp had already
+finished before the failure, recovery relies on the server's own
+explicit "credits" values once it starts responding again, not a fresh
+ramp. If the ramp was still in progress, the next response with no
+"credits" attached resumes doubling from the freshly reset (lower)
+balance, exactly as it would from a new connection.
+
+Calling WithResetOnErrorCodes more than once merges into the existing
+set rather than replacing it.
+*/
+func WithResetOnErrorCodes(resetCodes []codes.Code) Option {
+	return func(bw *Breakwater) {
+		if bw.resetOnErrorCodes == nil {
+			bw.resetOnErrorCodes = make(map[codes.Code]bool, len(resetCodes))
+		}
+		for _, code := range resetCodes {
+			bw.resetOnErrorCodes[code] = true
+		}
+	}
+}
+
+// WithAcquireNPolicy overrides AcquireN's default AllOrNothing behavior
+// when fewer than the requested n credits are available. See
+// AcquireNPolicy's doc comment for what each value does.
+func WithAcquireNPolicy(policy AcquireNPolicy) Option {
+	return func(bw *Breakwater) {
+		bw.acquireNPolicy = policy
+	}
+}
+
+// WithInitialCredits overrides the client's starting outgoing credit
+// balance (1 by default) and turns on a TCP-slow-start-style ramp: with
+// a deliberately low n, every response that carries no real "credits"
+// value doubles the balance instead of leaving it unchanged, climbing
+// 1 -> 2 -> 4 -> ... until the first response that does carry one. From
+// that point on, slow-start growth never runs again for this instance;
+// mergeCredit's creditFloor floor and the server's reported value
+// apply exactly as they would without this option. n <= 0 is ignored,
+// leaving both the default starting balance and the old no-ramp
+// behavior in place.
+func WithInitialCredits(n int64) Option {
+	return func(bw *Breakwater) {
+		if n <= 0 {
+			return
+		}
+		bw.outgoingCredits.Store(n)
+		bw.slowStartEnabled = true
+	}
+}
+
+// WithShadowMode puts this instance into dry-run mode: acquireCreditLoop
+// still runs every queue-full, expiration, and cancellation check it
+// normally would, still counts what it decided (via Stats()'
+// ShadowDropped, broken out by reason in ShadowDroppedByReason) and
+// still fires OnDrop if set, but always admits the request regardless
+// of what it decided. This lets an operator size queue capacity and
+// expiration budgets against real traffic before flipping enforcement
+// on for real. WithMaxInFlight's cap is unaffected: it protects local
+// memory rather than encoding a policy being evaluated, so it keeps
+// dropping for real even in shadow mode.
+func WithShadowMode(enabled bool) Option {
+	return func(bw *Breakwater) {
+		bw.shadowMode = enabled
+	}
+}
+
+/*
+WithCreditUpdateMode picks how the "credits" header/trailer is
+interpreted: Replace (the default) carries the receiver's new balance
+outright; Additive carries a signed delta that the receiver adds to its
+existing balance instead. Additive is more robust to a dropped
+response, since a missed delta is simply caught up by the next one
+rather than leaving the receiver stuck on a stale total.
+
+Both ends of a connection must agree: the client and the server it
+talks to each configure their own instance with this option, and each
+side also announces its mode via "credit-mode" metadata so a mismatch
+is logged by checkCreditModeMismatch instead of silently misinterpreting
+every update as the wrong one (a client expecting Additive would add a
+peer's absolute Replace values onto its balance forever, and vice
+versa).
+*/
+func WithCreditUpdateMode(mode CreditUpdateMode) Option {
+	return func(bw *Breakwater) {
+		bw.creditUpdateMode = mode
+	}
+}
+
+// WithBroker registers this instance with broker, letting it lend its
+// own idle credits to starved peers and borrow from theirs in turn; see
+// CreditBroker. Instances that never set this option are unaffected by
+// any broker, exactly as before CreditBroker existed.
+func WithBroker(broker *CreditBroker) Option {
+	return func(bw *Breakwater) {
+		bw.broker = broker
+		broker.register(bw)
+	}
+}
+
+// WithAdmissionPolicy replaces the default CreditPolicy with policy,
+// letting an advanced caller veto a request even after it has acquired a
+// credit -- eg a CoDel-style policy reacting to queuing delay, or a PID
+// controller tuning against an external signal -- while still reusing
+// all of acquireCreditLoop's queueing, expiration, and credit plumbing.
+// See AdmissionPolicy.
+func WithAdmissionPolicy(policy AdmissionPolicy) Option {
+	return func(bw *Breakwater) {
+		bw.admissionPolicy = policy
+	}
+}
+
+// WithFIFO gates an optional strict-ordering mode for queued requests.
+// When enabled, a ticket/sequence number lets the earliest-arriving
+// waiter attempt credit acquisition before any later-arriving one, even
+// if a later waiter would otherwise have raced ahead on noCreditBlocker.
+// This adds real coordination overhead: every request serializes behind
+// the one ahead of it, even when credits are plentiful. Off by default.
+func WithFIFO(enabled bool) Option {
+	return func(bw *Breakwater) {
+		bw.fifo = enabled
+	}
+}
+
+// WithMethodConfig gives method its own independent credit pool --
+// balance, queue, and parking channel -- instead of sharing Breakwater's
+// instance-wide one. Use this when two RPCs on the same connection have
+// very different costs, so shedding the expensive one doesn't also
+// starve the cheap one. The pool is created lazily the first time method
+// is seen on the hot path. Calling WithMethodConfig more than once for
+// the same method keeps the last value.
+func WithMethodConfig(method string, cfg MethodConfig) Option {
+	return func(bw *Breakwater) {
+		if bw.methodConfigs == nil {
+			bw.methodConfigs = make(map[string]MethodConfig)
+		}
+		bw.methodConfigs[method] = cfg
+	}
+}
+
+/*
+WithCreditClasses splits the instance-wide starting credit balance into
+independent per-class pools -- balance, queue, and parking channel each
+-- so workload classes tagged via WithClass can't starve each other the
+way they would sharing one pool, without registering a MethodConfig for
+every method in the class. shares maps a class name to its fraction of
+the starting balance (eg 0.7 for "interactive", 0.3 for "batch"); shares
+that don't sum to 1 just scale the total up or down. A request tagged
+with a name absent from shares, or not tagged via WithClass at all,
+draws from the reserved "default" class, which gets whatever fraction of
+the balance shares doesn't otherwise account for (1 - sum(shares), floored
+at one credit) unless the caller supplies its own "default" entry.
+
+Once set, this takes priority over WithMethodConfig/WithCreditPartition
+for every call: classes are a coarser, connection-wide grouping, not an
+additional layer on top of per-method pools.
+*/
+func WithCreditClasses(shares map[string]float64) Option {
+	return func(bw *Breakwater) {
+		if bw.methodConfigs == nil {
+			bw.methodConfigs = make(map[string]MethodConfig)
+		}
+		bw.creditClassesEnabled = true
+
+		total := float64(bw.outgoingCredits.Load())
+		var allocated float64
+		for name, share := range shares {
+			if share <= 0 {
+				continue
+			}
+			allocated += share
+			bw.methodConfigs[classPoolKey(name)] = bw.classConfig(total * share)
+		}
+		if _, ok := shares[defaultClassName]; !ok {
+			bw.methodConfigs[classPoolKey(defaultClassName)] = bw.classConfig(total * (1 - allocated))
+		}
+	}
+}
+
+// classConfig builds the MethodConfig backing one class's pool: its
+// share of the starting balance (floored at 1, so a very small or
+// negative share still gets a pool rather than starting permanently
+// empty), and the instance-wide floor/queue capacity, unaffected by
+// WithCreditClasses.
+func (b *Breakwater) classConfig(initialCredits float64) MethodConfig {
+	n := int64(initialCredits)
+	if n < 1 {
+		n = 1
+	}
+	return MethodConfig{InitialCredits: n, CreditFloor: b.creditFloor.Load(), QueueCapacity: b.queueCapacity.Load()}
+}
+
+// WithAdaptiveExpiration makes acquireCredit's client-side expiration
+// check scale with observed latency instead of using the static
+// clientExpiration: the effective budget becomes multiplier * ewmaRTT,
+// clamped to clientExpiration as an upper bound. This keeps brief backend
+// latency blips from tripping the fixed budget prematurely, while still
+// shedding load once queueing delay genuinely exceeds what clientExpiration
+// would have allowed. Has no effect until at least one unary call has
+// completed and seeded ewmaRTTMicros; before that, clientExpiration applies
+// unchanged. Only WithClientTimeExpiration(true) (the default) actually
+// enforces either budget.
+func WithAdaptiveExpiration(multiplier float64) Option {
+	return func(bw *Breakwater) {
+		bw.adaptiveExpiration = true
+		bw.adaptiveMultiplier = multiplier
+	}
+}
+
+// WithTracerProvider sets the trace.TracerProvider used to open the
+// "breakwater.queue_wait" span around credit acquisition in
+// UnaryInterceptorClient. Defaults to the global provider
+// (otel.GetTracerProvider) when never called.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(bw *Breakwater) {
+		bw.tracerProvider = tp
+	}
+}
+
+// WithOnDrop registers fn to be called at every point the client
+// interceptor sheds a request: queue-full, client-side expiration, and
+// cancellation. fn receives the DropReason, this instance's id, and how
+// long (in microseconds) the request had been waiting before it was
+// dropped.
+//
+// fn is called synchronously from the hot path, while no internal lock
+// is held, so it cannot deadlock against breakwater's own state -- but
+// it also runs in-line with every dropped request, so a slow fn directly
+// adds to that latency. If fn does anything beyond a quick metric
+// increment or sampled log, dispatch the real work in a goroutine (or a
+// bounded worker pool) rather than doing it inline.
+func WithOnDrop(fn func(reason DropReason, id string, waitedUs int64)) Option {
+	return func(bw *Breakwater) {
+		bw.onDrop = fn
+	}
+}
+
+/*
+WithStallTimeout arms a watchdog for waiters parked waiting for a
+credit: if one has been parked on noCreditBlocker longer than timeout,
+onStall (if non-nil) is called once with this instance's id, the method,
+and how long it had waited, and StallCount is incremented, turning what
+would otherwise be a silent hang (eg the server restarted mid-flight
+and never sends more credits) into an observable signal. failRequest
+additionally gives up on the request at that point, dropping it with a
+Stalled DropError (codes.Unavailable unless overridden by
+WithDropCodes) instead of continuing to wait indefinitely.
+
+timeout<=0 disables the watchdog entirely -- the default, so existing
+callers see no behavior change. onStall runs synchronously on the hot
+path, under the same constraints as WithOnDrop's fn.
+*/
+func WithStallTimeout(timeout time.Duration, failRequest bool, onStall func(id string, method string, waitedUs int64)) Option {
+	return func(bw *Breakwater) {
+		bw.stallTimeout = timeout
+		bw.stallFailsRequest = failRequest
+		bw.onStall = onStall
+	}
+}
+
+/*
+WithCreditWaitTimeout arms a second, normally shorter ceiling than
+clientExpiration/WithClientTimeExpiration on how long a request may sit
+parked on noCreditBlocker specifically, as opposed to total time in
+queue. Once exceeded, the request is dropped outright with a
+CreditTimeout DropError (codes.Unavailable unless overridden by
+WithDropCodes) -- there is no report-only mode the way WithStallTimeout
+has, since the point of this option is to fail fast rather than just
+observe.
+
+This is useful for distinguishing "the server has gone completely
+silent" from "the server is just slow but still issuing credits
+eventually": a short WithCreditWaitTimeout fires well before the overall
+expiration budget would. timeout<=0 disables it entirely -- the default,
+so existing callers see no behavior change.
+*/
+func WithCreditWaitTimeout(timeout time.Duration) Option {
+	return func(bw *Breakwater) {
+		bw.creditWaitTimeout = timeout
+	}
+}
+
+/*
+WithDemandReportInterval batches how often UnaryInterceptorClient and
+StreamInterceptorClient attach the "demand" header: once interval has
+elapsed since the last report, the next call reports and resets the
+clock; calls in between omit "demand" entirely (still sending "id" and
+"credit-mode"), and the server falls back to the last demand it did
+hear about (see lastKnownDemand) rather than treating the client as
+unregistered.
+
+This trades demand-on-the-server staleness of up to interval for fewer
+metadata bytes per call, worthwhile for callers issuing many small
+requests in quick succession whose demand doesn't change request to
+request. interval<=0 disables batching entirely -- the default, so
+every call reports its demand as before.
+*/
+func WithDemandReportInterval(interval time.Duration) Option {
+	return func(bw *Breakwater) {
+		bw.demandReportInterval = interval
+	}
+}
+
+// WithAdmitJitter makes UnaryInterceptorClient and StreamInterceptorClient
+// sleep for a random duration in [0, maxDelay) after acquiring a credit
+// but before calling invoker/streamer. When a batch of credits arrives
+// and wakes many waiters parked on the same noCreditBlocker at once, they
+// would otherwise all hit the server in lockstep, producing a latency
+// sawtooth; spreading their admission over maxDelay smooths that out.
+// Keep maxDelay small relative to typical request latency so it doesn't
+// meaningfully affect median latency. A non-positive maxDelay disables
+// jitter, the default.
+func WithAdmitJitter(maxDelay time.Duration) Option {
+	return func(bw *Breakwater) {
+		bw.admitJitter = maxDelay
+	}
+}
+
+// WithDisabled starts a Breakwater instance in passthrough mode: every
+// call skips queueing and credit logic until Enable is called. Useful
+// for wiring breakwater into an interceptor chain ahead of a canary
+// rollout, so it can be flipped on at runtime without a redeploy once
+// its behavior has been validated. Equivalent to calling Disable()
+// immediately after New.
+func WithDisabled() Option {
+	return func(bw *Breakwater) {
+		bw.enabled.Store(false)
+	}
+}
+
+// WithCreditPartition makes UnaryInterceptorClient and
+// StreamInterceptorClient fold keyFunc(ctx) into the method string used
+// to look up a credit pool, so calls that would otherwise share one
+// instance-wide (or one per-method, with WithMethodConfig) balance can
+// instead get a separate one per partition -- typically the backend
+// address a grpc.Picker routed the call to, read back off ctx via
+// peer.FromContext or a custom balancer that stashes it there. Every
+// partition is discovered lazily the first time it's seen and starts
+// with the same defaults as the instance-wide pool (1 starting credit,
+// this instance's creditFloor and queueCapacity); use WithMethodConfig
+// together with this option if a specific method needs different
+// per-partition defaults. keyFunc must be safe to call concurrently, and
+// should be cheap: it runs on every admitted call, not just once.
+func WithCreditPartition(keyFunc func(ctx context.Context) string) Option {
+	return func(bw *Breakwater) {
+		bw.partitionKeyFunc = keyFunc
+	}
+}
+
+// WithPoolScope sets how coarse or fine poolKey's pooling is: the
+// default PoolScopeMethod keys pools by method (isolating each method
+// that has a WithMethodConfig), PoolScopeService
+// isolates only services (every method of one service shares a pool),
+// and PoolScopeConnection collapses everything back onto the single
+// instance-wide pool. Takes effect immediately for calls made after this
+// option is applied; WithCreditClasses, if also configured, overrides
+// this entirely.
+func WithPoolScope(scope PoolScope) Option {
+	return func(bw *Breakwater) {
+		bw.poolScope = scope
+	}
+}
+
+// WithID overrides the value this instance sends on the wire as the "id"
+// metadata key, in place of the auto-generated UUID. Use a stable,
+// human-meaningful value (a logical service name, a pod name) so the
+// server's per-client bookkeeping -- RegisterClient, the overcommitment
+// map, log lines keyed by clientID -- reads the same way your service
+// topology does. Uniqueness across instances is the caller's
+// responsibility; nothing here validates it. idStr keeps its
+// auto-generated UUID string when WithID is never called.
+func WithID(id string) Option {
+	return func(bw *Breakwater) {
+		bw.idStr = id
+	}
+}
+
+// WithMaxInFlight bounds how many invoker/streamer calls this instance
+// lets run at once to n, independent of and in addition to the credit
+// system: credits throttle based on server feedback, while this is a
+// hard local ceiling that protects client memory regardless of how
+// generous the server is being. The check runs just before invoker (or
+// streamer) is called and releases its slot right after it returns (or,
+// for streams, once the stream closes); a request that already holds a
+// credit but arrives when the cap is full gets that credit back and is
+// dropped with a DropError{Reason: InFlightLimit}. n <= 0 disables the
+// cap, the default.
+func WithMaxInFlight(n int) Option {
+	return func(bw *Breakwater) {
+		if n <= 0 {
+			bw.maxInFlightSem = nil
+			return
+		}
+		bw.maxInFlightSem = make(chan struct{}, n)
+	}
+}
+
+// WithMaxCreditsPerClient caps how many credits updateCreditsToIssue will
+// ever issue to a single client id, regardless of how much demand it
+// reports. This is a server-side fairness knob: without it, one client
+// reporting inflated demand can be issued most of the overcommitted pool
+// (cTotal), starving every other registered client. Capped clients
+// simply never consume their share of the pool, so it naturally
+// redistributes to everyone else via the existing cIssued/cTotal
+// accounting -- no separate redistribution logic is needed. n <= 0
+// disables the cap, the pre-existing behavior.
+func WithMaxCreditsPerClient(n int64) Option {
+	return func(bw *Breakwater) {
+		bw.maxCreditsPerClient = n
+	}
+}
+
+// WithDemandEWMAAlpha overrides the smoothing factor applied to the
+// "demand" value reported on the wire: each raw Demand() sample is
+// folded into a running average as alpha*sample + (1-alpha)*average,
+// rather than sending the noisy instantaneous queue depth directly.
+// This only affects what's sent to the server -- Demand() and Stats()
+// still report the exact raw count. Higher alpha tracks recent demand
+// more closely at the cost of more noise; defaults to 0.2.
+func WithDemandEWMAAlpha(alpha float64) Option {
+	return func(bw *Breakwater) {
+		bw.demandEWMAAlpha = alpha
+	}
+}
+
+// WithQueueDelayEWMAAlpha overrides the smoothing factor applied to the
+// server-side delay signal the AQM controller (getUpdatedTotalCredits,
+// the load-shedding check in UnaryInterceptor) reacts to: each request's
+// handler latency is folded into a running average as
+// alpha*sample + (1-alpha)*average, rather than reacting to one noisy
+// request at a time. UnaryInterceptor never parks a request before
+// calling its handler, so true queueing time isn't directly observable
+// here; the sample is handler latency itself (see recordMeasuredDelay). Higher alpha tracks recent latency more closely at
+// the cost of more noise; defaults to 0.2. Only meaningful server-side.
+func WithQueueDelayEWMAAlpha(alpha float64) Option {
+	return func(bw *Breakwater) {
+		bw.queueDelayEWMAAlpha = alpha
+	}
+}
+
+// WithPriorityAging overrides how many Priority levels a waiter parked
+// in acquireCreditLoop gains per second waited (see priority_queue.go),
+// bounding how long a Low waiter can be starved by a steady stream of
+// higher-priority traffic during partial overload. levelsPerSecond <= 0
+// disables aging entirely: priority order becomes fixed, and a
+// sustained stream of higher-priority requests can starve a Low one
+// indefinitely. Defaults to defaultPriorityAgingPerSecond. Only
+// meaningful client-side; only applies to requests that actually queue
+// (WithPriority(ctx, High) still bypasses queueing altogether).
+func WithPriorityAging(levelsPerSecond float64) Option {
+	return func(bw *Breakwater) {
+		bw.priorityAgingPerSecond = levelsPerSecond
+		bw.priorityQueue.aging = levelsPerSecond
+	}
+}
+
+// WithMaxAdmitRate caps how many requests per second acquireCreditLoop
+// admits, via a token bucket applied after credit acquisition and
+// independent of it: a request that already holds a credit still waits
+// at awaitAdmitRate if the bucket is dry, instead of being admitted
+// immediately. Meant for chaos testing -- reproducing a slow downstream
+// server deterministically -- not as a substitute for the credit
+// protocol itself; see tokenBucket. The wait still obeys the usual
+// expiration/ctx-deadline budget, so a request that runs out of that
+// budget while waiting on the rate cap is dropped with Reason Expired,
+// same as any other expiration, rather than held indefinitely.
+// perSecond <= 0 disables it, the default.
+func WithMaxAdmitRate(perSecond float64) Option {
+	return func(bw *Breakwater) {
+		bw.admitRateLimiter = newTokenBucket(perSecond)
+	}
+}
+
+// WithClock overrides the Clock used for every time.Now()/time.Since()
+// read on the client-side hot path (queue-wait timing, expiration, RTT),
+// in place of the default realClock. Tests can use this to drive
+// clientExpiration and the adaptive-RTT logic deterministically, without
+// sleeping in real time.
+func WithClock(c Clock) Option {
+	return func(bw *Breakwater) {
+		bw.clock = c
+	}
+}
+
+// WithClientKeyFunc overrides how the server-side UnaryInterceptor
+// derives the per-client key it registers/looks up a Connection under,
+// in place of defaultClientKey. Operators reach for this to pick their
+// own trust model outright -- eg always deriving the key from
+// peer.FromContext and ignoring the "id" metadata entirely, or combining
+// it with mTLS client certificate identity -- rather than the built-in
+// fallback/validation behavior covered by WithPeerValidatedID. f
+// returning "" bypasses overload control for that request, as
+// defaultClientKey's "" does for a request with neither an id nor a peer.
+func WithClientKeyFunc(f func(ctx context.Context) string) Option {
+	return func(bw *Breakwater) {
+		bw.clientKeyFunc = f
+	}
+}
+
+// WithPeerValidatedID makes the server's default client-key resolution
+// (see defaultClientKey) fold the transport peer's address into the key
+// even when the client supplied an "id", so a claimed id replayed from a
+// different connection lands its own Connection rather than sharing or
+// stealing another one's credit balance. Off by default: plenty of
+// deployments treat the client-supplied id as trustworthy on its own,
+// and folding in the peer address means a client's accounting no longer
+// survives a reconnect. Has no effect once WithClientKeyFunc is set.
+func WithPeerValidatedID(enabled bool) Option {
+	return func(bw *Breakwater) {
+		bw.peerValidatedID = enabled
+	}
+}
+
+// WithCheapHintThreshold overrides how fast a WithCheapHint request's
+// response has to come back for UnaryInterceptorClient to proactively
+// release its credit, in place of defaultCheapHintThreshold.
+func WithCheapHintThreshold(d time.Duration) Option {
+	return func(bw *Breakwater) {
+		bw.cheapHintThreshold = d
+	}
+}
+
+// WithQueueFullPolicy picks what acquireCreditLoop does when a new
+// request finds method's queue already at capacity, in place of the
+// default Reject; see QueueFullPolicy for the latency tradeoffs of each.
+// blockTimeout is only consulted for BlockWithTimeout (ignored, and safe
+// to leave at 0, for Reject and DropOldest).
+func WithQueueFullPolicy(policy QueueFullPolicy, blockTimeout time.Duration) Option {
+	return func(bw *Breakwater) {
+		bw.queueFullPolicy = policy
+		bw.queueFullBlockTimeout = blockTimeout
+	}
+}
+
+// WithCreditStore installs store as this instance's credit-balance
+// persistence hook, for clients that restart often enough (eg
+// serverless-style workers) that cold-starting from scratch each time
+// causes a burst of overshoot against a shared server. If store.Load
+// reports a previously saved balance, the instance starts from it
+// instead of its default outgoing balance. From then on, store.Save is called every
+// saveInterval with the current balance, and once more from Close, so a
+// later restart resumes near where this run left off. A non-positive
+// saveInterval disables the periodic save; Close still saves once.
+func WithCreditStore(store CreditStore, saveInterval time.Duration) Option {
+	return func(bw *Breakwater) {
+		if store == nil {
+			return
+		}
+		bw.creditStore = store
+		if balance, ok := store.Load(); ok {
+			bw.outgoingCredits.Store(balance)
+		}
+		if saveInterval > 0 {
+			go bw.watchCreditStore(store, saveInterval)
+		}
+	}
+}
+
+// WithCircuitBreaker installs a breaker that fails requests fast with
+// CircuitOpen, before they ever reach the queue, once the drop rate over
+// the trailing window reaches threshold. Once open, it fails everything
+// for cooldown, then lets exactly one probe request through; a
+// successful probe closes the breaker and resets its window, a dropped
+// one reopens it for another cooldown. threshold must be in (0, 1] and
+// window and cooldown must both be positive, or the option is a no-op
+// and the instance behaves as if it were never called.
+func WithCircuitBreaker(threshold float64, window, cooldown time.Duration) Option {
+	return func(bw *Breakwater) {
+		if threshold <= 0 || threshold > 1 || window <= 0 || cooldown <= 0 {
+			return
+		}
+		bw.circuitBreaker = newCircuitBreaker(threshold, window, cooldown)
+	}
+}
+
+// New builds a Breakwater the same way InitBreakwater does, then applies
+// opts on top of it. Prefer New over InitBreakwater when running more than
+// one Breakwater instance in the same process with different policies,
+// since opts are stored on the instance rather than on package globals.
+func New(param BWParameters, opts ...Option) *Breakwater {
+	bw := InitBreakwater(param)
+	for _, opt := range opts {
+		opt(bw)
+	}
+	// Capture the post-options starting balance so Reset has the right
+	// baseline even when WithInitialCredits overrode InitBreakwater's
+	// default of 1.
+	bw.initialCredits = bw.outgoingCredits.Load()
+	return bw
+}
@@ -0,0 +1,59 @@
+package breakwater
+
+import "strings"
+
+/*
+PoolScope controls how poolKey derives the pool a request is admitted
+against, for callers whose method-level pooling is finer than how their
+backends are actually deployed and scaled. Set via WithPoolScope.
+
+  - PoolScopeMethod is the default: the pool key is the full method,
+    exactly as if WithPoolScope were never called, so a method with its
+    own WithMethodConfig gets its own pool and every other method shares
+    the instance-wide one.
+  - PoolScopeService pools every method of a gRPC service together,
+    keyed on the service portion of the method string (eg "/pkg.Svc/"
+    out of "/pkg.Svc/Method"), isolating services from each other while
+    no longer isolating methods within the same service. Each service's
+    pool is created on first use with the instance-wide defaults unless
+    WithMethodConfig registered one under the service key.
+  - PoolScopeConnection pools every method across every service
+    together, falling back to the single instance-wide pool exactly as
+    if method were always "".
+
+WithCreditClasses, when configured, takes priority over PoolScope
+entirely, the same way it already takes priority over WithMethodConfig
+and WithCreditPartition. WithCreditPartition's partition suffix still
+applies on top of whichever scope this resolves to.
+*/
+type PoolScope int
+
+const (
+	PoolScopeMethod PoolScope = iota
+	PoolScopeService
+	PoolScopeConnection
+)
+
+func (s PoolScope) String() string {
+	switch s {
+	case PoolScopeService:
+		return "service"
+	case PoolScopeConnection:
+		return "connection"
+	default:
+		return "method"
+	}
+}
+
+// serviceFromMethod returns the service portion of a gRPC method string,
+// ie everything up to and including the second "/" (the package and
+// service name, not the RPC name) -- "/pkg.Svc/Method" becomes
+// "/pkg.Svc/". method is returned unchanged if it doesn't look like a
+// gRPC method path, rather than guessing.
+func serviceFromMethod(method string) string {
+	idx := strings.LastIndex(method, "/")
+	if idx <= 0 {
+		return method
+	}
+	return method[:idx+1]
+}
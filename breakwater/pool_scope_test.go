@@ -0,0 +1,85 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+func TestServiceFromMethodStripsTheRPCName(t *testing.T) {
+	cases := map[string]string{
+		"/pkg.Svc/Method":      "/pkg.Svc/",
+		"/pkg.Svc/OtherMethod": "/pkg.Svc/",
+		"no-slashes":           "no-slashes",
+		"/onlyone":             "/onlyone",
+	}
+	for method, want := range cases {
+		if got := serviceFromMethod(method); got != want {
+			t.Fatalf("serviceFromMethod(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+func TestWithoutPoolScopeDefaultsToPerMethodPools(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	keyA := bw.poolKey(context.Background(), "/pkg.Svc/MethodA")
+	keyB := bw.poolKey(context.Background(), "/pkg.Svc/MethodB")
+	if keyA == keyB {
+		t.Fatalf("expected distinct per-method keys by default, got %q for both", keyA)
+	}
+}
+
+func TestPoolScopeServiceSharesOneKeyPerService(t *testing.T) {
+	bw := New(BWParametersDefault, WithPoolScope(PoolScopeService))
+
+	keyA := bw.poolKey(context.Background(), "/pkg.Svc/MethodA")
+	keyB := bw.poolKey(context.Background(), "/pkg.Svc/MethodB")
+	keyOther := bw.poolKey(context.Background(), "/pkg.OtherSvc/Method")
+
+	if keyA != keyB {
+		t.Fatalf("expected both methods of the same service to share a key, got %q and %q", keyA, keyB)
+	}
+	if keyA == keyOther {
+		t.Fatal("expected a different service to get a different key")
+	}
+}
+
+func TestPoolScopeConnectionCollapsesToTheInstanceWidePool(t *testing.T) {
+	bw := New(BWParametersDefault, WithPoolScope(PoolScopeConnection))
+
+	key := bw.poolKey(context.Background(), "/pkg.Svc/MethodA")
+	if key != "" {
+		t.Fatalf("expected PoolScopeConnection to resolve to the instance-wide pool (key \"\"), got %q", key)
+	}
+	if pool := bw.poolFor(key); pool != nil {
+		t.Fatal("expected poolFor(\"\") to fall back to the instance-wide balance rather than a per-key pool")
+	}
+}
+
+func TestPoolScopeServiceComposesWithCreditPartition(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithPoolScope(PoolScopeService),
+		WithCreditPartition(backendFromContext),
+	)
+
+	ctxA := withBackend(context.Background(), "backend-a")
+	ctxB := withBackend(context.Background(), "backend-b")
+
+	keyA := bw.poolKey(ctxA, "/pkg.Svc/MethodA")
+	keyB := bw.poolKey(ctxB, "/pkg.Svc/MethodB")
+	if keyA == keyB {
+		t.Fatalf("expected the partition suffix to still distinguish pools under PoolScopeService, got %q for both", keyA)
+	}
+}
+
+func TestPoolScopeTakesSecondPlaceToCreditClasses(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithPoolScope(PoolScopeConnection),
+		WithCreditClasses(map[string]float64{"high": 1}),
+	)
+
+	key := bw.poolKey(context.Background(), "/pkg.Svc/MethodA")
+	if key == "" {
+		t.Fatal("expected WithCreditClasses to take priority over PoolScopeConnection")
+	}
+}
@@ -0,0 +1,54 @@
+package breakwater
+
+import "context"
+
+/*
+Priority marks a request's importance, for two separate interceptor
+behaviors: High takes the WithPriority bypass (skips queueing and credit
+acquisition entirely), while every level -- including Low -- influences
+admission order among requests that do queue, via the weighted wait
+queue acquireCreditLoop parks on; see priority_queue.go and
+WithPriorityAging. Higher values win ties for an available credit;
+Low exists for background work that should yield to everything else
+under partial overload, bounded by aging so it is never starved outright.
+*/
+type Priority int
+
+const (
+	Normal Priority = iota
+	High
+)
+
+// Low marks background work that should step aside for Normal or High
+// traffic while credits are scarce. Declared outside the iota block
+// above (and with no change to it) so Normal's zero value -- the
+// default for any context WithPriority never touched -- stays stable.
+// Low still ages like any other level (see WithPriorityAging), so a Low
+// waiter eventually outranks a steady stream of fresher Normal ones
+// rather than waiting forever.
+const Low Priority = -1
+
+type priorityKey struct{}
+
+/*
+WithPriority marks ctx so the client interceptors skip queueing, credit
+acquisition, and client-side expiration for requests made with it,
+passing straight through to the invoker/streamer. Use it for
+control-plane RPCs (health checks, leader election) that must never be
+dropped or queued by admission control.
+
+Bypassed requests still propagate the "id" metadata, so the server can
+attribute them to a client, but they neither consume nor update that
+client's credit balance, and are counted separately in Stats().Bypassed
+so operators can see how much traffic is escaping admission control.
+*/
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return Normal
+}
@@ -0,0 +1,94 @@
+package breakwater
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+priorityWaiter is one request parked in acquireCreditLoop's wait loop,
+tracked here purely so other waiters on the same pool can compare
+themselves against it; the actual blocking/waking still happens on
+noCreditBlocker, see priorityWaitQueue.
+*/
+type priorityWaiter struct {
+	level      Priority
+	enqueuedAt time.Time
+}
+
+// effectivePriority is level boosted by how long this waiter has been
+// parked: agingPerSecond levels per second waited, so a Low waiter
+// parked long enough eventually outranks a freshly-arrived Normal one
+// instead of starving behind a steady stream of higher-priority traffic.
+func (w *priorityWaiter) effectivePriority(now time.Time, agingPerSecond float64) float64 {
+	return float64(w.level) + agingPerSecond*now.Sub(w.enqueuedAt).Seconds()
+}
+
+/*
+priorityWaitQueue replaces the single FIFO-ish noCreditBlocker wait with
+soft priority ordering, without replacing noCreditBlocker's actual
+parking/waking mechanism: every waiter still blocks on the same channel
+it always did, but before spending a token it just received, it checks
+whether a still-parked peer currently outranks it (see hasHigherPriority)
+and, if so, hands the token back for that peer instead of consuming it
+itself. One priorityWaitQueue is shared by every waiter on the same
+credit pool -- the instance-wide one, or a MethodConfig override's, see
+methodPool.priorityQueue -- mirroring noCreditBlocker's own scoping.
+*/
+type priorityWaitQueue struct {
+	mu    sync.Mutex
+	set   map[*priorityWaiter]struct{}
+	aging float64 // priority levels gained per second waited; see WithPriorityAging
+}
+
+func newPriorityWaitQueue(agingPerSecond float64) *priorityWaitQueue {
+	return &priorityWaitQueue{set: make(map[*priorityWaiter]struct{}), aging: agingPerSecond}
+}
+
+// register parks a new waiter at level, returning a ticket the caller
+// must pass to unregister (typically via defer) once it leaves the wait
+// loop, win or lose.
+func (q *priorityWaitQueue) register(level Priority) *priorityWaiter {
+	w := &priorityWaiter{level: level, enqueuedAt: time.Now()}
+	q.mu.Lock()
+	q.set[w] = struct{}{}
+	q.mu.Unlock()
+	return w
+}
+
+func (q *priorityWaitQueue) unregister(w *priorityWaiter) {
+	q.mu.Lock()
+	delete(q.set, w)
+	q.mu.Unlock()
+}
+
+// hasHigherPriority reports whether some other currently-parked waiter
+// strictly outranks w right now. Ties go to w -- including the common
+// case of every waiter at the same level, where this always returns
+// false and acquireCreditLoop behaves exactly as it did before this type
+// existed.
+func (q *priorityWaitQueue) hasHigherPriority(w *priorityWaiter) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.set) <= 1 {
+		return false
+	}
+	now := time.Now()
+	mine := w.effectivePriority(now, q.aging)
+	for other := range q.set {
+		if other == w {
+			continue
+		}
+		if other.effectivePriority(now, q.aging) > mine {
+			return true
+		}
+	}
+	return false
+}
+
+// len reports how many waiters are currently parked, for tests.
+func (q *priorityWaitQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.set)
+}
@@ -0,0 +1,103 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPriorityWaitQueueHasHigherPriorityFalseWhenAlone(t *testing.T) {
+	q := newPriorityWaitQueue(0)
+	w := q.register(Normal)
+	if q.hasHigherPriority(w) {
+		t.Fatal("a lone waiter should never be told someone else outranks it")
+	}
+}
+
+func TestPriorityWaitQueueHasHigherPriorityComparesLevels(t *testing.T) {
+	q := newPriorityWaitQueue(0) // aging disabled for a deterministic comparison
+	low := q.register(Low)
+	q.register(Normal)
+
+	if !q.hasHigherPriority(low) {
+		t.Fatal("expected the Normal waiter to outrank the Low one")
+	}
+}
+
+func TestPriorityWaitQueueAgingEventuallyClosesTheGap(t *testing.T) {
+	q := newPriorityWaitQueue(10) // 10 levels/sec closes a 1-level gap in ~100ms
+	low := &priorityWaiter{level: Low, enqueuedAt: time.Now().Add(-200 * time.Millisecond)}
+	q.set[low] = struct{}{}
+	q.register(Normal)
+
+	if q.hasHigherPriority(low) {
+		t.Fatal("expected aging to have already closed the one-level gap")
+	}
+}
+
+func TestPriorityWaitQueueUnregisterRemovesTheWaiter(t *testing.T) {
+	q := newPriorityWaitQueue(0)
+	w := q.register(Normal)
+	if q.len() != 1 {
+		t.Fatalf("expected 1 waiter, got %d", q.len())
+	}
+	q.unregister(w)
+	if q.len() != 0 {
+		t.Fatalf("expected 0 waiters after unregister, got %d", q.len())
+	}
+}
+
+// waitForQueueLen polls until priorityQueueFor(method) holds exactly n
+// waiters, rather than sleeping a fixed guess, so the acquireCreditLoop
+// integration test below isn't racy against goroutine scheduling.
+func waitForQueueLen(t *testing.T, bw *Breakwater, method string, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if bw.priorityQueueFor(method).len() == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiter(s) on %q", n, method)
+}
+
+func TestAcquireCreditLoopPrefersHigherPriorityWaiter(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithPriorityAging(0))
+	bw.SetCredit("", 0)
+
+	lowDone := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(WithPriority(context.Background(), Low), bw.clock.Now(), "")
+		lowDone <- err
+	}()
+	waitForQueueLen(t, bw, "", 1)
+
+	normalDone := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		normalDone <- err
+	}()
+	waitForQueueLen(t, bw, "", 2)
+
+	bw.SetCredit("", 1)
+	select {
+	case err := <-normalDone:
+		if err != nil {
+			t.Fatalf("expected the normal-priority waiter to be admitted first, got err=%v", err)
+		}
+	case <-lowDone:
+		t.Fatal("expected the normal-priority waiter to be admitted before the low-priority one")
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the normal-priority waiter to be admitted")
+	}
+
+	bw.SetCredit("", 1)
+	select {
+	case err := <-lowDone:
+		if err != nil {
+			t.Fatalf("expected the low-priority waiter to eventually be admitted, got err=%v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the low-priority waiter to be admitted")
+	}
+}
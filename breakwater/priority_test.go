@@ -0,0 +1,40 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestUnaryInterceptorClientBypassesHighPriority(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	// Drain the one starting credit so a non-bypassed call would have to
+	// queue and wait.
+	if !bw.TryAcquireCredit("") {
+		t.Fatal("expected the starting credit to be available")
+	}
+
+	invoked := false
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		invoked = true
+		return nil
+	}
+
+	ctx := WithPriority(context.Background(), High)
+	err := bw.UnaryInterceptorClient(ctx, "/svc/Ctrl", nil, nil, nil, invoker)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !invoked {
+		t.Fatal("expected invoker to be called")
+	}
+	if got := bw.Stats().Bypassed; got != 1 {
+		t.Fatalf("expected Bypassed to be 1, got %d", got)
+	}
+	// Bypass must not have touched the (already-zero) credit balance.
+	if got := bw.PeekCredit(""); got != 0 {
+		t.Fatalf("expected credit balance to remain 0, got %d", got)
+	}
+}
@@ -0,0 +1,59 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSmoothedMeasuredDelaySeedsFromFirstSample(t *testing.T) {
+	bw := New(BWParametersDefault)
+	if got := bw.recordMeasuredDelay(1000); got != 1000 {
+		t.Fatalf("expected first sample to seed the average unchanged, got %f", got)
+	}
+}
+
+func TestSmoothedMeasuredDelayTracksTowardsSustainedLatency(t *testing.T) {
+	bw := New(BWParametersDefault, WithQueueDelayEWMAAlpha(0.5))
+
+	bw.recordMeasuredDelay(0)
+	first := bw.recordMeasuredDelay(1000)
+	second := bw.recordMeasuredDelay(1000)
+
+	if first >= 1000 {
+		t.Fatalf("expected a momentary spike to only partially move the average, got %f", first)
+	}
+	if second <= first || second > 1000 {
+		t.Fatalf("expected the average to keep climbing towards sustained latency, got first=%f second=%f", first, second)
+	}
+}
+
+func TestGetDelayPrefersTheMeasuredEWMAOnceSeeded(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.recordMeasuredDelay(1234)
+
+	if got := bw.getDelay(); got != 1234 {
+		t.Fatalf("expected getDelay to report the seeded EWMA, got %f", got)
+	}
+}
+
+func TestUnaryInterceptorFeedsHandlerLatencyIntoTheDelayEWMA(t *testing.T) {
+	params := BWParametersDefault
+	params.ServerSide = true
+	bw := New(params)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("id", "client-1", "demand", "1"))
+
+	slowHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "ok", nil
+	}
+
+	if _, err := bw.UnaryInterceptor(ctx, struct{}{}, nil, slowHandler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := bw.getDelay(); got < 4000 {
+		t.Fatalf("expected the measured delay to reflect the handler's ~5ms latency, got %f us", got)
+	}
+}
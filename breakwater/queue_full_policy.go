@@ -0,0 +1,105 @@
+package breakwater
+
+import "sync"
+
+/*
+QueueFullPolicy controls what acquireCreditLoop does when queueRequest
+finds method's queue already at capacity; see WithQueueFullPolicy. Each
+policy trades latency differently:
+
+  - Reject drops the new request immediately. Lowest latency for the
+    request that hits it (no extra wait), but it never runs at all if
+    the queue was only briefly full.
+  - BlockWithTimeout retries queueing for up to a configured timeout
+    before falling back to Reject. Adds up to that timeout of tail
+    latency to the new request, in exchange for a chance to ride out a
+    brief burst instead of dropping outright.
+  - DropOldest evicts the longest-queued still-waiting request instead
+    of rejecting the new one. The new request pays no extra latency at
+    all, but an older, already-queued request that believed it was
+    about to be served pays for it by being dropped in its place --
+    favoring the newest request's freshness over fairness to whatever
+    was already waiting.
+*/
+type QueueFullPolicy int
+
+const (
+	// Reject is the default: see the QueueFullPolicy doc comment.
+	Reject QueueFullPolicy = iota
+	// BlockWithTimeout: see the QueueFullPolicy doc comment.
+	BlockWithTimeout
+	// DropOldest: see the QueueFullPolicy doc comment.
+	DropOldest
+)
+
+func (p QueueFullPolicy) String() string {
+	switch p {
+	case BlockWithTimeout:
+		return "block_with_timeout"
+	case DropOldest:
+		return "drop_oldest"
+	default:
+		return "reject"
+	}
+}
+
+// queueTicket is one waiter's entry in a dropOldestQueue: dropped is
+// closed by evictOldest to signal that the waiter it belongs to should
+// give up its queue slot, a wakeup source acquireCreditLoop's wait loop
+// selects on alongside noCreditBlocker, ctx.Done(), and the rest.
+type queueTicket struct {
+	dropped chan struct{}
+}
+
+/*
+dropOldestQueue tracks the FIFO arrival order of everything currently
+parked in acquireCreditLoop for one pool, so DropOldest can evict
+whichever has been waiting longest when a new arrival finds the queue
+full. It is distinct from priorityWaitQueue, which orders who gets the
+*next available credit* among waiters that are staying; this instead
+decides who gets removed outright to free a slot for someone who hasn't
+queued yet.
+*/
+type dropOldestQueue struct {
+	mu    sync.Mutex
+	order []*queueTicket
+}
+
+func newDropOldestQueue() *dropOldestQueue {
+	return &dropOldestQueue{}
+}
+
+func (q *dropOldestQueue) register() *queueTicket {
+	t := &queueTicket{dropped: make(chan struct{})}
+	q.mu.Lock()
+	q.order = append(q.order, t)
+	q.mu.Unlock()
+	return t
+}
+
+func (q *dropOldestQueue) unregister(t *queueTicket) {
+	q.mu.Lock()
+	for i, e := range q.order {
+		if e == t {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+	q.mu.Unlock()
+}
+
+// evictOldest closes the longest-registered still-parked ticket's
+// dropped channel and removes it from the queue, reporting whether
+// there was one to evict.
+func (q *dropOldestQueue) evictOldest() bool {
+	q.mu.Lock()
+	if len(q.order) == 0 {
+		q.mu.Unlock()
+		return false
+	}
+	oldest := q.order[0]
+	q.order = q.order[1:]
+	q.mu.Unlock()
+	close(oldest.dropped)
+	return true
+}
@@ -0,0 +1,173 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDropOldestQueueEvictsInFIFOOrder(t *testing.T) {
+	q := newDropOldestQueue()
+	t1 := q.register()
+	t2 := q.register()
+
+	if !q.evictOldest() {
+		t.Fatal("expected an eviction with two registered tickets")
+	}
+	select {
+	case <-t1.dropped:
+	default:
+		t.Fatal("expected the first-registered ticket to be evicted first")
+	}
+	select {
+	case <-t2.dropped:
+		t.Fatal("expected the second ticket to still be registered")
+	default:
+	}
+}
+
+func TestDropOldestQueueEvictOldestFalseWhenEmpty(t *testing.T) {
+	q := newDropOldestQueue()
+	if q.evictOldest() {
+		t.Fatal("expected no eviction from an empty queue")
+	}
+}
+
+func TestDropOldestQueueUnregisterRemovesTicket(t *testing.T) {
+	q := newDropOldestQueue()
+	t1 := q.register()
+	q.unregister(t1)
+
+	if q.evictOldest() {
+		t.Fatal("expected no eviction after the only ticket was unregistered")
+	}
+}
+
+func TestWaitToQueueWithTimeoutSucceedsWhenSlotFreesInTime(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: 1})
+	if !bw.queueRequest("") {
+		t.Fatal("expected the first queueRequest to fill the only slot")
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		bw.dequeueRequest("")
+	}()
+
+	if !bw.waitToQueueWithTimeout(context.Background(), "", 200*time.Millisecond) {
+		t.Fatal("expected waitToQueueWithTimeout to succeed once the slot freed")
+	}
+}
+
+func TestWaitToQueueWithTimeoutGivesUpAfterTimeout(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: 1})
+	if !bw.queueRequest("") {
+		t.Fatal("expected the first queueRequest to fill the only slot")
+	}
+
+	if bw.waitToQueueWithTimeout(context.Background(), "", 5*time.Millisecond) {
+		t.Fatal("expected waitToQueueWithTimeout to give up with the slot never freed")
+	}
+}
+
+func TestQueueFullPolicyDefaultsToReject(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: 1, UseClientQueueLength: true})
+	if !bw.queueRequest("") {
+		t.Fatal("expected the first queueRequest to fill the only slot")
+	}
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError, got %v", err)
+	}
+	if de.Reason != QueueFull {
+		t.Fatalf("expected Reason QueueFull, got %v", de.Reason)
+	}
+}
+
+func TestQueueFullPolicyBlockWithTimeoutAdmitsOnceASlotFrees(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: 1, UseClientQueueLength: true}, WithQueueFullPolicy(BlockWithTimeout, 200*time.Millisecond))
+	if !bw.queueRequest("") {
+		t.Fatal("expected the first queueRequest to fill the only slot")
+	}
+	bw.SetCredit("", 1)
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		bw.dequeueRequest("")
+	}()
+
+	if _, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), ""); err != nil {
+		t.Fatalf("expected the blocked request to be admitted once a slot freed, got err=%v", err)
+	}
+}
+
+func TestQueueFullPolicyDropOldestEvictsTheLongestWaitingRequest(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, UseClientQueueLength: true}, WithQueueFullPolicy(DropOldest, 0))
+	bw.SetCredit("", 0)
+
+	// Park one waiter first, so it is the oldest (and only) registered
+	// ticket DropOldest can evict, then fill the instance-wide queue's
+	// remaining slots by hand; oldestDone is that parked waiter.
+	oldestDone := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		oldestDone <- err
+	}()
+	waitForDemand(t, bw, "", 1)
+
+	for bw.Demand("") < int(MAX_Q_LENGTH) {
+		if !bw.queueRequest("") {
+			break
+		}
+	}
+
+	// The new arrival finds the queue full, so DropOldest evicts the
+	// first waiter above to make room for it.
+	newArrivalDone := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		newArrivalDone <- err
+	}()
+
+	select {
+	case err := <-oldestDone:
+		var de *DropError
+		if !errors.As(err, &de) {
+			t.Fatalf("expected the oldest waiter to be dropped with a *DropError, got %v", err)
+		}
+		if de.Reason != QueueFull {
+			t.Fatalf("expected Reason QueueFull for the evicted waiter, got %v", de.Reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the oldest waiter to be evicted")
+	}
+
+	// Grant credit so the new arrival, which queued in the slot the
+	// eviction freed and then parked on the zero balance, is admitted and
+	// its goroutine doesn't outlive the test.
+	bw.SetCredit("", 1000)
+	select {
+	case err := <-newArrivalDone:
+		if err != nil {
+			t.Fatalf("expected the new arrival to be admitted, got err=%v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the new arrival to be admitted")
+	}
+}
+
+// waitForDemand polls until bw's Demand for method reaches n, rather
+// than sleeping a fixed guess.
+func waitForDemand(t *testing.T, bw *Breakwater, method string, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if bw.Demand(method) == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for demand %d on %q", n, method)
+}
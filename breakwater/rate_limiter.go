@@ -0,0 +1,80 @@
+package breakwater
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+tokenBucket is a minimal token bucket backing WithMaxAdmitRate: a
+deterministic pace cap on the admit path, independent of and applied
+after credit acquisition, so a chaos test can reproduce a slow
+downstream server without touching the credit protocol itself. Callers
+pass "now" in rather than this type reading a clock itself, so it stays
+consistent with whatever Clock the owning Breakwater is using (see
+WithClock) regardless of option application order.
+*/
+type tokenBucket struct {
+	mu         sync.Mutex
+	ratePerSec float64
+	burst      float64
+	tokens     float64
+	lastRefill time.Time
+	seeded     bool
+}
+
+// newTokenBucket returns nil for ratePerSec <= 0, so every method on
+// *tokenBucket is written to treat a nil receiver as "disabled, always
+// allow" and every call site can skip a separate enabled check.
+func newTokenBucket(ratePerSec float64) *tokenBucket {
+	if ratePerSec <= 0 {
+		return nil
+	}
+	burst := ratePerSec
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{ratePerSec: ratePerSec, burst: burst, tokens: burst}
+}
+
+// take refills for however long has elapsed since the last call (or
+// since construction, for the first one), then removes one token if
+// available, reporting whether it succeeded.
+func (tb *tokenBucket) take(now time.Time) bool {
+	if tb == nil {
+		return true
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if !tb.seeded {
+		tb.lastRefill = now
+		tb.seeded = true
+	}
+	if elapsed := now.Sub(tb.lastRefill).Seconds(); elapsed > 0 {
+		if tb.tokens += elapsed * tb.ratePerSec; tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+		tb.lastRefill = now
+	}
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// retryAfter estimates how long a caller that just lost take should wait
+// before trying again, from the current shortfall, so awaitAdmitRate
+// can pace its retries instead of busy-looping.
+func (tb *tokenBucket) retryAfter() time.Duration {
+	if tb == nil {
+		return 0
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	shortfall := 1 - tb.tokens
+	if shortfall <= 0 {
+		return 0
+	}
+	return time.Duration(shortfall / tb.ratePerSec * float64(time.Second))
+}
@@ -0,0 +1,115 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketDisabledWhenRateIsNonPositive(t *testing.T) {
+	if newTokenBucket(0) != nil {
+		t.Fatal("expected a non-positive rate to disable the bucket")
+	}
+	if newTokenBucket(-1) != nil {
+		t.Fatal("expected a negative rate to disable the bucket")
+	}
+}
+
+func TestTokenBucketAllowsUpToBurstThenBlocks(t *testing.T) {
+	tb := newTokenBucket(10)
+	now := time.Now()
+
+	if !tb.take(now) {
+		t.Fatal("expected the first take to succeed from a full bucket")
+	}
+	// burst is max(ratePerSec, 1) = 10, so 9 more should still succeed
+	// with no time having elapsed.
+	for i := 0; i < 9; i++ {
+		if !tb.take(now) {
+			t.Fatalf("expected take #%d to still succeed within burst", i+2)
+		}
+	}
+	if tb.take(now) {
+		t.Fatal("expected the bucket to be dry after burst consecutive takes")
+	}
+}
+
+func TestTokenBucketRefillsOverTime(t *testing.T) {
+	tb := newTokenBucket(10) // 10/sec
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		tb.take(now)
+	}
+	if tb.take(now) {
+		t.Fatal("expected the bucket to be dry")
+	}
+	if !tb.take(now.Add(200 * time.Millisecond)) {
+		t.Fatal("expected ~2 tokens to have refilled after 200ms at 10/sec")
+	}
+}
+
+func TestAwaitAdmitRateReturnsImmediatelyWhenDisabled(t *testing.T) {
+	bw := New(BWParametersDefault)
+	if err := bw.awaitAdmitRate(context.Background(), bw.clock.Now()); err != nil {
+		t.Fatalf("expected no wait with no admit rate configured, got %v", err)
+	}
+}
+
+func TestAwaitAdmitRateAdmitsWithinBurst(t *testing.T) {
+	bw := New(BWParametersDefault, WithMaxAdmitRate(1000)) // generous burst, fast refill
+	start := bw.clock.Now()
+	if err := bw.awaitAdmitRate(context.Background(), start); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAwaitAdmitRateDropsOnContextCancellation(t *testing.T) {
+	bw := New(BWParametersDefault, WithMaxAdmitRate(1))
+	// Drain the single burst token so the next wait actually blocks.
+	bw.admitRateLimiter.take(bw.clock.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bw.awaitAdmitRate(ctx, bw.clock.Now()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestUnaryInterceptorClientHonorsMaxAdmitRate(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithMaxAdmitRate(1000))
+	bw.SetCredit("", 10)
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := bw.PeekCredit(""); got != 9 {
+		t.Fatalf("expected the rate limiter to still let an admitted request debit its credit, got balance %d", got)
+	}
+}
+
+func TestAcquireCreditLoopDropsWithExpiredWhenRateLimiterNeverOpens(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH, ClientExpiration: 50_000, UseClientTimeExpiration: true}, WithMaxAdmitRate(0.001))
+	bw.SetCredit("", 10)
+	// Drain the burst so the very next admission has to wait on the
+	// bucket -- at 0.001/sec, effectively forever relative to the 50ms
+	// ClientExpiration budget above, which the request still has in full
+	// when it takes its credit.
+	bw.admitRateLimiter.take(bw.clock.Now())
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	if err == nil {
+		t.Fatal("expected the request to be dropped once its expiration budget ran out waiting on the rate limiter")
+	}
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError, got %v", err)
+	}
+	if de.Reason != Expired {
+		t.Fatalf("expected Reason Expired, got %v", de.Reason)
+	}
+	if got := bw.PeekCredit(""); got != 10 {
+		t.Fatalf("expected the credit to be released back on drop, got balance %d", got)
+	}
+}
@@ -0,0 +1,52 @@
+package breakwater
+
+import "sync/atomic"
+
+/*
+Reset clears this instance's credit and queue state back to what it was
+at construction, for a long-lived ClientConn that has reconnected to a
+fresh server instance: the old server never issued the balance this
+client is still carrying, so keeping it causes an overshoot against the
+new one. Call it from a connection state watcher on a
+TRANSIENT_FAILURE->READY transition (or equivalent), once per
+reconnect.
+
+Reset is safe to call with requests in flight. Restoring a pool's
+balance goes through the same set() path SetCredit does, so any request
+already parked on that pool's noCreditBlocker is woken exactly as a
+credit grant would wake it, and simply re-checks against the freshly
+reset balance instead of being dropped. Draining a pool's queue removes
+only the tokens present at the moment Reset runs, paired with the same
+demandCount decrement dequeueRequest itself would do; a request that
+queued its own slot just before Reset and hasn't dequeued it yet may
+find its own later dequeue call a no-op (Reset got there first), which
+undercounts demand by one until new requests queue -- it can't corrupt
+demandCount or strand a waiter, since decrementing only ever happens
+paired with an actual removal.
+
+Per-method and per-class pools (see WithMethodConfig, WithCreditClasses)
+are reset the same way as the instance-wide pool, since a reconnect
+invalidates all of them, not just whichever one a given call happens to
+use.
+*/
+func (b *Breakwater) Reset() {
+	b.resetPool("", b.initialCredits)
+	b.methodPools.Range(func(key, value any) bool {
+		p := value.(*methodPool)
+		b.resetPool(key.(string), p.initialCredits)
+		return true
+	})
+
+	atomic.StoreUint64(&b.droppedCount, 0)
+	atomic.StoreUint64(&b.expiredCount, 0)
+	atomic.StoreUint64(&b.bypassedCount, 0)
+	atomic.StoreUint64(&b.backoffMicros, 0)
+}
+
+// resetPool restores key's balance to initial and drains whatever is
+// currently sitting in its queue.
+func (b *Breakwater) resetPool(key string, initial int64) {
+	b.SetCredit(key, initial)
+	for b.dequeueRequest(key) {
+	}
+}
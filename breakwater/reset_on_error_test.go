@@ -0,0 +1,87 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryInterceptorClientResetsCreditOnConfiguredErrorCode(t *testing.T) {
+	bw := New(BWParametersDefault, WithResetOnErrorCodes([]codes.Code{codes.Unavailable}))
+	bw.SetCredit("", 42)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "backend down")
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err == nil {
+		t.Fatal("expected the invoker error to propagate")
+	}
+
+	// acquireAdmission debits 1 from the balance of 42 before the invoker
+	// ever runs, so a reset lands back on bw.initialCredits, not 42 - 1.
+	if got := bw.PeekCredit(""); got != bw.initialCredits {
+		t.Fatalf("expected the balance to reset to %d, got %d", bw.initialCredits, got)
+	}
+}
+
+func TestUnaryInterceptorClientLeavesOtherErrorCodesToCreditsOnFail(t *testing.T) {
+	bw := New(BWParametersDefault, WithResetOnErrorCodes([]codes.Code{codes.Unavailable}), WithCreditsOnFail(true))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.ResourceExhausted, "slow down")
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err == nil {
+		t.Fatal("expected the invoker error to propagate")
+	}
+
+	// Not a reset code: falls through to creditsOnFail's add-one, same as
+	// if WithResetOnErrorCodes had never been configured.
+	if got := bw.PeekCredit(""); got != 1 {
+		t.Fatalf("expected credit balance 1 from the creditsOnFail fallback, got %d", got)
+	}
+}
+
+func TestUnaryInterceptorClientHonorsCreditsHeaderOverResetOnError(t *testing.T) {
+	bw := New(BWParametersDefault, WithResetOnErrorCodes([]codes.Code{codes.Unavailable}))
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		setHeaderOpt(opts, metadata.Pairs("credits", "7"))
+		return status.Error(codes.Unavailable, "backend down")
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err == nil {
+		t.Fatal("expected the invoker error to propagate")
+	}
+
+	// An explicit server-reported balance still wins over resetting to
+	// the default, the same as it already does over creditsOnFail.
+	if got := bw.PeekCredit(""); got != 7 {
+		t.Fatalf("expected credit balance 7 from the error header, got %d", got)
+	}
+}
+
+func TestWithResetOnErrorCodesMergesAcrossCalls(t *testing.T) {
+	bw := New(BWParametersDefault,
+		WithResetOnErrorCodes([]codes.Code{codes.Unavailable}),
+		WithResetOnErrorCodes([]codes.Code{codes.Internal}),
+	)
+
+	if !bw.resetOnErrorCodes[codes.Unavailable] || !bw.resetOnErrorCodes[codes.Internal] {
+		t.Fatal("expected both calls' codes to be present in the merged set")
+	}
+}
+
+func TestResetOnErrorReturnsFalseWithoutAnyConfiguredCodes(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	if bw.resetOnError(errors.New("boom")) {
+		t.Fatal("expected resetOnError to be false with no WithResetOnErrorCodes configured")
+	}
+}
@@ -0,0 +1,82 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResetRestoresBalanceAndClearsDemand(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithInitialCredits(5), WithClientQueueLength(true))
+	bw.SetCredit("", 0)
+	if !bw.queueRequest("") {
+		t.Fatal("expected a queue slot to be available")
+	}
+
+	bw.Reset()
+
+	if got := bw.PeekCredit(""); got != 5 {
+		t.Fatalf("expected balance restored to 5, got %d", got)
+	}
+	if got := bw.getDemand(""); got != 0 {
+		t.Fatalf("expected demand cleared to 0, got %d", got)
+	}
+}
+
+func TestResetRestoresPerMethodPoolBalance(t *testing.T) {
+	bw := New(BWParametersDefault, WithMethodConfig("/svc/Expensive", MethodConfig{InitialCredits: 8, QueueCapacity: MAX_Q_LENGTH}))
+	bw.SetCredit("/svc/Expensive", 1)
+
+	bw.Reset()
+
+	if got := bw.PeekCredit("/svc/Expensive"); got != 8 {
+		t.Fatalf("expected method pool balance restored to 8, got %d", got)
+	}
+}
+
+func TestResetUnblocksAWaiterInsteadOfStrandingIt(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithInitialCredits(3))
+	bw.SetCredit("", 0)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		result <- err
+	}()
+
+	// Give the waiter a moment to park on noCreditBlocker before Reset runs.
+	time.Sleep(20 * time.Millisecond)
+
+	bw.Reset()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected the parked waiter to be admitted after Reset, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Reset to unblock the parked waiter, but it stayed stranded")
+	}
+}
+
+func TestResetClearsCounters(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithClientQueueLength(true))
+	bw.SetCredit("", 0)
+	for i := 0; i < MAX_Q_LENGTH; i++ {
+		if !bw.queueRequest("") {
+			t.Fatalf("expected queue slot %d to be available", i)
+		}
+	}
+	if _, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), ""); err == nil {
+		t.Fatal("expected a drop with the queue full")
+	}
+	if bw.Stats().Dropped == 0 {
+		t.Fatal("expected Dropped to be nonzero before Reset")
+	}
+
+	bw.Reset()
+
+	if got := bw.Stats().Dropped; got != 0 {
+		t.Fatalf("expected Dropped reset to 0, got %d", got)
+	}
+}
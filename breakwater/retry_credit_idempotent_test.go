@@ -0,0 +1,34 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestUnaryInterceptorClientAppliesOnlyFinalCreditsOnRetry simulates an
+// invoker whose underlying transport retried once internally, appending a
+// second "credits" entry to the same header rather than replacing the
+// first. Only the last (final-attempt) value must end up applied, not an
+// average or a double-application of both.
+func TestUnaryInterceptorClientAppliesOnlyFinalCreditsOnRetry(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md := metadata.MD{}
+		md.Append("credits", "100")
+		md.Append("credits", "150")
+		setHeaderOpt(opts, md)
+		return nil
+	}
+
+	if err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := bw.PeekCredit(""); got != 150 {
+		t.Fatalf("expected the final retry's reported balance 150 to win, got %d", got)
+	}
+}
@@ -3,12 +3,12 @@ package breakwater
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math"
 	"runtime/metrics"
 	"strconv"
 	"time"
 
-	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -16,7 +16,7 @@ import (
 )
 
 // We need another fast function for server side interceptor to check and register client
-func (b *Breakwater) RegisterClient(id uuid.UUID, demand int64) {
+func (b *Breakwater) RegisterClient(id string, demand int64) {
 	// Check if the client already exists, if so, return.
 	if _, exists := b.clientMap.Load(id); exists {
 		return
@@ -37,14 +37,101 @@ func (b *Breakwater) RegisterClient(id uuid.UUID, demand int64) {
 
 	// Use LoadOrStore to attempt to store the new connection, and also safely check if it was already present.
 	b.clientMap.Store(id, c)
+	b.incrementNumClients()
+}
+
+/*
+DeregisterClient removes id's Connection entry, for a client that is
+shutting down cleanly and wants cTotal/numClients recomputed for the
+remaining clients immediately rather than waiting out clientTTL. It is
+RegisterClient's counterpart: call it from wherever the application
+tears down the client side of the connection (UnaryInterceptorClient
+does not do this on its own, since a single RPC failing is not the same
+as the client going away).
+
+A client that crashes instead of calling DeregisterClient is still
+handled: evictStaleClients expires its registration once clientTTL
+passes since its lastUpdated, the same heartbeat-timeout mechanism
+WithClientTTL already provides, bumped by every request the client
+successfully sends.
+*/
+func (b *Breakwater) DeregisterClient(id string) {
+	if _, existed := b.clientMap.LoadAndDelete(id); existed {
+		b.decrementNumClients()
+		b.logDebug("client deregistered", slog.String("clientID", id))
+	}
+}
+
+/*
+updateClientDemand records demand as clientID's most recently reported
+value, under the same demandWriteLock token ServerStats already reads
+through. This backs WithDemandReportInterval's batched reporting: a
+request that omits the "demand" header (because reporting isn't due
+yet) falls back to whatever the last request that did report left here,
+via lastKnownDemand, instead of the server seeing a missing or zero
+demand for every in-between request.
+*/
+func (b *Breakwater) updateClientDemand(id string, demand int64) {
+	connection, ok := b.clientMap.Load(id)
+	if !ok {
+		return
+	}
+	c := connection.(Connection)
+	<-c.demandWriteLock
+	c.demand = demand
+	b.clientMap.Store(id, c)
+	c.demandWriteLock <- 1
+}
+
+// lastKnownDemand returns id's most recently reported demand (via
+// updateClientDemand or its initial RegisterClient value), and whether
+// id is a registered client at all.
+func (b *Breakwater) lastKnownDemand(id string) (int64, bool) {
+	connection, ok := b.clientMap.Load(id)
+	if !ok {
+		return 0, false
+	}
+	c := connection.(Connection)
+	lockToken := <-c.demandWriteLock
+	demand := c.demand
+	c.demandWriteLock <- lockToken
+	return demand, true
+}
+
+// NumClients returns the number of clients currently registered, the
+// same count the credit-distribution formula uses: calculateCreditsToOvercommit
+// divides the unissued credits by it and getAdditiveFactor scales aFactor by it.
+func (b *Breakwater) NumClients() int64 {
+	num := <-b.numClients
+	b.numClients <- num
+	return num
+}
+
+func (b *Breakwater) incrementNumClients() {
 	num := <-b.numClients
 	b.numClients <- num + 1
 }
 
+func (b *Breakwater) decrementNumClients() {
+	num := <-b.numClients
+	b.numClients <- max(num-1, 0)
+}
+
 /*
 Helper to get current time delay
+
+getDelay prefers measuredDelayEWMABits, the EWMA of real per-request
+handler latency recorded by UnaryInterceptor (see recordMeasuredDelay),
+over the /sched/latencies runtime histogram below. The histogram is only
+a process-wide scheduler-latency proxy, not this instance's own
+requests; it is kept as the startup fallback for the window before the
+first request has seeded the EWMA.
 */
 func (b *Breakwater) getDelay() float64 {
+	if b.measuredDelayEWMASeeded.Load() {
+		return math.Float64frombits(uint64(b.measuredDelayEWMABits.Load()))
+	}
+
 	// get the current histogram
 	b.currHist = readHistogram()
 
@@ -61,6 +148,32 @@ func (b *Breakwater) getDelay() float64 {
 	return gapLatency
 }
 
+/*
+recordMeasuredDelay folds sampleUs, one request's handler latency in
+microseconds, into measuredDelayEWMABits and returns the updated
+average. UnaryInterceptor can't time true queueing -- it never parks a
+request before calling handler -- so, per WithQueueDelayEWMAAlpha's
+documented fallback, this measures the handler call itself: growing
+handler latency is itself a meaningful symptom of an overloaded server,
+even though it is not literally time spent waiting in a queue.
+*/
+func (b *Breakwater) recordMeasuredDelay(sampleUs float64) float64 {
+	if !b.measuredDelayEWMASeeded.Load() {
+		b.measuredDelayEWMABits.Store(int64(math.Float64bits(sampleUs)))
+		b.measuredDelayEWMASeeded.Store(true)
+		return sampleUs
+	}
+	for {
+		curBits := b.measuredDelayEWMABits.Load()
+		cur := math.Float64frombits(uint64(curBits))
+		next := b.queueDelayEWMAAlpha*sampleUs + (1-b.queueDelayEWMAAlpha)*cur
+		nextBits := int64(math.Float64bits(next))
+		if b.measuredDelayEWMABits.CompareAndSwap(curBits, nextBits) {
+			return next
+		}
+	}
+}
+
 // we should be able to avoid the GetHistogramDifference function by using the following function
 // Find the maximum bucket between two Float64Histogram distributions
 func maximumQueuingDelayus(earlier, later *metrics.Float64Histogram) float64 {
@@ -110,7 +223,8 @@ func (b *Breakwater) getAdditiveFactor() int64 {
 }
 
 func (b *Breakwater) getMultiplicativeFactor(delay float64) float64 {
-	adjustingFactor := 1.0 - b.bFactor*((delay-b.thresholdDelay)/b.thresholdDelay)
+	thresholdDelay := b.thresholdDelay.Load()
+	adjustingFactor := 1.0 - b.bFactor*((delay-thresholdDelay)/thresholdDelay)
 	adjustingFactor = math.Max(adjustingFactor, 0.5)
 	return adjustingFactor
 }
@@ -136,13 +250,14 @@ Runs once every RTT
 */
 func (b *Breakwater) getUpdatedTotalCredits() int64 {
 	delay := b.getDelay()
+	thresholdDelay := b.thresholdDelay.Load()
 
-	if delay < b.thresholdDelay {
-		logger("[Updating credits]: Within SLA")
+	if delay < thresholdDelay {
+		b.logDebug("updating credits, within SLA")
 		addFactor := b.getAdditiveFactor()
 		return b.cTotal + addFactor
 	} else {
-		logger("[Updating credits]: Beyond SLA, delay is %f threshold is %f", delay, b.thresholdDelay)
+		b.logDebug("updating credits, beyond SLA", slog.Float64("delay", delay), slog.Float64("threshold", thresholdDelay))
 		adjustingFactor := b.getMultiplicativeFactor(delay)
 		newTotal := roundedInt(adjustingFactor * float64(b.cTotal))
 		// Addresses edge case: credits is 0, but we need to process at least 1 request
@@ -167,7 +282,7 @@ func (b *Breakwater) rttUpdate() {
 				newDelay := b.getDelay() // Assume this function returns the new delay
 				b.queueingDelayChan <- DelayOperation{Value: newDelay}
 				// log the delay
-				logger("[RTT Update]: delay is %f", newDelay)
+				b.logDebug("rtt update", slog.Float64("delay", newDelay))
 				recordCredits("[Incremental Waiting Time Maximum]:	%f ms.\n", newDelay/1000)
 			}
 			prevCTotal := b.cTotal
@@ -188,13 +303,39 @@ func (b *Breakwater) rttUpdate() {
 			// b.prevGreatestDelay <- <-b.currGreatestDelay
 			// b.currGreatestDelay <- 0
 
-			logger("[Updating credits]: prev cTotal: %d, new cTotal: %d, cIssued: %d", prevCTotal, b.cTotal, totalIssued)
+			b.logDebug("updating credits", slog.Int64("prevCTotal", prevCTotal), slog.Int64("cTotal", b.cTotal), slog.Int64("cIssued", totalIssued))
 			recordCredits("[Credit Update per RTT]: cTotal updated from %d to %d, cIssued: %d", prevCTotal, b.cTotal, totalIssued)
+			b.evictStaleClients()
 			b.rttLock <- 1
 		}
 	}
 }
 
+/*
+evictStaleClients removes Connection entries (and decrements numClients)
+for clients whose lastUpdated is older than clientTTL, bounding clientMap's
+size for long-running servers with high client churn. A non-positive
+clientTTL disables eviction.
+*/
+func (b *Breakwater) evictStaleClients() {
+	if b.clientTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	b.clientMap.Range(func(key, value interface{}) bool {
+		c := value.(Connection)
+		lastUpdated := <-c.lastUpdated
+		c.lastUpdated <- lastUpdated
+
+		if now.Sub(lastUpdated) > b.clientTTL {
+			b.clientMap.Delete(key)
+			b.decrementNumClients()
+			b.logDebug("evicted stale client", slog.String("clientID", key.(string)))
+		}
+		return true
+	})
+}
+
 /*
 Number of over-committed credits per client
 */
@@ -212,7 +353,7 @@ and cPrevious - 1
 */
 func (b *Breakwater) getLowerCreditsIssued(cOvercommit int64, demand int64, cPrevious int64) int64 {
 	if (demand + cOvercommit) < 0 {
-		logger("WARNING: demand + cOvercommit < 0")
+		b.logWarn("demand + cOvercommit < 0")
 		return 1
 	}
 	cNew := min(demand+cOvercommit, cPrevious-1)
@@ -225,7 +366,7 @@ and cCurr + cAvail (ie we cannot add more than cAvail)
 */
 func (b *Breakwater) getHigherCreditsIssued(cOvercommit int64, demand int64, cPrevious int64) int64 {
 	if (demand + cOvercommit) < 0 {
-		logger("WARNING: demand + cOvercommit < 0")
+		b.logWarn("demand + cOvercommit < 0")
 		return 1
 	}
 	cIssued := <-b.cIssued
@@ -239,18 +380,18 @@ func (b *Breakwater) getHigherCreditsIssued(cOvercommit int64, demand int64, cPr
 
 func (b *Breakwater) calculateCreditsToIssue(demand int64, connCPrevious int64) (cNew int64) {
 	cOverCommit := b.calculateCreditsToOvercommit()
-	logger("[Issuing credits]: cOverCommit is %d", cOverCommit)
+	b.logDebug("issuing credits", slog.Int64("cOverCommit", cOverCommit))
 	cIssued := <-b.cIssued
 	b.cIssued <- cIssued
 
 	// Here, b.cIssued is OVERALL issued credits, while c.issued is credits issued to a connection
 	if cIssued < b.cTotal {
 		// There is still space to issue credits
-		logger("[Issuing credits]: Under limit, cIssued is %d, cTotal is %d", cIssued, b.cTotal)
+		b.logDebug("issuing credits, under limit", slog.Int64("cIssued", cIssued), slog.Int64("cTotal", b.cTotal))
 		cNew = b.getHigherCreditsIssued(cOverCommit, demand, connCPrevious)
 	} else {
 		// At credit limit, so we only decrease
-		logger("[Issuing credits]: Over limit, cIssued is %d, cTotal is %d", cIssued, b.cTotal)
+		b.logDebug("issuing credits, over limit", slog.Int64("cIssued", cIssued), slog.Int64("cTotal", b.cTotal))
 		cNew = b.getLowerCreditsIssued(cOverCommit, demand, connCPrevious)
 	}
 
@@ -267,13 +408,13 @@ Ideal to be issued is demandX + cOC, but limited by total available (cTotal - cI
 4. If cIssued >= cTotal:
 We need to rate limit, so we issue demandX + cOC, OR just cX - 1 (ie we do not grant any new credits)
 */
-func (b *Breakwater) updateCreditsToIssue(clientID uuid.UUID, demand int64) (cNew int64) {
+func (b *Breakwater) updateCreditsToIssue(clientID string, demand int64) (cNew int64, previousIssued int64) {
 
 	connection, ok := b.clientMap.Load(clientID)
 	if !ok {
-		logger("WARNING: client not found")
+		b.logWarn("client not found")
 		// throw an error
-		return 0
+		return 0, 0
 	}
 	c := connection.(Connection)
 
@@ -288,15 +429,20 @@ func (b *Breakwater) updateCreditsToIssue(clientID uuid.UUID, demand int64) (cNe
 	connCPrevious := c.issued
 	if connTimeOfLastUpdate.After(b.lastUpdateTime) {
 		// It was already updated after the last RTT update
-		logger("[Issuing credits]: Auto Decr")
+		b.logDebug("issuing credits, auto decrement")
 		cNew = max(connCPrevious-1, 1)
 	} else {
 		// not yet updated after the last RT update, so have to update
-		logger("[Issuing credits]: Post RTT")
+		b.logDebug("issuing credits, post RTT")
 		cNew = b.calculateCreditsToIssue(demand, connCPrevious)
 	}
 
-	logger("[Issuing credits]: Client %s, cPrev issued: %d, cNew: %d", clientID, connCPrevious, cNew)
+	if b.maxCreditsPerClient > 0 && cNew > b.maxCreditsPerClient {
+		b.logDebug("clamping issued credits to the per-client cap", slog.String("clientID", clientID), slog.Int64("cNew", cNew), slog.Int64("maxCreditsPerClient", b.maxCreditsPerClient))
+		cNew = b.maxCreditsPerClient
+	}
+
+	b.logDebug("issuing credits", slog.String("clientID", clientID), slog.Int64("cPrevIssued", connCPrevious), slog.Int64("cNew", cNew))
 
 	// update conn credits
 	c.issued = cNew
@@ -307,12 +453,12 @@ func (b *Breakwater) updateCreditsToIssue(clientID uuid.UUID, demand int64) (cNe
 	prevCIssued := <-b.cIssued
 	b.cIssued <- prevCIssued + diff
 	if (prevCIssued + diff) < 0 {
-		logger("WARNING: cIssued < 0")
+		b.logWarn("cIssued < 0")
 	}
 
 	c.issuedWriteLock <- 1
 	c.lastUpdated <- time.Now()
-	return
+	return cNew, connCPrevious
 }
 
 /*
@@ -324,16 +470,22 @@ It should
 4. Occassionally update cTotal
 */
 func (b *Breakwater) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	loadLevel := int64(-1) // -1 means "not measured this request", loadShedding is off
 	if loadShedding {
 		responseChan := make(chan float64)
 		b.queueingDelayChan <- DelayOperation{Response: responseChan}
 		queueingDelay := <-responseChan // This will wait for the response
-		// logger("[Req handled]: Server-side queuing delay is %f microseconds", queueingDelay)
+		// Server-side queuing delay in microseconds
+		aqmDelay := b.aqmDelay.Load()
+		loadLevel = normalizeLoad(queueingDelay, aqmDelay)
 
-		if queueingDelay < b.aqmDelay {
-			logger("[Load Shedding] not applied, server-side queuing delay %f us is within AQM threshold", queueingDelay)
+		if queueingDelay < aqmDelay {
+			b.logDebug("load shedding not applied, within AQM threshold", slog.Float64("queueingDelayMicros", queueingDelay))
 		} else {
-			logger("[Load Shedding] applied, server-side queuing delay %f us is beyond AQM threshold", queueingDelay)
+			b.logDebug("load shedding applied, beyond AQM threshold", slog.Float64("queueingDelayMicros", queueingDelay))
+			if err := grpc.SetHeader(ctx, metadata.Pairs("load", strconv.FormatInt(loadLevel, 10))); err != nil {
+				b.logWarn("failed to set header", slog.Any("err", err))
+			}
 			return nil, status.Errorf(codes.ResourceExhausted, "Server-side queuing delay is beyond AQM threshold")
 		}
 	}
@@ -343,55 +495,122 @@ func (b *Breakwater) UnaryInterceptor(ctx context.Context, req interface{}, info
 		return nil, errMissingMetadata
 	}
 
-	// check if metadata has demand and id, if not, assume the client is not using breakwater! If so, jump to handler and bypass overload control
-	// if both are present, do the overload control
-	if _, ok := md["demand"]; ok {
-		if _, ok := md["id"]; ok {
-			logger("[Received Req]:	Received metadata")
-
-			demand, err1 := strconv.ParseInt(md["demand"][0], 10, 64)
-			clientId, err2 := uuid.Parse(md["id"][0])
-			// reqId, err3 := uuid.Parse(md["reqid"][0])
+	if loadLevel >= 0 {
+		// Read-only signal for clients that want to make their own
+		// backoff decisions; attached regardless of whether this client
+		// sent the demand/id metadata breakwater itself relies on.
+		if err := grpc.SetHeader(ctx, metadata.Pairs("load", strconv.FormatInt(loadLevel, 10))); err != nil {
+			b.logWarn("failed to set header", slog.Any("err", err))
+		}
+	}
 
-			if err1 != nil || err2 != nil {
-				logger("[Received Req]:	Error: malformed metadata")
+	// Resolve the per-client key; if that comes back empty, assume the
+	// client is not using breakwater at all, and jump to handler
+	// bypassing overload control. "demand" on its own is no longer
+	// required here: WithDemandReportInterval lets a client omit it on
+	// requests where reporting isn't due yet, in which case
+	// lastKnownDemand below supplies the last value it did report.
+	if clientId := b.clientKey(ctx, md); clientId != "" {
+		b.logDebug("received metadata")
+
+		var demand int64
+		if values, ok := md["demand"]; ok {
+			d, err1 := strconv.ParseInt(values[0], 10, 64)
+			if err1 != nil {
+				b.logWarn("malformed metadata", slog.String("demand", values[0]), slog.Any("err", err1))
 				return nil, errMissingMetadata
 			}
-
-			logger("[Received Req]:	ClientId: %s, Demand %d", clientId, demand)
-
-			// Register client if unregistered
+			demand = clampDemand(d, b.maxDemand.Load())
+			if demand != d {
+				b.logWarn("demand out of range, clamped", slog.Int64("reported", d), slog.Int64("clamped", demand))
+			}
 			b.RegisterClient(clientId, demand)
+			b.updateClientDemand(clientId, demand)
+		} else {
+			// Batched demand reporting: this request didn't carry a
+			// fresh value, so fall back to the last one this client did
+			// report. A client we've never heard a real demand from
+			// yet (eg its very first request landed before its first
+			// report was due) registers at 0, same as before this
+			// option existed.
+			b.RegisterClient(clientId, 0)
+			demand, _ = b.lastKnownDemand(clientId)
+		}
 
-			issuedCredits := b.updateCreditsToIssue(clientId, demand)
-			logger("[Received Req]:	issued credits is %d", issuedCredits)
+		b.logDebug("received request", slog.String("clientID", clientId), slog.Int64("demand", demand))
 
-			// Piggyback updated credits issued
-			header := metadata.Pairs("credits", strconv.FormatInt(issuedCredits, 10))
-			// Set the header to be sent with the response or error
-			err := grpc.SetHeader(ctx, header)
-			if err != nil {
-				logger("Failed to set header: %v", err)
-			}
+		b.checkCreditModeMismatch(md, clientId)
+
+		issuedCredits, previousIssued := b.updateCreditsToIssue(clientId, demand)
+		if b.chainedCreditFunc != nil {
+			issuedCredits = b.chainedCreditFunc(clientId, demand, issuedCredits)
+		}
+		b.logDebug("issued credits", slog.Int64("issuedCredits", issuedCredits))
+
+		// Piggyback updated credits issued, as an absolute balance or a
+		// signed delta from previousIssued depending on creditUpdateMode
+		// (see CreditUpdateMode); credit-mode is echoed back so the
+		// client can confirm it decoded the value the way this server
+		// actually sent it.
+		creditsValue := issuedCredits
+		if b.creditUpdateMode == Additive {
+			creditsValue = issuedCredits - previousIssued
+		}
+		header := metadata.Pairs(
+			"credits", strconv.FormatInt(creditsValue, 10),
+			"credit-mode", b.creditUpdateMode.String(),
+		)
+		// Set the header to be sent with the response or error
+		err := grpc.SetHeader(ctx, header)
+		if err != nil {
+			b.logWarn("failed to set header", slog.Any("err", err))
 		}
 	}
 	// Call the handler function to handle the request
-	logger("[Handling Req]:	Handling req")
+	b.logDebug("handling request")
+	handlerStart := time.Now()
 	m, err := handler(ctx, req)
+	b.recordMeasuredDelay(float64(time.Since(handlerStart).Microseconds()))
 
 	// Does update once every rtt in separate goroutine
 	go b.rttUpdate()
 
 	if err != nil {
-		logger("RPC failed with error %v", err)
+		b.logDebug("rpc failed", slog.Any("err", err))
 	}
 	return m, err
 }
 
+// normalizeLoad scales a measured server-side queueing delay against
+// aqmDelay, the hard load-shedding threshold, into a 0-100 level: 0 is
+// idle, 100 is at or beyond the point load shedding kicks in. Reported
+// to clients via the "load" header so they can make their own backoff
+// decisions independent of breakwater's own credit math.
+func normalizeLoad(delayMicros, aqmDelayMicros float64) int64 {
+	if aqmDelayMicros <= 0 {
+		return 0
+	}
+	level := roundedInt(delayMicros / aqmDelayMicros * 100)
+	return max(0, min(level, 100))
+}
+
+// clampDemand bounds a client-reported demand to [0, max]: negative values
+// (a buggy client, or one deliberately gaming admission) clamp to 0 rather
+// than flowing into updateCreditsToIssue as-is, and anything above max --
+// see WithMaxDemand -- clamps down to it instead of being treated at face
+// value.
+func clampDemand(demand, max int64) int64 {
+	if demand < 0 {
+		return 0
+	}
+	if demand > max {
+		return max
+	}
+	return demand
+}
+
 func (b *Breakwater) PrintOutgoingCredits() {
-	o := <-b.outgoingCredits
-	logger("Outgoing credits: ", o)
-	b.outgoingCredits <- o
+	b.logDebug("outgoing credits", slog.Int64("creditBalance", b.PeekCredit("")))
 }
 
 /*
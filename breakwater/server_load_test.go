@@ -0,0 +1,35 @@
+package breakwater
+
+import "testing"
+
+func TestNormalizeLoad(t *testing.T) {
+	cases := []struct {
+		delay, aqm float64
+		want       int64
+	}{
+		{0, 1000, 0},
+		{500, 1000, 50},
+		{1000, 1000, 100},
+		{2000, 1000, 100}, // clamped
+		{500, 0, 0},       // aqmDelay misconfigured, avoid divide-by-zero
+	}
+	for _, c := range cases {
+		if got := normalizeLoad(c.delay, c.aqm); got != c.want {
+			t.Errorf("normalizeLoad(%v, %v) = %d, want %d", c.delay, c.aqm, got, c.want)
+		}
+	}
+}
+
+func TestNoteServerLoadUpdatesStats(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	if got := bw.Stats().ServerLoad; got != -1 {
+		t.Fatalf("expected ServerLoad to start at -1, got %d", got)
+	}
+
+	bw.noteServerLoad(map[string][]string{"load": {"73"}})
+
+	if got := bw.Stats().ServerLoad; got != 73 {
+		t.Fatalf("expected ServerLoad 73 after noteServerLoad, got %d", got)
+	}
+}
@@ -0,0 +1,30 @@
+package breakwater
+
+import "testing"
+
+func TestServerStatsReflectsRegisteredClientsAndDemand(t *testing.T) {
+	bw := InitBreakwater(BWParametersDefault)
+
+	bw.RegisterClient("client-a", 3)
+	bw.RegisterClient("client-b", 5)
+
+	stats := bw.ServerStats()
+	if stats.RegisteredClients != 2 {
+		t.Fatalf("expected 2 registered clients, got %d", stats.RegisteredClients)
+	}
+	if stats.AggregatedDemand != 8 {
+		t.Fatalf("expected aggregated demand 3+5=8, got %d", stats.AggregatedDemand)
+	}
+	if stats.TotalCredits != bw.cTotal {
+		t.Fatalf("expected TotalCredits to mirror cTotal (%d), got %d", bw.cTotal, stats.TotalCredits)
+	}
+
+	bw.DeregisterClient("client-a")
+	stats = bw.ServerStats()
+	if stats.RegisteredClients != 1 {
+		t.Fatalf("expected 1 registered client after deregistering client-a, got %d", stats.RegisteredClients)
+	}
+	if stats.AggregatedDemand != 5 {
+		t.Fatalf("expected aggregated demand to drop to 5, got %d", stats.AggregatedDemand)
+	}
+}
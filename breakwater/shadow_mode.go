@@ -0,0 +1,26 @@
+package breakwater
+
+// ShadowDropCounts returns, for each DropReason, how many requests
+// acquireCreditLoop decided it would have dropped while shadow mode
+// (see WithShadowMode) was active. Zero for every reason when shadow
+// mode has never been enabled, since real drops are counted separately
+// via Stats().
+func (b *Breakwater) ShadowDropCounts() map[DropReason]uint64 {
+	counts := make(map[DropReason]uint64, len(b.shadowDropCounts))
+	for i := range b.shadowDropCounts {
+		if n := b.shadowDropCounts[i].Load(); n > 0 {
+			counts[DropReason(i)] = n
+		}
+	}
+	return counts
+}
+
+// shadowDroppedTotal sums ShadowDropCounts across every reason, backing
+// Stats().ShadowDropped.
+func (b *Breakwater) shadowDroppedTotal() uint64 {
+	var total uint64
+	for i := range b.shadowDropCounts {
+		total += b.shadowDropCounts[i].Load()
+	}
+	return total
+}
@@ -0,0 +1,64 @@
+package breakwater
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShadowModeAdmitsAQueueFullRequestInsteadOfDropping(t *testing.T) {
+	var onDropCalls atomic.Int64
+	bw := New(BWParametersDefault,
+		WithClientQueueLength(true),
+		WithShadowMode(true),
+		WithOnDrop(func(reason DropReason, id string, waitedUs int64) {
+			onDropCalls.Add(1)
+		}),
+	)
+	bw.SetCredit("", 0)
+
+	for i := 0; i < MAX_Q_LENGTH; i++ {
+		if !bw.queueRequest("") {
+			t.Fatalf("expected queue slot %d to be available", i)
+		}
+	}
+
+	if _, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), ""); err != nil {
+		t.Fatalf("expected shadow mode to admit the request despite the full queue, got %v", err)
+	}
+
+	if got := bw.Stats().Dropped; got != 0 {
+		t.Fatalf("expected Stats().Dropped to stay 0 under shadow mode, got %d", got)
+	}
+	if got := bw.Stats().ShadowDropped; got != 1 {
+		t.Fatalf("expected ShadowDropped to count the decision, got %d", got)
+	}
+	if got := bw.ShadowDropCounts()[QueueFull]; got != 1 {
+		t.Fatalf("expected ShadowDropCounts[QueueFull] to be 1, got %d", got)
+	}
+	if got := onDropCalls.Load(); got != 1 {
+		t.Fatalf("expected OnDrop to still fire once under shadow mode, got %d", got)
+	}
+}
+
+func TestWithoutShadowModeQueueFullStillDropsForReal(t *testing.T) {
+	bw := New(BWParametersDefault, WithClientQueueLength(true))
+	bw.SetCredit("", 0)
+
+	for i := 0; i < MAX_Q_LENGTH; i++ {
+		if !bw.queueRequest("") {
+			t.Fatalf("expected queue slot %d to be available", i)
+		}
+	}
+
+	if _, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), ""); err == nil {
+		t.Fatal("expected a drop without shadow mode enabled")
+	}
+
+	if got := bw.Stats().Dropped; got != 1 {
+		t.Fatalf("expected the real Dropped counter to be incremented, got %d", got)
+	}
+	if got := bw.Stats().ShadowDropped; got != 0 {
+		t.Fatalf("expected ShadowDropped to stay 0 without shadow mode, got %d", got)
+	}
+}
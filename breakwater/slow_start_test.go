@@ -0,0 +1,55 @@
+package breakwater
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithInitialCreditsStartsLowAndDoublesUntilFirstRealTrailer(t *testing.T) {
+	bw := New(BWParametersDefault, WithInitialCredits(1))
+
+	if got := bw.PeekCredit(""); got != 1 {
+		t.Fatalf("expected the overridden starting balance of 1, got %d", got)
+	}
+
+	// Each response with no "credits" entry should double the balance:
+	// acquiring a credit first debits it back to 0, so the sequence
+	// below is 1 -(acquire)-> 0 -(double)-> 1 -(acquire)-> 0 -(double)-> 1 ...
+	// Instead drive it directly through updateCreditsFromTrailer, which
+	// is what actually performs the ramp, without the debit in between.
+	bw.updateCreditsFromTrailer(metadata.MD{}, "")
+	if got := bw.PeekCredit(""); got != 2 {
+		t.Fatalf("expected slow-start to double 1 to 2, got %d", got)
+	}
+	bw.updateCreditsFromTrailer(metadata.MD{}, "")
+	if got := bw.PeekCredit(""); got != 4 {
+		t.Fatalf("expected slow-start to double 2 to 4, got %d", got)
+	}
+
+	// The first trailer carrying a real "credits" value ends the ramp:
+	// from here on the reported value wins outright, not a doubling of
+	// whatever slow-start had reached.
+	bw.updateCreditsFromTrailer(metadata.Pairs("credits", "3"), "")
+	if got := bw.PeekCredit(""); got != 3 {
+		t.Fatalf("expected the real reported balance 3 to win, got %d", got)
+	}
+
+	bw.updateCreditsFromTrailer(metadata.MD{}, "")
+	if got := bw.PeekCredit(""); got != 3 {
+		t.Fatalf("expected slow-start to stay off after real feedback arrived, got %d", got)
+	}
+}
+
+func TestWithoutWithInitialCreditsKeepsDefaultStartingBalanceAndNoRamp(t *testing.T) {
+	bw := New(BWParametersDefault)
+
+	if got := bw.PeekCredit(""); got != 1 {
+		t.Fatalf("expected the unmodified default starting balance of 1, got %d", got)
+	}
+
+	bw.updateCreditsFromTrailer(metadata.MD{}, "")
+	if got := bw.PeekCredit(""); got != 1 {
+		t.Fatalf("expected the balance to stay unchanged without WithInitialCredits, got %d", got)
+	}
+}
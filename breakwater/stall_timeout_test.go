@@ -0,0 +1,89 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStallTimeoutFiresOnStallAndKeepsWaitingByDefault(t *testing.T) {
+	var stallCalls atomic.Int64
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH},
+		WithStallTimeout(20*time.Millisecond, false, func(id, method string, waitedUs int64) {
+			stallCalls.Add(1)
+		}),
+	)
+	bw.SetCredit("", 0)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		result <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if got := stallCalls.Load(); got != 1 {
+		t.Fatalf("expected OnStall to fire exactly once, got %d", got)
+	}
+	if got := bw.Stats().Stalled; got != 1 {
+		t.Fatalf("expected Stats().Stalled to be 1, got %d", got)
+	}
+
+	bw.SetCredit("", 1)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected the stalled-but-not-failed waiter to eventually be admitted, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the waiter to be admitted once credit arrived")
+	}
+}
+
+func TestStallTimeoutFailsTheRequestWhenConfiguredTo(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH}, WithStallTimeout(20*time.Millisecond, true, nil))
+	bw.SetCredit("", 0)
+
+	_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+	if err == nil {
+		t.Fatal("expected the stalled request to be dropped")
+	}
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError, got %v", err)
+	}
+	if de.Reason != Stalled {
+		t.Fatalf("expected Reason Stalled, got %v", de.Reason)
+	}
+	if got := bw.Stats().Stalled; got != 1 {
+		t.Fatalf("expected Stats().Stalled to be 1, got %d", got)
+	}
+}
+
+func TestStallTimeoutDisabledByDefault(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 0)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), bw.clock.Now(), "")
+		result <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	bw.SetCredit("", 1)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected the waiter to be admitted once credit arrived, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the waiter to be admitted")
+	}
+	if got := bw.Stats().Stalled; got != 0 {
+		t.Fatalf("expected Stats().Stalled to stay 0 without WithStallTimeout, got %d", got)
+	}
+}
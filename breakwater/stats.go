@@ -0,0 +1,156 @@
+package breakwater
+
+import "sync/atomic"
+
+// Stats is a point-in-time snapshot of a Breakwater client instance's
+// internal state, intended for dashboards and periodic scraping.
+type Stats struct {
+	Demand        int    // current outgoing queue length, ie number of requests in flight or waiting
+	CreditBalance int64  // current outgoing credit balance
+	QueueLength   int    // alias of Demand, kept for readability at call sites
+	Dropped       uint64 // total requests dropped locally since creation: queue-too-long, shutting down, or the WithMaxInFlight cap
+	Expired       uint64 // total requests dropped for expiration (fixed budget or context deadline) since creation
+	Bypassed      uint64 // total requests that skipped admission control since creation, via WithPriority(ctx, High) or while disabled (see Disable/WithDisabled)
+	BackoffMicros uint64 // total microseconds spent sleeping between zero-credit retries since creation, see zeroCreditBackoff
+	// ServerLoad is the most recently observed "load" header value (0-100,
+	// 0 idle, 100 at or beyond the server's own load-shedding threshold),
+	// or -1 if no response carrying it has been seen yet. It is a
+	// read-only signal reported by the server independent of credit
+	// issuance; Stats() does not act on it itself.
+	ServerLoad int64
+	// WaitLatencyP50/P95/P99 mirror WaitLatency(), included here so a
+	// dashboard already scraping Stats doesn't need a second call.
+	WaitLatencyP50 int64
+	WaitLatencyP95 int64
+	WaitLatencyP99 int64
+	// CreditsOnFailNoop counts returnCreditOnFail calls that had no cost
+	// to give back; nonzero here points at a call site debiting with
+	// cost<=0, which shouldn't happen under normal use.
+	CreditsOnFailNoop uint64
+	// ShadowDropped is the total across every reason acquireCreditLoop
+	// would have dropped while WithShadowMode was on, kept separate from
+	// Dropped/Expired since a shadow decision never actually drops
+	// anything; see ShadowDropCounts for the breakdown by reason.
+	ShadowDropped uint64
+	// Stalled counts requests that WithStallTimeout's watchdog caught
+	// parked waiting for a credit past its configured timeout, whether
+	// or not failRequest was set to actually drop them.
+	Stalled uint64
+	// CreditsGranted is the total credits the server has ever added to
+	// the outgoing balance across every response, and CreditsConsumed is
+	// the total TryAcquireCreditN has successfully debited from it.
+	// CreditUtilization is CreditsConsumed/CreditsGranted, 0 if nothing
+	// has been granted yet; a low ratio means the server is over-granting
+	// relative to what this client actually uses.
+	CreditsGranted    uint64
+	CreditsConsumed   uint64
+	CreditUtilization float64
+	// CheapHintReleases counts responses to a WithCheapHint request that
+	// came back under WithCheapHintThreshold, triggering a proactive
+	// credit release ahead of the next "credits" trailer.
+	CheapHintReleases uint64
+	// CircuitState is WithCircuitBreaker's breaker state, or CircuitClosed
+	// if WithCircuitBreaker was never called.
+	CircuitState CircuitState
+}
+
+// Stats returns a thread-safe snapshot of this Breakwater instance's
+// current state. Reading the credit balance peeks outgoingCredits without
+// permanently consuming it, so calling Stats does not perturb the
+// interceptor's hot path. It reports the instance-wide pool only; a
+// method given its own pool via WithMethodConfig isn't reflected here.
+func (b *Breakwater) Stats() Stats {
+	granted := atomic.LoadUint64(&b.creditsGranted)
+	consumed := atomic.LoadUint64(&b.creditsConsumed)
+	var utilization float64
+	if granted > 0 {
+		utilization = float64(consumed) / float64(granted)
+	}
+	circuitState := CircuitClosed
+	if b.circuitBreaker != nil {
+		circuitState = b.circuitBreaker.State()
+	}
+	return Stats{
+		Demand:            b.getDemand(""),
+		CreditBalance:     b.PeekCredit(""),
+		QueueLength:       len(b.pendingOutgoing),
+		Dropped:           atomic.LoadUint64(&b.droppedCount),
+		Expired:           atomic.LoadUint64(&b.expiredCount),
+		Bypassed:          atomic.LoadUint64(&b.bypassedCount),
+		BackoffMicros:     atomic.LoadUint64(&b.backoffMicros),
+		ServerLoad:        b.lastServerLoad.Load(),
+		WaitLatencyP50:    b.waitLatency.percentile(0.50),
+		WaitLatencyP95:    b.waitLatency.percentile(0.95),
+		WaitLatencyP99:    b.waitLatency.percentile(0.99),
+		CreditsOnFailNoop: atomic.LoadUint64(&b.creditsOnFailNoop),
+		ShadowDropped:     b.shadowDroppedTotal(),
+		Stalled:           atomic.LoadUint64(&b.stallCount),
+		CreditsGranted:    granted,
+		CreditsConsumed:   consumed,
+		CreditUtilization: utilization,
+		CheapHintReleases: atomic.LoadUint64(&b.cheapHintReleases),
+		CircuitState:      circuitState,
+	}
+}
+
+// ServerStats is a point-in-time snapshot of a Breakwater server
+// instance's internal state, mirroring the client-side Stats(). It is
+// plain and JSON-serializable so it can back an admin endpoint for
+// debugging why a particular client is being starved of credits.
+type ServerStats struct {
+	TotalCredits      int64 // cTotal, the global credit pool this RTT
+	IssuedCredits     int64 // cIssued, total credits currently issued across every registered client
+	RegisteredClients int   // number of clients currently registered, see NumClients
+	// MeasuredDelayUs is the most recently measured server-side delay, in
+	// microseconds; 0 if this instance isn't server-side or LoadShedding
+	// is off. It is an EWMA of real per-request handler latency (see
+	// WithQueueDelayEWMAAlpha) once at least one request has been served,
+	// falling back to the /sched/latencies runtime histogram before that.
+	MeasuredDelayUs  int64
+	AggregatedDemand int64 // sum of the demand each registered client most recently reported
+	// PerClientIssued maps each registered client id to its currently
+	// issued credits, so a caller using WithMaxCreditsPerClient can see
+	// who's near their cap without instrumenting updateCreditsToIssue
+	// itself.
+	PerClientIssued map[string]int64
+}
+
+// ServerStats returns a thread-safe snapshot of this Breakwater
+// instance's server-side state. RegisteredClients and AggregatedDemand
+// are read under the same clientMap/demandWriteLock synchronization
+// updateCreditsToIssue and RegisterClient use, so the snapshot can't
+// observe a client half-registered or a demand value mid-update.
+func (b *Breakwater) ServerStats() ServerStats {
+	cIssued := <-b.cIssued
+	b.cIssued <- cIssued
+
+	var aggregatedDemand int64
+	perClientIssued := make(map[string]int64)
+	b.clientMap.Range(func(_, value interface{}) bool {
+		c := value.(Connection)
+		lockToken := <-c.demandWriteLock
+		c.demandWriteLock <- lockToken
+		aggregatedDemand += c.demand
+
+		issuedToken := <-c.issuedWriteLock
+		c.issuedWriteLock <- issuedToken
+		perClientIssued[c.id] = c.issued
+		return true
+	})
+
+	var measuredDelayUs int64
+	if b.delayTrackingRunning {
+		responseChan := make(chan float64)
+		b.queueingDelayChan <- DelayOperation{Response: responseChan}
+		measuredDelayUs = int64(<-responseChan)
+	}
+
+	return ServerStats{
+		TotalCredits:      b.cTotal,
+		IssuedCredits:     cIssued,
+		RegisteredClients: int(b.NumClients()),
+		MeasuredDelayUs:   measuredDelayUs,
+		AggregatedDemand:  aggregatedDemand,
+		PerClientIssued:   perClientIssued,
+	}
+}
@@ -0,0 +1,113 @@
+package breakwater
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+/*
+StatsHandler adapts a Breakwater instance's existing MetricsRecorder
+callbacks (OnAdmit, OnDrop, OnCreditUpdate) into google.golang.org/grpc/
+stats.Handler events, for teams whose observability wiring already plugs
+in via grpc.WithStatsHandler rather than WithMetricsRecorder. Attach the
+same value both ways:
+
+	sh := &breakwater.StatsHandler{}
+	bw := breakwater.New(params, breakwater.WithMetricsRecorder(sh))
+	conn, _ := grpc.Dial(addr, grpc.WithStatsHandler(sh), ...)
+
+StatsHandler runs no admission logic of its own: it is driven entirely
+by the calls UnaryInterceptorClient/StreamInterceptorClient already make
+to their MetricsRecorder at the points they've decided an outcome, so it
+cooperates with that existing accounting rather than duplicating it.
+Each MetricsRecorder callback is relayed as a synthetic *stats.End to
+Handler (if set), the same event type a real RPC completion produces, so
+anything already consuming a stats.Handler pipeline (tracing, logging,
+a custom sink) observes breakwater's decisions through that one
+integration point instead of a second bespoke one. Its own TagRPC/
+HandleRPC/TagConn/HandleConn methods are a pure pass-through to Handler;
+they exist only so StatsHandler itself satisfies stats.Handler and can
+be passed straight to grpc.WithStatsHandler.
+*/
+type StatsHandler struct {
+	// Handler, if set, receives every real RPCStats/ConnStats event
+	// passed through unchanged, plus a synthetic *stats.End for each
+	// OnAdmit/OnDrop/OnCreditUpdate call Breakwater makes. A nil Handler
+	// makes every method here a no-op.
+	Handler stats.Handler
+}
+
+func (h *StatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if h.Handler == nil {
+		return ctx
+	}
+	return h.Handler.TagRPC(ctx, info)
+}
+
+func (h *StatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	if h.Handler != nil {
+		h.Handler.HandleRPC(ctx, rs)
+	}
+}
+
+func (h *StatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	if h.Handler == nil {
+		return ctx
+	}
+	return h.Handler.TagConn(ctx, info)
+}
+
+func (h *StatsHandler) HandleConn(ctx context.Context, cs stats.ConnStats) {
+	if h.Handler != nil {
+		h.Handler.HandleConn(ctx, cs)
+	}
+}
+
+// OnAdmit implements MetricsRecorder: id and demand have nowhere to live
+// on *stats.End, so they're dropped here the same way CreditBalance from
+// OnCreditUpdate is -- this relays that an admission happened, not the
+// full detail behind it. Callers that need the detail should attach a
+// MetricsRecorder directly via WithMetricsRecorder instead.
+func (h *StatsHandler) OnAdmit(id string, creditBalance int64, demand int64) {
+	h.relay(nil)
+}
+
+// OnDrop implements MetricsRecorder, relaying reason as the synthetic
+// End's Error so a wrapped Handler's usual error-classification logic
+// (eg status.FromError) still works.
+func (h *StatsHandler) OnDrop(id string, reason string) {
+	h.relay(&dropReasonError{reason: reason})
+}
+
+// OnCreditUpdate implements MetricsRecorder. A credit update is purely
+// informational (see updateCreditsFromTrailer), not success or failure,
+// so it relays as a synthetic End with no error.
+func (h *StatsHandler) OnCreditUpdate(id string, creditBalance int64) {
+	h.relay(nil)
+}
+
+func (h *StatsHandler) relay(err error) {
+	if h.Handler == nil {
+		return
+	}
+	now := time.Now()
+	h.Handler.HandleRPC(context.Background(), &stats.End{
+		Client:    true,
+		BeginTime: now,
+		EndTime:   now,
+		Error:     err,
+	})
+}
+
+// dropReasonError carries a MetricsRecorder.OnDrop reason string as a
+// plain error, since OnDrop only gets the already-stringified reason
+// (see DropReason.String()), not the original *DropError.
+type dropReasonError struct {
+	reason string
+}
+
+func (e *dropReasonError) Error() string {
+	return "breakwater: dropped: " + e.reason
+}
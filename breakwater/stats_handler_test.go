@@ -0,0 +1,103 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/stats"
+)
+
+type recordingHandler struct {
+	ends []*stats.End
+}
+
+func (r *recordingHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *recordingHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	if end, ok := rs.(*stats.End); ok {
+		r.ends = append(r.ends, end)
+	}
+}
+
+func (r *recordingHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *recordingHandler) HandleConn(ctx context.Context, cs stats.ConnStats) {}
+
+func TestStatsHandlerRelaysOnAdmitAsSuccessfulEnd(t *testing.T) {
+	rec := &recordingHandler{}
+	sh := &StatsHandler{Handler: rec}
+
+	sh.OnAdmit("client-1", 4, 1)
+
+	if len(rec.ends) != 1 {
+		t.Fatalf("expected 1 relayed End, got %d", len(rec.ends))
+	}
+	if rec.ends[0].Error != nil {
+		t.Fatalf("expected no error on an admit, got %v", rec.ends[0].Error)
+	}
+}
+
+func TestStatsHandlerRelaysOnDropAsFailedEnd(t *testing.T) {
+	rec := &recordingHandler{}
+	sh := &StatsHandler{Handler: rec}
+
+	sh.OnDrop("client-1", QueueFull.String())
+
+	if len(rec.ends) != 1 {
+		t.Fatalf("expected 1 relayed End, got %d", len(rec.ends))
+	}
+	if rec.ends[0].Error == nil {
+		t.Fatal("expected OnDrop to relay a non-nil error")
+	}
+}
+
+func TestStatsHandlerRelaysOnCreditUpdate(t *testing.T) {
+	rec := &recordingHandler{}
+	sh := &StatsHandler{Handler: rec}
+
+	sh.OnCreditUpdate("client-1", 10)
+
+	if len(rec.ends) != 1 {
+		t.Fatalf("expected 1 relayed End, got %d", len(rec.ends))
+	}
+}
+
+func TestStatsHandlerIsANoopWithoutAHandler(t *testing.T) {
+	sh := &StatsHandler{}
+
+	// None of these should panic with a nil Handler.
+	sh.OnAdmit("client-1", 4, 1)
+	sh.OnDrop("client-1", QueueFull.String())
+	sh.OnCreditUpdate("client-1", 10)
+	sh.HandleRPC(context.Background(), &stats.End{})
+	sh.HandleConn(context.Background(), &stats.ConnEnd{})
+	if got := sh.TagRPC(context.Background(), &stats.RPCTagInfo{}); got == nil {
+		t.Fatal("expected TagRPC to return a non-nil context")
+	}
+	if got := sh.TagConn(context.Background(), &stats.ConnTagInfo{}); got == nil {
+		t.Fatal("expected TagConn to return a non-nil context")
+	}
+}
+
+func TestStatsHandlerPassesThroughRealRPCStats(t *testing.T) {
+	rec := &recordingHandler{}
+	sh := &StatsHandler{Handler: rec}
+
+	sh.HandleRPC(context.Background(), &stats.End{})
+
+	if len(rec.ends) != 1 {
+		t.Fatalf("expected the real End to pass through, got %d relayed", len(rec.ends))
+	}
+}
+
+// StatsHandler must satisfy stats.Handler so it can be passed directly
+// to grpc.WithStatsHandler, and MetricsRecorder so it can be passed
+// directly to WithMetricsRecorder.
+var (
+	_ stats.Handler   = &StatsHandler{}
+	_ MetricsRecorder = &StatsHandler{}
+)
@@ -0,0 +1,181 @@
+package breakwater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+/*
+StreamInterceptorClient is the streaming counterpart to
+UnaryInterceptorClient. It acquires one credit before the stream is
+opened, propagates the same "demand"/"id" metadata, and returns/updates
+that credit based on the trailer once the stream closes. If streamer
+itself fails during setup, the acquired credit is returned the same way
+UnaryInterceptorClient returns it on invoker failure.
+*/
+func (b *Breakwater) StreamInterceptorClient(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+	if !b.enabled.Load() || priorityFromContext(ctx) == High {
+		return b.bypassStream(ctx, desc, cc, method, streamer, opts...)
+	}
+
+	timeStart := b.clock.Now()
+
+	poolKey := b.poolKey(ctx, method)
+	cost := costFromContext(ctx)
+	ctx = withWaitForReady(ctx, waitForReadyFromOpts(opts))
+
+	demand, err := b.acquireCredit(ctx, timeStart, poolKey)
+	if err != nil {
+		return nil, err
+	}
+	b.applyAdmitJitter()
+	if b.shouldReportDemand(b.clock.Now()) {
+		ctx = metadata.AppendToOutgoingContext(ctx, "demand", b.demandString(b.smoothedDemand(demand)), "id", b.idStr, "credit-mode", b.creditUpdateMode.String())
+	} else {
+		ctx = metadata.AppendToOutgoingContext(ctx, "id", b.idStr, "credit-mode", b.creditUpdateMode.String())
+	}
+
+	// WithMaxInFlight's cap is orthogonal to credits: it can still reject
+	// a request that already holds one, in which case that credit is
+	// wasted unless we hand it straight back.
+	if !b.acquireInFlightSlot() {
+		b.ReleaseCredit(poolKey, cost)
+		atomic.AddUint64(&b.droppedCount, 1)
+		if b.metrics != nil {
+			b.metrics.OnDrop(b.idStr, InFlightLimit.String())
+		}
+		waitedUs := elapsedMicros(b.clock.Now(), timeStart)
+		b.fireOnDrop(InFlightLimit, waitedUs)
+		return nil, &DropError{
+			Reason:   InFlightLimit,
+			Code:     b.dropCode(InFlightLimit, codes.ResourceExhausted),
+			Message:  fmt.Sprintf("Client id %s max in-flight limit reached", b.idStr),
+			ClientID: b.idStr,
+			WaitedUs: waitedUs,
+		}
+	}
+
+	// Mirror UnaryInterceptorClient's panic recovery: a panicking streamer
+	// must not strand the credit acquireCredit already handed out.
+	defer func() {
+		if r := recover(); r != nil {
+			b.logWarn("streamer panicked, restoring credit", slog.Any("panic", r))
+			b.restoreCreditAfterPanic(poolKey, cost)
+			b.releaseInFlightSlot()
+			panic(r)
+		}
+	}()
+
+	// Admitted at this point, mirroring UnaryInterceptorClient: stash how
+	// long it waited and the state it was admitted under for downstream
+	// code to read back via AdmitInfoFromContext.
+	ctx = withAdmitInfo(ctx, AdmitInfo{
+		WaitedUs:      elapsedMicros(b.clock.Now(), timeStart),
+		CreditBalance: b.PeekCredit(poolKey),
+		Demand:        int(demand),
+	})
+
+	clientStream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		// Setup failed before the stream was established, mirror the
+		// creditsOnFail behavior used by UnaryInterceptorClient.
+		b.returnCreditOnFail(poolKey, cost)
+		b.releaseInFlightSlot()
+		return nil, err
+	}
+
+	// Held for the life of the stream, not just its setup: Close needs to
+	// wait for streams still exchanging messages, not only the unary-like
+	// moment streamer returns. settleCredits releases it (and the
+	// in-flight slot, if one was taken) once the stream closes.
+	b.inFlight.Add(1)
+
+	return &creditTrackingClientStream{
+		ClientStream:   clientStream,
+		bw:             b,
+		poolKey:        poolKey,
+		creditInterval: b.streamCreditInterval,
+	}, nil
+}
+
+/*
+bypassStream is the streaming counterpart to bypassUnary: it skips
+credit acquisition entirely and hands back streamer's stream directly,
+unwrapped, since there is no credit balance for
+creditTrackingClientStream to settle against a trailer.
+*/
+func (b *Breakwater) bypassStream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	atomic.AddUint64(&b.bypassedCount, 1)
+	ctx = metadata.AppendToOutgoingContext(ctx, "id", b.idStr)
+	// A bypassed stream never goes through creditTrackingClientStream, so
+	// there is nowhere to release an inFlight count paired here; Close
+	// simply won't wait on bypassed streams, matching that they never
+	// participate in admission control either.
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+/*
+creditTrackingClientStream wraps a grpc.ClientStream to debit credits as
+messages are sent and to settle the credit balance against the server's
+trailer once the stream closes.
+*/
+type creditTrackingClientStream struct {
+	grpc.ClientStream
+	bw *Breakwater
+	// poolKey is the key this stream's credit was acquired under, as
+	// Breakwater.poolKey resolved it for method: a credit class, the
+	// method, its service or "" depending on WithCreditClasses and
+	// WithPoolScope, plus a partition suffix when WithCreditPartition is set.
+	poolKey        string
+	creditInterval int64 // debit an extra credit every N sent messages; 0 disables
+	sentCount      int64
+	settled        bool
+}
+
+func (s *creditTrackingClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil {
+		s.settleCredits()
+		return err
+	}
+
+	s.sentCount++
+	if s.creditInterval > 0 && s.sentCount%s.creditInterval == 0 {
+		s.bw.debitCredit(s.poolKey)
+	}
+	return nil
+}
+
+func (s *creditTrackingClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		// io.EOF signals a clean end of stream; any other error also means
+		// there is nothing left to wait for. Either way, the trailer is now
+		// readable and the credit balance should be settled.
+		s.settleCredits()
+		if err == io.EOF {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *creditTrackingClientStream) settleCredits() {
+	if s.settled {
+		return
+	}
+	s.settled = true
+	trailer := s.ClientStream.Trailer()
+	s.bw.updateCreditsFromTrailer(trailer, s.poolKey)
+	s.bw.noteServerLoad(trailer)
+	s.bw.releaseInFlightSlot()
+	s.bw.inFlight.Done()
+}
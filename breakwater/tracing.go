@@ -0,0 +1,55 @@
+package breakwater
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies this package's spans in exported trace data.
+const tracerName = "github.com/pennsail/breakwater-grpc/breakwater"
+
+// tracer returns b's configured tracer, falling back to one resolved from
+// the global TracerProvider (otel.GetTracerProvider) when WithTracerProvider
+// was never called.
+func (b *Breakwater) tracer() trace.Tracer {
+	tp := b.tracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+/*
+startQueueWaitSpan opens a "breakwater.queue_wait" child span nested under
+ctx's existing span, covering the credit-acquisition loop in
+acquireCredit. The caller must end the returned span, via
+endQueueWaitSpanOnAdmit or endQueueWaitSpanOnDrop, once the loop exits.
+*/
+func (b *Breakwater) startQueueWaitSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return b.tracer().Start(ctx, "breakwater.queue_wait",
+		trace.WithAttributes(attribute.String("breakwater.method", method)))
+}
+
+// endQueueWaitSpanOnAdmit records the outcome attributes for a successful
+// credit acquisition and ends span.
+func endQueueWaitSpanOnAdmit(span trace.Span, demand int, creditBalance int64, queueWaitUs int64) {
+	span.SetAttributes(
+		attribute.Int("breakwater.demand", demand),
+		attribute.Int64("breakwater.credit_balance_on_admit", creditBalance),
+		attribute.Int64("breakwater.queue_wait_us", queueWaitUs),
+	)
+	span.End()
+}
+
+// endQueueWaitSpanOnDrop annotates span with a drop event carrying reason,
+// then ends it.
+func endQueueWaitSpanOnDrop(span trace.Span, reason DropReason, queueWaitUs int64) {
+	span.AddEvent("breakwater.drop", trace.WithAttributes(
+		attribute.String("breakwater.drop_reason", reason.String()),
+		attribute.Int64("breakwater.queue_wait_us", queueWaitUs),
+	))
+	span.End()
+}
@@ -0,0 +1,72 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAcquireCreditRecordsQueueWaitSpanOnAdmit(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	bw := New(BWParametersDefault, WithTracerProvider(tp))
+
+	if _, err := bw.acquireCredit(context.Background(), time.Now(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if got := spans[0].Name; got != "breakwater.queue_wait" {
+		t.Fatalf("expected span name breakwater.queue_wait, got %q", got)
+	}
+
+	var sawDemand, sawCreditBalance, sawQueueWait bool
+	for _, attr := range spans[0].Attributes {
+		switch string(attr.Key) {
+		case "breakwater.demand":
+			sawDemand = true
+		case "breakwater.credit_balance_on_admit":
+			sawCreditBalance = true
+		case "breakwater.queue_wait_us":
+			sawQueueWait = true
+		}
+	}
+	if !sawDemand || !sawCreditBalance || !sawQueueWait {
+		t.Fatalf("expected demand, credit_balance_on_admit and queue_wait_us attributes, got %v", spans[0].Attributes)
+	}
+}
+
+func TestAcquireCreditRecordsDropEventOnQueueFull(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	bw := New(BWParametersDefault,
+		WithTracerProvider(tp),
+		WithClientQueueLength(true),
+	)
+	bw.queueCapacity.Store(0)
+	bw.pendingOutgoing = make(chan int64) // capacity 0: queueRequest always fails
+
+	if _, err := bw.acquireCredit(context.Background(), time.Now(), ""); err == nil {
+		t.Fatal("expected the request to be dropped for a full queue")
+	}
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if len(spans[0].Events) != 1 || spans[0].Events[0].Name != "breakwater.drop" {
+		t.Fatalf("expected a single breakwater.drop event, got %v", spans[0].Events)
+	}
+}
@@ -1,7 +1,9 @@
 package breakwater
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"math"
 	"time"
 
@@ -13,7 +15,22 @@ var (
 	errMissingMetadata = status.Errorf(codes.InvalidArgument, "missing metadata")
 )
 
-// logger is to mock a sophisticated logging system. To simplify the example, we just print out the content.
+// noopHandler is a slog.Handler that is never enabled, so building a
+// Record (and its Attrs) is skipped entirely on the hot path. This is the
+// default logger for a Breakwater instance that hasn't been given one via
+// WithLogger.
+type noopHandler struct{}
+
+func (noopHandler) Enabled(context.Context, slog.Level) bool   { return false }
+func (noopHandler) Handle(context.Context, slog.Record) error  { return nil }
+func (h noopHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h noopHandler) WithGroup(name string) slog.Handler       { return h }
+
+var noopLogger = slog.New(noopHandler{})
+
+// logger is kept around, deprecated, for the handful of call sites that run
+// before a Breakwater instance (and therefore its *slog.Logger) exists.
+// Prefer (*Breakwater).logDebug for anything with access to a receiver.
 func logger(format string, a ...interface{}) {
 	if debug {
 		// print to stdout with timestamp
@@ -22,6 +39,21 @@ func logger(format string, a ...interface{}) {
 	}
 }
 
+// logDebug emits a structured debug-level record through the instance's
+// configured logger. Callers should pass slog.Attr-producing helpers
+// (slog.String, slog.Int64, ...) as args; when the logger is at a level
+// above Debug (including the default no-op logger), slog.Handler.Enabled
+// short-circuits before any Attr is built, so this is safe on the hot path.
+func (b *Breakwater) logDebug(msg string, args ...any) {
+	b.slogger.Debug(msg, args...)
+}
+
+// logWarn emits a structured warn-level record through the instance's
+// configured logger.
+func (b *Breakwater) logWarn(msg string, args ...any) {
+	b.slogger.Warn(msg, args...)
+}
+
 func recordCredits(format string, a ...interface{}) {
 	if trackCredits {
 		timestamp := time.Now().Format("2006-01-02T15:04:05.999999999-07:00")
@@ -60,6 +92,7 @@ type BWParameters struct {
 	UseClientQueueLength    bool
 	RTT_MICROSECOND         int64
 	TrackCredits            bool
+	QueueCapacity           int64 // capacity of the client outgoing queue; must be > 0
 }
 
 /*
@@ -82,4 +115,5 @@ var BWParametersDefault BWParameters = BWParameters{
 	UseClientQueueLength:    false,
 	RTT_MICROSECOND:         5000,
 	TrackCredits:            false,
+	QueueCapacity:           MAX_Q_LENGTH,
 }
@@ -0,0 +1,44 @@
+package breakwater
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+type waitForReadyKey struct{}
+
+/*
+waitForReadyFromOpts reports whether opts carries a wait-for-ready call
+option -- grpc.WaitForReady(true), or the deprecated grpc.FailFast(false)
+-- both of which construct the same exported grpc.FailFastCallOption.
+There is no public accessor for a *callInfo's resolved failFast field,
+so this scans opts directly for the last FailFastCallOption instead,
+mirroring how invoker/streamer itself would apply it.
+*/
+func waitForReadyFromOpts(opts []grpc.CallOption) bool {
+	waitForReady := false
+	for _, opt := range opts {
+		if ff, ok := opt.(grpc.FailFastCallOption); ok {
+			waitForReady = !ff.FailFast
+		}
+	}
+	return waitForReady
+}
+
+// withWaitForReady marks ctx, when waitForReady is true, so
+// acquireCreditLoop treats this call the way grpc.WaitForReady(true)
+// already treats connection readiness: the caller opted into waiting
+// rather than failing fast, so admission control should block for a
+// credit instead of dropping for queue length or client-side expiration.
+func withWaitForReady(ctx context.Context, waitForReady bool) context.Context {
+	if !waitForReady {
+		return ctx
+	}
+	return context.WithValue(ctx, waitForReadyKey{}, true)
+}
+
+func waitForReadyFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(waitForReadyKey{}).(bool)
+	return v
+}
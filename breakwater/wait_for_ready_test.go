@@ -0,0 +1,114 @@
+package breakwater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestWaitForReadyFromOptsDetectsCallOption(t *testing.T) {
+	if waitForReadyFromOpts(nil) {
+		t.Fatal("expected no call options to mean wait-for-ready is off")
+	}
+	if !waitForReadyFromOpts([]grpc.CallOption{grpc.WaitForReady(true)}) {
+		t.Fatal("expected grpc.WaitForReady(true) to be detected")
+	}
+	if waitForReadyFromOpts([]grpc.CallOption{grpc.WaitForReady(false)}) {
+		t.Fatal("expected grpc.WaitForReady(false) to report false")
+	}
+}
+
+func noopInvoker(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	return nil
+}
+
+func TestWaitForReadyBypassesQueueFullDrop(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: 1, InitialCredits: 1}, WithClientQueueLength(true))
+	if !bw.queueRequest("") {
+		t.Fatal("expected to fill the single queue slot")
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		bw.dequeueRequest("")
+	}()
+
+	err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, noopInvoker, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatalf("expected the wait-for-ready call to be admitted once a slot freed up, got %v", err)
+	}
+}
+
+func TestWithoutWaitForReadyStillDropsOnQueueFull(t *testing.T) {
+	bw := New(BWParameters{QueueCapacity: 1, InitialCredits: 1}, WithClientQueueLength(true))
+	if !bw.queueRequest("") {
+		t.Fatal("expected to fill the single queue slot")
+	}
+
+	err := bw.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, noopInvoker)
+	var de *DropError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DropError without wait-for-ready, got %v", err)
+	}
+	if de.Reason != QueueFull {
+		t.Fatalf("expected QueueFull, got %v", de.Reason)
+	}
+}
+
+func TestWaitForReadyBypassesClientExpirationDrop(t *testing.T) {
+	bw := New(BWParametersDefault, WithClientTimeExpiration(true))
+	bw.clientExpiration.Store(1000) // 1ms budget
+	bw.SetCredit("", 0)
+
+	result := make(chan error, 1)
+	go func() {
+		ctx := withWaitForReady(context.Background(), true)
+		_, err := bw.acquireCreditLoop(ctx, bw.clock.Now(), "")
+		result <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond) // well past clientExpiration
+	bw.SetCredit("", 1)
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected the wait-for-ready waiter to be admitted instead of expiring, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiter to be admitted")
+	}
+}
+
+func TestWithoutWaitForReadyStillExpires(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	bw := New(BWParametersDefault, WithClock(clock), WithClientTimeExpiration(true))
+	bw.clientExpiration.Store(1000) // 1ms budget
+	bw.SetCredit("", 0)
+
+	timeStart := clock.Now()
+	result := make(chan error, 1)
+	go func() {
+		_, err := bw.acquireCreditLoop(context.Background(), timeStart, "")
+		result <- err
+	}()
+
+	clock.Advance(5 * time.Millisecond)
+	bw.unblockNoCreditBlock("")
+
+	select {
+	case err := <-result:
+		var de *DropError
+		if !errors.As(err, &de) {
+			t.Fatalf("expected a *DropError, got %v", err)
+		}
+		if de.Reason != Expired {
+			t.Fatalf("expected Expired, got %v", de.Reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquireCreditLoop did not return in time")
+	}
+}
@@ -0,0 +1,108 @@
+package breakwater
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// waitLatencyBuckets is sized so that bucket waitLatencyBuckets-1 reaches
+// up to 2^39 microseconds (~6 days), far beyond any realistic queue
+// wait, so waitLatencyBucketFor's clamp in practice never triggers.
+const waitLatencyBuckets = 40
+
+/*
+waitLatencyHistogram is a lock-free, log2-bucketed histogram of queue
+wait times: record costs one atomic.Uint64.Add on the hot
+path, with no allocation and no lock, at the expense of percentiles
+that are approximate (accurate to the width of the bucket they fall in,
+which doubles every bucket) rather than exact. That tradeoff is the
+right one here: Stats() callers want a cheap, continuously-updated p50/
+p95/p99 for SLO dashboards, not an exact order statistic.
+*/
+type waitLatencyHistogram struct {
+	buckets [waitLatencyBuckets]atomic.Uint64
+}
+
+// waitLatencyBucketFor maps a duration in microseconds to its bucket
+// index: bucket i (i >= 1) holds samples in [2^(i-1), 2^i) microseconds,
+// and bucket 0 holds only 0us samples.
+func waitLatencyBucketFor(us int64) int {
+	if us < 0 {
+		us = 0
+	}
+	i := bits.Len64(uint64(us))
+	if i >= waitLatencyBuckets {
+		i = waitLatencyBuckets - 1
+	}
+	return i
+}
+
+func (h *waitLatencyHistogram) record(us int64) {
+	h.buckets[waitLatencyBucketFor(us)].Add(1)
+}
+
+// reset zeroes every bucket. Not atomic as a whole -- a sample recorded
+// mid-reset can still land in a bucket that's about to be zeroed or was
+// just zeroed -- which is an acceptable approximation for a
+// per-interval reporting reset, the same tradeoff record's approximate
+// buckets already make.
+func (h *waitLatencyHistogram) reset() {
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+}
+
+// percentile returns the upper bound (in microseconds) of the bucket
+// containing the p-th percentile sample, p in [0, 1]. Returns 0 if no
+// samples have been recorded.
+func (h *waitLatencyHistogram) percentile(p float64) int64 {
+	var total uint64
+	counts := make([]uint64, waitLatencyBuckets)
+	for i := range h.buckets {
+		counts[i] = h.buckets[i].Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0
+	}
+
+	target := uint64(p * float64(total))
+	var cumulative uint64
+	for i, c := range counts {
+		cumulative += c
+		if cumulative > target {
+			if i == 0 {
+				return 1
+			}
+			return int64(1) << uint(i)
+		}
+	}
+	return int64(1) << uint(waitLatencyBuckets-1)
+}
+
+// WaitLatency is a point-in-time snapshot of queue wait-time
+// percentiles, in microseconds, for admitted requests only (drops
+// aren't "waits" that resolved into anything to report a latency for).
+type WaitLatency struct {
+	P50 int64
+	P95 int64
+	P99 int64
+}
+
+// WaitLatency returns the current p50/p95/p99 queue wait time, in
+// microseconds, computed from every admitted request's wait recorded
+// since construction or the last ResetWaitLatency call.
+func (b *Breakwater) WaitLatency() WaitLatency {
+	return WaitLatency{
+		P50: b.waitLatency.percentile(0.50),
+		P95: b.waitLatency.percentile(0.95),
+		P99: b.waitLatency.percentile(0.99),
+	}
+}
+
+// ResetWaitLatency clears every sample WaitLatency's histogram has
+// accumulated, so a caller reporting per-interval percentiles (rather
+// than all-time ones) can call this at the start of each interval.
+func (b *Breakwater) ResetWaitLatency() {
+	b.waitLatency.reset()
+}
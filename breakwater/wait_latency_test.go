@@ -0,0 +1,60 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWaitLatencyPercentilesReflectRecordedSamples(t *testing.T) {
+	var h waitLatencyHistogram
+	for i := 0; i < 99; i++ {
+		h.record(100)
+	}
+	h.record(100_000)
+
+	if p50 := h.percentile(0.50); p50 != 128 {
+		t.Fatalf("expected p50 to fall in the bucket covering 100us (128), got %d", p50)
+	}
+	if p99 := h.percentile(0.99); p99 <= 128 {
+		t.Fatalf("expected p99 to be pulled up by the outlier sample, got %d", p99)
+	}
+}
+
+func TestWaitLatencyPercentileZeroWithNoSamples(t *testing.T) {
+	var h waitLatencyHistogram
+	if got := h.percentile(0.50); got != 0 {
+		t.Fatalf("expected 0 with no samples recorded, got %d", got)
+	}
+}
+
+func TestResetWaitLatencyClearsAllSamples(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.waitLatency.record(500)
+	if bw.WaitLatency().P50 == 0 {
+		t.Fatal("expected a nonzero p50 after recording a sample")
+	}
+
+	bw.ResetWaitLatency()
+	if got := bw.WaitLatency(); got.P50 != 0 || got.P95 != 0 || got.P99 != 0 {
+		t.Fatalf("expected all percentiles to be 0 after reset, got %+v", got)
+	}
+}
+
+func TestAcquireCreditRecordsWaitLatencyOnAdmit(t *testing.T) {
+	bw := New(BWParametersDefault)
+	bw.SetCredit("", 10)
+
+	for i := 0; i < 5; i++ {
+		if _, err := bw.acquireCredit(context.Background(), bw.clock.Now(), ""); err != nil {
+			t.Fatalf("unexpected drop: %v", err)
+		}
+	}
+
+	var total uint64
+	for i := range bw.waitLatency.buckets {
+		total += bw.waitLatency.buckets[i].Load()
+	}
+	if total != 5 {
+		t.Fatalf("expected 5 recorded samples, one per admitted request, got %d", total)
+	}
+}
@@ -0,0 +1,17 @@
+package breakwater
+
+import "testing"
+
+func TestWithIDOverridesGeneratedID(t *testing.T) {
+	bw := New(BWParametersDefault, WithID("checkout-service"))
+	if bw.idStr != "checkout-service" {
+		t.Fatalf("expected idStr %q, got %q", "checkout-service", bw.idStr)
+	}
+}
+
+func TestWithoutWithIDKeepsGeneratedID(t *testing.T) {
+	bw := New(BWParametersDefault)
+	if bw.idStr != bw.id.String() {
+		t.Fatalf("expected idStr to default to the generated uuid, got %q vs %q", bw.idStr, bw.id.String())
+	}
+}
@@ -0,0 +1,55 @@
+package breakwater
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestZeroCreditBackoffGrowsThenCapsAtExpiration(t *testing.T) {
+	bw := New(BWParameters{ClientExpiration: 1_000_000}) // 1s budget, plenty of headroom
+	timeStart := bw.clock.Now()
+
+	first := bw.zeroCreditBackoff(1, timeStart)
+	second := bw.zeroCreditBackoff(2, timeStart)
+	if first <= 0 || second <= 0 {
+		t.Fatalf("expected positive backoffs, got first=%v second=%v", first, second)
+	}
+	// Jitter makes these noisy, but the doubling base should still put the
+	// second streak's ceiling well above the first's.
+	if second < first {
+		t.Fatalf("expected backoff to grow with streak, got first=%v second=%v", first, second)
+	}
+
+	// A streak long after the budget is nearly exhausted must be capped by
+	// whatever time remains, not by the unclamped exponential.
+	late := bw.zeroCreditBackoff(20, timeStart.Add(-999*time.Millisecond))
+	if late > time.Millisecond {
+		t.Fatalf("expected backoff capped near the expired budget, got %v", late)
+	}
+}
+
+func TestAcquireCreditLoopRecordsBackoffOnSustainedZeroCredit(t *testing.T) {
+	bw := New(BWParameters{ClientExpiration: 1_000_000, QueueCapacity: MAX_Q_LENGTH})
+	bw.SetCredit("", 0)
+
+	// Re-signal noCreditBlocker immediately so the loop keeps retrying
+	// TryAcquireCredit against a balance that never becomes positive,
+	// exercising the backoff path instead of parking indefinitely.
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(time.Millisecond)
+			bw.unblockNoCreditBlock("")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := bw.acquireCreditLoop(ctx, bw.clock.Now(), ""); err == nil {
+		t.Fatal("expected the request to eventually be dropped, never got a credit")
+	}
+
+	if bw.Stats().BackoffMicros == 0 {
+		t.Fatal("expected BackoffMicros to be nonzero after repeated zero-credit misses")
+	}
+}
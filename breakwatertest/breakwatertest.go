@@ -0,0 +1,119 @@
+/*
+Package breakwatertest provides Fake, a lightweight stand-in for
+*breakwater.Breakwater for unit-testing handlers and middleware wrapped
+by it, without spinning up the real credit/queue machinery. It shares
+breakwater.DropReason and breakwater.DropError with the main package, so
+a handler that branches on them with errors.As works identically against
+Fake and the real thing.
+*/
+package breakwatertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pennsail/breakwater-grpc/breakwater"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Decision records one admit/drop decision Fake made, for assertions in
+// a handler's unit tests.
+type Decision struct {
+	Method   string
+	CallNum  int // 1-indexed, shared across UnaryInterceptorClient and StreamInterceptorClient calls on this Fake
+	Admitted bool
+	Reason   breakwater.DropReason // only meaningful when !Admitted
+}
+
+/*
+Fake implements the same interceptor signatures as *breakwater.Breakwater
+(UnaryInterceptorClient, StreamInterceptorClient), so it drops straight
+into grpc.WithChainUnaryInterceptor/WithChainStreamInterceptor in place
+of the real thing. Every call is recorded to Decisions; Decide controls
+which calls are admitted.
+*/
+type Fake struct {
+	mu        sync.Mutex
+	calls     int
+	Decisions []Decision
+
+	// Decide is consulted once per call, unary or streaming, with a
+	// 1-indexed counter shared across both, and controls whether that
+	// call is admitted. A nil Decide (the default) admits every call.
+	Decide func(method string, callNum int) (admit bool, reason breakwater.DropReason)
+
+	// Code is the gRPC status code attached to the DropError built for a
+	// declined call. Defaults to codes.ResourceExhausted, matching the
+	// code the real Breakwater attaches to most of its own drops.
+	Code codes.Code
+}
+
+// New returns a Fake that admits every call until Decide is set.
+func New() *Fake {
+	return &Fake{Code: codes.ResourceExhausted}
+}
+
+// DropAt returns a Decide function that declines exactly the n-th call
+// (1-indexed) across a Fake's lifetime with reason, admitting every
+// other one -- the common case of asserting a handler correctly surfaces
+// a single rejected request, eg "drop the 3rd request".
+func DropAt(n int, reason breakwater.DropReason) func(method string, callNum int) (bool, breakwater.DropReason) {
+	return func(_ string, callNum int) (bool, breakwater.DropReason) {
+		return callNum != n, reason
+	}
+}
+
+func (f *Fake) decide(method string) (admit bool, reason breakwater.DropReason, callNum int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	callNum = f.calls
+
+	admit, reason = true, breakwater.DropReason(0)
+	if f.Decide != nil {
+		admit, reason = f.Decide(method, callNum)
+	}
+	f.Decisions = append(f.Decisions, Decision{Method: method, CallNum: callNum, Admitted: admit, Reason: reason})
+	return admit, reason, callNum
+}
+
+func (f *Fake) dropError(method string, callNum int, reason breakwater.DropReason) error {
+	return &breakwater.DropError{
+		Reason:  reason,
+		Code:    f.Code,
+		Message: fmt.Sprintf("breakwatertest: declined call #%d to %s (%s)", callNum, method, reason),
+	}
+}
+
+// UnaryInterceptorClient mirrors breakwater.Breakwater.UnaryInterceptorClient's
+// signature. Declined calls never reach invoker.
+func (f *Fake) UnaryInterceptorClient(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	admit, reason, callNum := f.decide(method)
+	if !admit {
+		return f.dropError(method, callNum, reason)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// StreamInterceptorClient mirrors breakwater.Breakwater.StreamInterceptorClient's
+// signature. Declined calls never reach streamer.
+func (f *Fake) StreamInterceptorClient(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	admit, reason, callNum := f.decide(method)
+	if !admit {
+		return nil, f.dropError(method, callNum, reason)
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+// Snapshot returns a copy of every decision recorded so far, safe to
+// read concurrently with further calls through Fake.
+func (f *Fake) Snapshot() []Decision {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Decision, len(f.Decisions))
+	copy(out, f.Decisions)
+	return out
+}
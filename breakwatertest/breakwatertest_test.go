@@ -0,0 +1,83 @@
+package breakwatertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pennsail/breakwater-grpc/breakwater"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func noopInvoker(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	return nil
+}
+
+func TestFakeAdmitsEverythingByDefault(t *testing.T) {
+	f := New()
+	for i := 0; i < 3; i++ {
+		if err := f.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, noopInvoker); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(f.Snapshot()) != 3 {
+		t.Fatalf("expected 3 recorded decisions, got %d", len(f.Snapshot()))
+	}
+}
+
+func TestFakeDropAtDeclinesOnlyTheRequestedCall(t *testing.T) {
+	f := New()
+	f.Decide = DropAt(3, breakwater.QueueFull)
+
+	var lastErr error
+	for i := 0; i < 5; i++ {
+		lastErr = f.UnaryInterceptorClient(context.Background(), "/svc/Method", nil, nil, nil, noopInvoker)
+		if i == 2 {
+			var de *breakwater.DropError
+			if !errors.As(lastErr, &de) {
+				t.Fatalf("expected call 3 to fail with a *breakwater.DropError, got %v", lastErr)
+			}
+			if de.Reason != breakwater.QueueFull {
+				t.Fatalf("expected QueueFull, got %v", de.Reason)
+			}
+			if de.Code != codes.ResourceExhausted {
+				t.Fatalf("expected codes.ResourceExhausted, got %v", de.Code)
+			}
+			continue
+		}
+		if lastErr != nil {
+			t.Fatalf("expected call %d to be admitted, got %v", i+1, lastErr)
+		}
+	}
+
+	decisions := f.Snapshot()
+	if len(decisions) != 5 {
+		t.Fatalf("expected 5 recorded decisions, got %d", len(decisions))
+	}
+	for i, d := range decisions {
+		wantAdmitted := i != 2
+		if d.Admitted != wantAdmitted {
+			t.Fatalf("decision %d: expected Admitted=%v, got %v", i, wantAdmitted, d.Admitted)
+		}
+	}
+}
+
+func TestFakeStreamInterceptorClientDeclinesWithoutCallingStreamer(t *testing.T) {
+	f := New()
+	f.Decide = DropAt(1, breakwater.InFlightLimit)
+
+	called := false
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		called = true
+		return nil, nil
+	}
+
+	_, err := f.StreamInterceptorClient(context.Background(), &grpc.StreamDesc{}, nil, "/svc/Method", streamer)
+	if err == nil {
+		t.Fatal("expected the declined call to return an error")
+	}
+	if called {
+		t.Fatal("expected streamer not to be called for a declined stream")
+	}
+}
@@ -0,0 +1,63 @@
+// Package bwprom provides a Prometheus-backed breakwater.MetricsRecorder.
+// It is kept as a separate module so that depending on the main
+// breakwater-grpc package never pulls in github.com/prometheus/client_golang;
+// only callers that import this package do.
+package bwprom
+
+import (
+	"github.com/pennsail/breakwater-grpc/breakwater"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recorder implements breakwater.MetricsRecorder on top of Prometheus
+// counters and gauges, all labeled by the breakwater instance id.
+type recorder struct {
+	admitted *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+	credits  *prometheus.GaugeVec
+	demand   *prometheus.GaugeVec
+}
+
+// WithPrometheus registers breakwater's counters and gauges with registerer
+// and returns a breakwater.Option that wires them up. Pass it to
+// breakwater.New alongside any other options:
+//
+//	bw := breakwater.New(breakwater.BWParametersDefault, bwprom.WithPrometheus(reg))
+func WithPrometheus(registerer prometheus.Registerer) breakwater.Option {
+	r := &recorder{
+		admitted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "breakwater_client_requests_admitted_total",
+			Help: "Total number of requests admitted (a credit was acquired) by a breakwater client instance.",
+		}, []string{"id"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "breakwater_client_requests_dropped_total",
+			Help: "Total number of requests dropped by a breakwater client instance, labeled by reason.",
+		}, []string{"id", "reason"}),
+		credits: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "breakwater_client_credit_balance",
+			Help: "Current outgoing credit balance of a breakwater client instance.",
+		}, []string{"id"}),
+		demand: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "breakwater_client_demand",
+			Help: "Current outgoing queue length (demand) of a breakwater client instance.",
+		}, []string{"id"}),
+	}
+
+	registerer.MustRegister(r.admitted, r.dropped, r.credits, r.demand)
+
+	return breakwater.WithMetricsRecorder(r)
+}
+
+func (r *recorder) OnAdmit(id string, creditBalance int64, demand int64) {
+	r.admitted.WithLabelValues(id).Inc()
+	r.credits.WithLabelValues(id).Set(float64(creditBalance))
+	r.demand.WithLabelValues(id).Set(float64(demand))
+}
+
+func (r *recorder) OnDrop(id string, reason string) {
+	r.dropped.WithLabelValues(id, reason).Inc()
+}
+
+func (r *recorder) OnCreditUpdate(id string, creditBalance int64) {
+	r.credits.WithLabelValues(id).Set(float64(creditBalance))
+}
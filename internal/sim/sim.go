@@ -0,0 +1,316 @@
+/*
+Package sim drives breakwater's real client and server interceptors
+against each other in-process, without a real gRPC connection, so the AQM
+control loop can be exercised under a scripted arrival process and
+regression-tested for stability.
+
+It deliberately reuses (*breakwater.Breakwater).UnaryInterceptor and
+UnaryInterceptorClient rather than reimplementing admission or credit
+logic: FakeServer attaches a minimal grpc.ServerTransportStream to the
+context so that grpc.SetHeader works with no listener, and Client bridges
+outgoing metadata on the caller's context to incoming metadata on the
+callee's, mirroring what a real transport does.
+*/
+package sim
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pennsail/breakwater-grpc/breakwater"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeServerTransportStream is the minimal grpc.ServerTransportStream
+// implementation needed for grpc.SetHeader/SendHeader/SetTrailer to
+// succeed without a real network connection. It just captures whatever
+// the interceptor sets, for Client to read back.
+type fakeServerTransportStream struct {
+	method string
+
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *fakeServerTransportStream) Method() string { return s.method }
+
+func (s *fakeServerTransportStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *fakeServerTransportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *fakeServerTransportStream) SetTrailer(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func (s *fakeServerTransportStream) resultHeader() metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.header
+}
+
+// FakeServer wraps a server-side Breakwater and a configurable per-request
+// service time, standing in for a real listener.
+type FakeServer struct {
+	BW *breakwater.Breakwater
+
+	// ServiceTime returns how long handle should take to "process" each
+	// request. Called once per request; vary its return value to emulate
+	// overload.
+	ServiceTime func() time.Duration
+}
+
+// NewFakeServer builds a FakeServer around bw, defaulting ServiceTime to a
+// fixed 0, i.e. an instantly-answering backend.
+func NewFakeServer(bw *breakwater.Breakwater) *FakeServer {
+	return &FakeServer{BW: bw, ServiceTime: func() time.Duration { return 0 }}
+}
+
+func (s *FakeServer) handle(ctx context.Context, req interface{}) (interface{}, error) {
+	if d := s.ServiceTime(); d > 0 {
+		time.Sleep(d)
+	}
+	return req, nil
+}
+
+// call drives req through the server's UnaryInterceptor exactly as a real
+// gRPC server would, and reports back whatever headers the interceptor
+// attached (credits, load) alongside its error.
+func (s *FakeServer) call(ctx context.Context, method string, req interface{}) (interface{}, metadata.MD, error) {
+	stream := &fakeServerTransportStream{method: method}
+	ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	resp, err := s.BW.UnaryInterceptor(ctx, req, info, s.handle)
+	return resp, stream.resultHeader(), err
+}
+
+// Client wraps a client-side Breakwater pointed at a single FakeServer,
+// playing the role a grpc.ClientConn plus dial-time interceptor chain
+// would in production.
+type Client struct {
+	BW     *breakwater.Breakwater
+	Server *FakeServer
+}
+
+// NewClient builds a Client around bw, talking to server.
+func NewClient(bw *breakwater.Breakwater, server *FakeServer) *Client {
+	return &Client{BW: bw, Server: server}
+}
+
+// Call drives one request through UnaryInterceptorClient, which in turn
+// calls invoke below in place of a real grpc.UnaryInvoker.
+func (c *Client) Call(ctx context.Context, method string) error {
+	var reply interface{}
+	return c.BW.UnaryInterceptorClient(ctx, method, "req", &reply, nil, c.invoke)
+}
+
+// invoke matches grpc.UnaryInvoker. It bridges the outgoing metadata
+// UnaryInterceptorClient attached (demand, id) onto an incoming context
+// for the server side, then copies back any header the server set (via
+// grpc.SetHeader) into the caller's grpc.Header CallOption, the same way
+// a real transport would deliver it.
+func (c *Client) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+
+	_, header, err := c.Server.call(ctx, method, req)
+	for _, opt := range opts {
+		if h, ok := opt.(grpc.HeaderCallOption); ok {
+			*h.HeaderAddr = header
+		}
+	}
+	return err
+}
+
+// ArrivalFunc returns how long to wait before the next request is issued.
+type ArrivalFunc func() time.Duration
+
+// FixedArrivals issues one request every d, with no jitter.
+func FixedArrivals(d time.Duration) ArrivalFunc {
+	return func() time.Duration { return d }
+}
+
+// PoissonArrivals issues requests at a Poisson process with the given
+// mean rate (requests per second), i.e. exponentially distributed
+// inter-arrival times.
+func PoissonArrivals(rate float64) ArrivalFunc {
+	return func() time.Duration {
+		u := rand.Float64()
+		if u <= 0 {
+			u = math.SmallestNonzeroFloat64
+		}
+		seconds := -math.Log(u) / rate
+		return time.Duration(seconds * float64(time.Second))
+	}
+}
+
+// Config describes one simulation run.
+type Config struct {
+	// NumClients is how many independent client Breakwater instances to
+	// run concurrently, each with its own id and credit balance.
+	NumClients int
+
+	// Duration is how long each client generates arrivals for.
+	Duration time.Duration
+
+	// Arrivals controls the inter-arrival time between one client's
+	// requests. Every client gets its own call to Arrivals, so a
+	// non-deterministic ArrivalFunc (PoissonArrivals) produces
+	// independent arrival processes per client.
+	Arrivals ArrivalFunc
+
+	// Method is the fake RPC name threaded through to UnaryInterceptor.
+	Method string
+
+	// ServerParams configures the server-side Breakwater. ServerSide and
+	// LoadShedding should normally both be true; otherwise the AQM
+	// control loop this harness exists to test never engages.
+	ServerParams breakwater.BWParameters
+
+	// ClientParams configures every client-side Breakwater.
+	ClientParams breakwater.BWParameters
+
+	// ServiceTime is the server's per-request processing time knob; see
+	// FakeServer.ServiceTime.
+	ServiceTime func() time.Duration
+
+	// ClientOpts are applied to every client Breakwater via breakwater.New.
+	ClientOpts []breakwater.Option
+
+	// CallTimeout bounds each individual call's context, the same way a
+	// real caller's RPC deadline would. acquireCreditLoop's client-side
+	// expiration check only runs when the waiter is woken (by a credit,
+	// by Close, or by this deadline), so without it a client sitting
+	// behind a server that never issues another credit would otherwise
+	// wait forever. Defaults to 2 seconds.
+	CallTimeout time.Duration
+}
+
+// Report summarizes the outcome of a Run.
+type Report struct {
+	Admitted  int64
+	Dropped   int64
+	Expired   int64
+	Errored   int64
+	Latencies []time.Duration
+}
+
+// LatencyPercentile returns the latency at percentile p (0-100) among
+// admitted requests, or 0 if none were admitted. p is clamped to [0, 100].
+func (r Report) LatencyPercentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	if p < 0 {
+		p = 0
+	}
+	if p > 100 {
+		p = 100
+	}
+	sorted := make([]time.Duration, len(r.Latencies))
+	copy(sorted, r.Latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p / 100 * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// Run drives cfg.NumClients concurrent clients, each issuing requests
+// against one shared FakeServer according to cfg.Arrivals, for
+// cfg.Duration, and returns aggregate admission/latency statistics.
+//
+// Classifying a drop as Dropped vs Expired vs Errored is best-effort:
+// breakwater.DropError carries an exact breakwater.DropReason, but a
+// handler-side error (e.g. the server's own ResourceExhausted on AQM
+// shedding) has no DropError to inspect and is counted as Errored.
+func Run(cfg Config) Report {
+	if cfg.ServiceTime == nil {
+		cfg.ServiceTime = func() time.Duration { return 0 }
+	}
+	if cfg.Method == "" {
+		cfg.Method = "/sim.Service/Call"
+	}
+	if cfg.CallTimeout <= 0 {
+		cfg.CallTimeout = 2 * time.Second
+	}
+
+	// Construct clients before the server: InitBreakwater still mirrors
+	// ServerSide/LoadShedding/etc. onto deprecated package-level globals,
+	// and the server's UnaryInterceptor reads loadShedding from there, so
+	// whichever Breakwater is constructed last wins. Building the server
+	// last guarantees its own LoadShedding setting is the one in effect.
+	clients := make([]*breakwater.Breakwater, cfg.NumClients)
+	for i := range clients {
+		clients[i] = breakwater.New(cfg.ClientParams, cfg.ClientOpts...)
+	}
+	serverBW := breakwater.New(cfg.ServerParams)
+	server := &FakeServer{BW: serverBW, ServiceTime: cfg.ServiceTime}
+
+	var (
+		mu     sync.Mutex
+		report Report
+	)
+	record := func(err error, latency time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err == nil:
+			report.Admitted++
+			report.Latencies = append(report.Latencies, latency)
+		case isExpired(err):
+			report.Expired++
+		case isDropped(err):
+			report.Dropped++
+		default:
+			report.Errored++
+		}
+	}
+
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(cfg.Duration)
+	for _, bw := range clients {
+		wg.Add(1)
+		go func(bw *breakwater.Breakwater) {
+			defer wg.Done()
+			client := NewClient(bw, server)
+			for time.Now().Before(deadline) {
+				time.Sleep(cfg.Arrivals())
+				start := time.Now()
+				callCtx, cancel := context.WithTimeout(context.Background(), cfg.CallTimeout)
+				err := client.Call(callCtx, cfg.Method)
+				cancel()
+				record(err, time.Since(start))
+			}
+		}(bw)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func isExpired(err error) bool {
+	var dropErr *breakwater.DropError
+	return errors.As(err, &dropErr) && dropErr.Reason == breakwater.Expired
+}
+
+func isDropped(err error) bool {
+	var dropErr *breakwater.DropError
+	return errors.As(err, &dropErr)
+}
@@ -0,0 +1,95 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pennsail/breakwater-grpc/breakwater"
+)
+
+func TestRunAccountsForEveryCall(t *testing.T) {
+	report := Run(Config{
+		NumClients: 4,
+		Duration:   200 * time.Millisecond,
+		Arrivals:   FixedArrivals(2 * time.Millisecond),
+		ServerParams: breakwater.BWParameters{
+			ServerSide:   true,
+			LoadShedding: true,
+			SLO:          160,
+			BFactor:      0.02,
+			AFactor:      0.001,
+		},
+		ClientParams: breakwater.BWParameters{
+			InitialCredits:          10,
+			UseClientTimeExpiration: true,
+			ClientExpiration:        1000,
+			QueueCapacity:           breakwater.MAX_Q_LENGTH,
+		},
+	})
+
+	total := report.Admitted + report.Dropped + report.Expired + report.Errored
+	if total == 0 {
+		t.Fatal("expected at least one call to be accounted for")
+	}
+	if report.Admitted == 0 {
+		t.Fatal("expected at least one call to be admitted under no load")
+	}
+}
+
+func TestRunShedsLoadUnderOverload(t *testing.T) {
+	report := Run(Config{
+		NumClients: 20,
+		Duration:   300 * time.Millisecond,
+		Arrivals:   FixedArrivals(time.Millisecond),
+		ServiceTime: func() time.Duration {
+			return 20 * time.Millisecond
+		},
+		ServerParams: breakwater.BWParameters{
+			ServerSide:   true,
+			LoadShedding: true,
+			SLO:          160,
+			BFactor:      0.02,
+			AFactor:      0.001,
+		},
+		ClientParams: breakwater.BWParameters{
+			InitialCredits:          10,
+			UseClientTimeExpiration: true,
+			ClientExpiration:        1000,
+			QueueCapacity:           breakwater.MAX_Q_LENGTH,
+		},
+	})
+
+	if report.Dropped+report.Expired+report.Errored == 0 {
+		t.Fatal("expected the AQM control loop to shed some load under sustained overload")
+	}
+	if p99 := report.LatencyPercentile(99); p99 < 0 {
+		t.Fatalf("unexpected negative p99 latency: %v", p99)
+	}
+}
+
+func TestWithIDPropagatesToServerRegistration(t *testing.T) {
+	serverBW := breakwater.New(breakwater.BWParameters{
+		ServerSide:   true,
+		LoadShedding: true,
+		SLO:          160,
+		BFactor:      0.02,
+		AFactor:      0.001,
+	})
+	server := NewFakeServer(serverBW)
+
+	clientBW := breakwater.New(breakwater.BWParameters{
+		InitialCredits:          10,
+		UseClientTimeExpiration: true,
+		ClientExpiration:        1000,
+		QueueCapacity:           breakwater.MAX_Q_LENGTH,
+	}, breakwater.WithID("checkout-service"))
+	client := NewClient(clientBW, server)
+
+	if err := client.Call(context.Background(), "/sim.Service/Call"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if serverBW.NumClients() != 1 {
+		t.Fatalf("expected exactly one registered client, got %d", serverBW.NumClients())
+	}
+}